@@ -2,39 +2,96 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/Feokrat/music-dating-app/gateway/internal/TokenValidator"
 	"github.com/Feokrat/music-dating-app/gateway/internal/gateway"
+	"github.com/Feokrat/music-dating-app/gateway/internal/matches"
 	"github.com/Feokrat/music-dating-app/gateway/internal/notifications"
 	"github.com/Feokrat/music-dating-app/gateway/internal/session"
 	"github.com/gin-contrib/cors"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/Feokrat/music-dating-app/gateway/api"
 	"github.com/Feokrat/music-dating-app/gateway/internal/config"
+	"github.com/Feokrat/music-dating-app/gateway/internal/middleware"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
 	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPserver"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/health"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/logging"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/openapi"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var configFile = "configs/config"
+var configFile = flag.String("config", "configs/config.yml",
+	"path to the config file, its settings can be overridden by environment variables")
+
+const serviceName = "gateway"
 
 func main() {
-	logger := log.New(os.Stdout, "logger: ", log.Lshortfile)
+	flag.Parse()
+	args := flag.Args()
+
+	// used until the configured logger can be built
+	bootLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	cfg, err := config.Init(*configFile, bootLogger)
+	if err != nil {
+		bootLogger.Error("failed to load application configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "config" {
+		if err := runConfig(args[1:]); err != nil {
+			bootLogger.Error("failed to run config command", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		bootLogger.Error("invalid application configuration", "error", err)
+		os.Exit(1)
+	}
 
-	cfg, err := config.Init(configFile, logger)
+	logger, err := logging.NewLogger(cfg.Log, os.Stdout)
 	if err != nil {
-		logger.Fatalf("failed to load application configuration: %s", err)
+		bootLogger.Error("failed to set up logging", "error", err)
+		os.Exit(1)
 	}
+	slog.SetDefault(logger)
 
-	handlers := buildHandler(cfg, logger)
-	server := HTTPserver.NewHTTPserver(cfg, handlers)
+	shutdownTracing, err := tracing.NewTracerProvider(cfg.Tracing, serviceName)
+	if err != nil {
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	checker, err := buildChecker(cfg)
+	if err != nil {
+		logger.Error("failed to set up readiness checks", "error", err)
+		os.Exit(1)
+	}
+
+	handlers, err := buildHandler(cfg, logger, checker)
+	if err != nil {
+		logger.Error("failed to build http handler", "error", err)
+		os.Exit(1)
+	}
+	server := HTTPserver.NewHTTPserver(cfg, handlers, checker)
 
 	go func() {
-		if err := server.Run(); err != nil {
-			logger.Printf("error occurred while running http server: %s\n", err.Error())
+		if err := server.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("error occurred while running http server", "error", err)
 		}
 	}()
 
@@ -43,40 +100,102 @@ func main() {
 	signal.Notify(c, syscall.SIGTERM)
 
 	sig := <-c
-	logger.Println("Got signal:", sig)
-	logger.Print("Shutting down server")
+	logger.Info("got signal", "signal", sig.String())
+	logger.Info("shutting down server")
 
 	ctx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdown()
 
 	server.Stop(ctx)
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
 }
 
-func buildHandler(cfg *config.Config, logger *log.Logger) http.Handler {
-	router := gin.Default()
+func buildHandler(cfg *config.Config, logger *slog.Logger, checker *health.Checker) (http.Handler, error) {
+	doc, err := openapi.Load(api.Spec)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := openapi.Validator(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	router := gin.New()
+	// clients reach the gateway directly, a forwarded address would be
+	// whatever they put there
+	if err := router.SetTrustedProxies(nil); err != nil {
+		return nil, err
+	}
 
 	router.Use(
-		gin.Recovery(),
-		gin.Logger(),
+		problem.Recovery(),
+		middleware.RequestID(),
+		middleware.Logger(logger),
+		middleware.Tracing(serviceName),
+		middleware.Metrics(),
+		middleware.Timeout(cfg.HTTP.RequestTimeout),
 		cors.Default(),
 		CORSMiddleware(),
+		validator,
 	)
 
+	router.NoRoute(problem.NoRoute)
+
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	health.RegisterHandlers(router, checker)
+	openapi.RegisterHandler(router, doc)
+
+	clients := HTTPclient.NewClients(cfg.Services)
+	validationService := TokenValidator.NewValidationService(logger, cfg.Services, cfg.Token, clients.Sessions)
+	usersService := gateway.NewUsersService(cfg.Services, clients, logger)
+
 	rg := router.Group("/api/v1")
-	gateway.RegisterUsersHandlers(rg.Group(""), gateway.NewUsersService(cfg.Services, logger),
-		TokenValidator.NewValidationService(logger, cfg.Services), logger)
+	gateway.RegisterUsersHandlers(rg.Group(""), usersService, validationService, logger)
 
-	session.RegisterAuthHandlers(rg.Group("/sessions"), session.NewSessionService(logger, cfg.Services),
-		logger, gateway.NewUsersService(cfg.Services, logger))
+	session.RegisterAuthHandlers(rg.Group("/sessions"), session.NewSessionService(logger, cfg.Services, clients),
+		logger, usersService, validationService)
 
-	notifications.RegisterChatHandlers(rg.Group("/chats"), notifications.NewNotificationService(cfg.Services, logger),
-		TokenValidator.NewValidationService(logger, cfg.Services), gateway.NewUsersService(cfg.Services, logger), logger)
+	notificationService := notifications.NewNotificationService(cfg.Services, clients, logger)
+	notifications.RegisterChatHandlers(rg.Group("/chats"), notificationService, validationService, usersService, logger)
 
-	return router
+	matches.RegisterMatchHandlers(rg.Group("/matches"), matches.NewMatchService(cfg.Services, clients, logger),
+		notificationService, validationService, logger)
+
+	return router, nil
+}
+
+// buildChecker makes the gateway ready only while every upstream service is
+// reachable. Probes bypass the upstream clients, so they neither count towards
+// nor are rejected by the circuit breakers.
+func buildChecker(cfg *config.Config) (*health.Checker, error) {
+	checker := health.NewChecker(cfg.HTTP.ReadinessTimeout)
+	client := &http.Client{}
+
+	upstreams := []struct {
+		name string
+		url  string
+	}{
+		{"user_service", cfg.Services.UserService},
+		{"music_service", cfg.Services.MusicService},
+		{"notification_service", cfg.Services.NotificationService},
+		{"payment_service", cfg.Services.PaymentService},
+		{"session_service", cfg.Services.SessionService},
+	}
+	for _, upstream := range upstreams {
+		check, err := health.Upstream(client, upstream.url)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", upstream.name, err)
+		}
+		checker.Add(upstream.name, check)
+	}
+
+	return checker, nil
 }
 
 func CORSMiddleware() gin.HandlerFunc {
@@ -84,8 +203,8 @@ func CORSMiddleware() gin.HandlerFunc {
 
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -95,3 +214,13 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// runConfig handles "config print", which shows the effective config with
+// secrets redacted.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("unknown config command, expected print")
+	}
+
+	return config.Print(os.Stdout)
+}
@@ -1,67 +1,95 @@
 package TokenValidator
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Feokrat/music-dating-app/gateway/internal/config"
 	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
 	"github.com/google/uuid"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 type validator struct {
-	logger *log.Logger
-	client http.Client
+	logger *slog.Logger
+	client *HTTPclient.HTTPclient
 	config config.ServicesConfig
 }
 
+// Identity is the owner of a validated token.
+type Identity struct {
+	UserId uuid.UUID `json:"id"`
+	Role   string    `json:"role"`
+}
+
 type ValidationService interface {
-	Validate(token string) (uuid.UUID, error)
+	Validate(ctx context.Context, token string) (uuid.UUID, error)
+	ValidateIdentity(ctx context.Context, token string) (Identity, error)
+	// Invalidate rejects the token and the other tokens of its session from
+	// now on, e.g. after logout. The token must have been accepted by the
+	// sessions service.
+	Invalidate(token string)
+	// InvalidateUser drops every cached token of a user and reloads the
+	// revoked sessions, e.g. after one or all of their sessions were revoked.
+	InvalidateUser(ctx context.Context, userId uuid.UUID) error
 }
 
-func NewValidationService(logger *log.Logger, config config.ServicesConfig) ValidationService {
-	return validator{logger: logger, client: http.Client{}, config: config}
+type identityValidator interface {
+	ValidateIdentity(ctx context.Context, token string) (Identity, error)
 }
 
-func (v validator) Validate(token string) (uuid.UUID, error) {
+func NewValidationService(logger *slog.Logger, config config.ServicesConfig, tokens config.TokenConfig,
+	client *HTTPclient.HTTPclient) ValidationService {
+	revocations := newRevocationList(logger, client, config.SessionService+revocationsPath,
+		tokens.RevocationsInterval, tokens.RevocationsMaxAge)
+
+	var inner identityValidator = validator{logger: logger, client: client, config: config}
+	if tokens.LocalVerification {
+		inner = newLocalValidator(logger, client, config, tokens, revocations, inner)
+	}
+	return newCachedValidator(inner, revocations, tokens.CacheSize, tokens.CacheTTL, tokens.NegativeCacheTTL)
+}
+
+func (v validator) ValidateIdentity(ctx context.Context, token string) (Identity, error) {
 	sessionUrl := v.config.SessionService + fmt.Sprintf("/auth/token/validate")
 
-	req, err := http.NewRequest("GET", sessionUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", sessionUrl, nil)
 	if err != nil {
-		v.logger.Printf("could not create request, error: %s", err.Error())
-		return uuid.UUID{}, err
+		v.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return Identity{}, err
 	}
 
 	req.Header.Add("Authorization", "Bearer"+fmt.Sprintf(" %v", token))
 
 	resp, err := v.client.Do(req)
 	if err != nil {
-		v.logger.Printf("could not get users, error: %s", err.Error())
-		return uuid.UUID{}, err
+		v.logger.ErrorContext(ctx, "could not get users", "error", err)
+		return Identity{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return Identity{}, v.client.StatusError(resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Non-OK HTTP status:", resp.StatusCode)
-		// You may read / inspect response body
-		return uuid.UUID{}, schemas.TokenError
+		return Identity{}, schemas.TokenError
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		v.logger.Printf("could not read response body, error: %s",
-			err.Error())
-		return uuid.UUID{}, err
+		v.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return Identity{}, err
 	}
 
-	var userId schemas.IdResponse
-	err = json.Unmarshal(body, &userId)
+	var identity Identity
+	err = json.Unmarshal(body, &identity)
 	if err != nil {
-		v.logger.Printf("could not unmarshal response body, error: %s", err.Error())
-		return uuid.UUID{}, err
+		v.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return Identity{}, err
 	}
 
-	return userId.ID, err
+	return identity, err
 }
@@ -0,0 +1,198 @@
+package TokenValidator
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+type tokenHash [sha256.Size]byte
+
+type cacheEntry struct {
+	key       tokenHash
+	identity  Identity
+	err       error
+	tokenId   string
+	sessionId string
+	// tokenExpiresAt is zero for tokens without an exp claim
+	tokenExpiresAt time.Time
+	expiresAt      time.Time
+}
+
+// cachedValidator keeps the results of recent validations in a bounded LRU
+// cache keyed by the token hash, so that polling clients do not hit the
+// sessions service on every request. Rejected tokens are cached as well, for
+// a shorter time, and concurrent validations of the same token are collapsed
+// into a single call. Tokens are rejected as soon as they or their sessions
+// are revoked, whether their result is cached or not.
+type cachedValidator struct {
+	inner       identityValidator
+	revocations *revocationList
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	group       singleflight.Group
+
+	mu      sync.Mutex
+	entries map[tokenHash]*list.Element
+	order   *list.List
+}
+
+func newCachedValidator(inner identityValidator, revocations *revocationList, size int,
+	ttl, negativeTTL time.Duration) *cachedValidator {
+	return &cachedValidator{
+		inner:       inner,
+		revocations: revocations,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     map[tokenHash]*list.Element{},
+		order:       list.New(),
+	}
+}
+
+func (v *cachedValidator) Validate(ctx context.Context, token string) (uuid.UUID, error) {
+	identity, err := v.ValidateIdentity(ctx, token)
+	return identity.UserId, err
+}
+
+func (v *cachedValidator) ValidateIdentity(ctx context.Context, token string) (Identity, error) {
+	if v.size <= 0 {
+		return v.inner.ValidateIdentity(ctx, token)
+	}
+
+	key := tokenHash(sha256.Sum256([]byte(token)))
+	entry, ok := v.get(key)
+	if !ok {
+		// concurrent callers share the call made with the context of the first one,
+		// a cancelled call is not cached so the others only see it once
+		result, _, _ := v.group.Do(string(key[:]), func() (interface{}, error) {
+			identity, err := v.inner.ValidateIdentity(ctx, token)
+			entry := newCacheEntry(key, token, identity, err)
+			v.put(entry)
+			return entry, nil
+		})
+		entry = result.(cacheEntry)
+	}
+
+	if entry.err == nil {
+		if revoked, _ := v.revocations.revoked(ctx, entry.tokenId, entry.sessionId); revoked {
+			return Identity{}, schemas.TokenError
+		}
+	}
+	return entry.identity, entry.err
+}
+
+func (v *cachedValidator) Invalidate(token string) {
+	if claims, ok := tokenClaims(token); ok {
+		v.revocations.add(claims.Id, claims.SessionId, time.Unix(claims.ExpiresAt, 0))
+	}
+
+	key := tokenHash(sha256.Sum256([]byte(token)))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if element, ok := v.entries[key]; ok {
+		v.remove(element)
+	}
+}
+
+func (v *cachedValidator) InvalidateUser(ctx context.Context, userId uuid.UUID) error {
+	v.mu.Lock()
+	for element := v.order.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*cacheEntry).identity.UserId == userId {
+			v.remove(element)
+		}
+		element = next
+	}
+	v.mu.Unlock()
+
+	return v.revocations.reload(ctx)
+}
+
+func (v *cachedValidator) get(key tokenHash) (cacheEntry, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	element, ok := v.entries[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		v.remove(element)
+		return cacheEntry{}, false
+	}
+
+	v.order.MoveToFront(element)
+	return *entry, true
+}
+
+func newCacheEntry(key tokenHash, token string, identity Identity, err error) cacheEntry {
+	entry := cacheEntry{key: key, identity: identity, err: err}
+	if claims, ok := tokenClaims(token); ok {
+		entry.tokenId, entry.sessionId = claims.Id, claims.SessionId
+		if claims.ExpiresAt != 0 {
+			entry.tokenExpiresAt = time.Unix(claims.ExpiresAt, 0)
+		}
+	}
+	return entry
+}
+
+func (v *cachedValidator) put(entry cacheEntry) {
+	now := time.Now()
+	switch {
+	case entry.err == nil:
+		entry.expiresAt = now.Add(v.ttl)
+		if !entry.tokenExpiresAt.IsZero() && entry.tokenExpiresAt.Before(entry.expiresAt) {
+			entry.expiresAt = entry.tokenExpiresAt
+		}
+	case errors.Is(entry.err, schemas.TokenError):
+		entry.expiresAt = now.Add(v.negativeTTL)
+	default:
+		// transport failures say nothing about the token itself
+		return
+	}
+	if !now.Before(entry.expiresAt) {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if element, ok := v.entries[entry.key]; ok {
+		v.remove(element)
+	}
+	v.entries[entry.key] = v.order.PushFront(&entry)
+
+	for v.order.Len() > v.size {
+		v.remove(v.order.Back())
+	}
+}
+
+func (v *cachedValidator) remove(element *list.Element) {
+	v.order.Remove(element)
+	delete(v.entries, element.Value.(*cacheEntry).key)
+}
+
+// tokenClaims reads the claims without verifying the token; the token has
+// already been validated by the time its claims are used.
+func tokenClaims(token string) (sessionClaims, bool) {
+	var parser jwt.Parser
+	var claims sessionClaims
+	if _, _, err := parser.ParseUnverified(token, &claims); err != nil {
+		return sessionClaims{}, false
+	}
+	return claims, true
+}
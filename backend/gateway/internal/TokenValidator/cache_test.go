@@ -0,0 +1,270 @@
+package TokenValidator
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/gateway/internal/config"
+	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+var (
+	discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	testUserId    = uuid.MustParse("8f6a1c59-2b1f-4f0c-8a57-6c0e6a2b9d13")
+)
+
+// fakeValidator accepts every token unless err is set and counts its calls.
+type fakeValidator struct {
+	calls atomic.Int32
+	err   error
+	// release, when set, blocks the calls until it is closed
+	release chan struct{}
+}
+
+func (f *fakeValidator) ValidateIdentity(context.Context, string) (Identity, error) {
+	f.calls.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	if f.err != nil {
+		return Identity{}, f.err
+	}
+	return Identity{UserId: testUserId, Role: "user"}, nil
+}
+
+// freshRevocations is a revocation list that needs no reload within the test.
+func freshRevocations() *revocationList {
+	list := newRevocationList(discardLogger, nil, "", time.Hour, time.Hour)
+	list.fetchedAt = time.Now()
+	return list
+}
+
+func testToken(t *testing.T, sessionId string, expiresAt time.Time) string {
+	t.Helper()
+	claims := sessionClaims{
+		StandardClaims: jwt.StandardClaims{Id: uuid.NewString(), Subject: testUserId.String(), ExpiresAt: expiresAt.Unix()},
+		SessionId:      sessionId,
+		Role:           "user",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func (v *cachedValidator) cached(token string) (cacheEntry, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	element, ok := v.entries[tokenHash(sha256.Sum256([]byte(token)))]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	return *element.Value.(*cacheEntry), true
+}
+
+func TestCacheCapsTTLAtTokenExpiry(t *testing.T) {
+	inner := &fakeValidator{}
+	v := newCachedValidator(inner, freshRevocations(), 10, time.Hour, time.Minute)
+	expiresAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	token := testToken(t, uuid.NewString(), expiresAt)
+
+	for i := 0; i < 2; i++ {
+		if _, err := v.ValidateIdentity(context.Background(), token); err != nil {
+			t.Fatalf("ValidateIdentity() error = %v", err)
+		}
+	}
+
+	if got := inner.calls.Load(); got != 1 {
+		t.Errorf("inner validator called %d times, want 1", got)
+	}
+	entry, ok := v.cached(token)
+	if !ok {
+		t.Fatal("token is not cached")
+	}
+	if !entry.expiresAt.Equal(expiresAt) {
+		t.Errorf("entry expires at %v, want the token expiry %v", entry.expiresAt, expiresAt)
+	}
+}
+
+func TestCacheSkipsExpiredTokens(t *testing.T) {
+	inner := &fakeValidator{}
+	v := newCachedValidator(inner, freshRevocations(), 10, time.Hour, time.Minute)
+	token := testToken(t, uuid.NewString(), time.Now().Add(-time.Second))
+
+	for i := 0; i < 2; i++ {
+		_, _ = v.ValidateIdentity(context.Background(), token)
+	}
+
+	if got := inner.calls.Load(); got != 2 {
+		t.Errorf("inner validator called %d times, want 2", got)
+	}
+}
+
+func TestCacheKeepsRejectionsForNegativeTTL(t *testing.T) {
+	inner := &fakeValidator{err: schemas.TokenError}
+	v := newCachedValidator(inner, freshRevocations(), 10, time.Hour, 50*time.Millisecond)
+	token := testToken(t, uuid.NewString(), time.Now().Add(time.Hour))
+
+	for i := 0; i < 2; i++ {
+		if _, err := v.ValidateIdentity(context.Background(), token); !errors.Is(err, schemas.TokenError) {
+			t.Fatalf("ValidateIdentity() error = %v, want %v", err, schemas.TokenError)
+		}
+	}
+	if got := inner.calls.Load(); got != 1 {
+		t.Errorf("inner validator called %d times within negative TTL, want 1", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	_, _ = v.ValidateIdentity(context.Background(), token)
+	if got := inner.calls.Load(); got != 2 {
+		t.Errorf("inner validator called %d times after negative TTL, want 2", got)
+	}
+}
+
+func TestCacheSkipsTransportErrors(t *testing.T) {
+	inner := &fakeValidator{err: errors.New("connection refused")}
+	v := newCachedValidator(inner, freshRevocations(), 10, time.Hour, time.Minute)
+	token := testToken(t, uuid.NewString(), time.Now().Add(time.Hour))
+
+	for i := 0; i < 2; i++ {
+		_, _ = v.ValidateIdentity(context.Background(), token)
+	}
+
+	if got := inner.calls.Load(); got != 2 {
+		t.Errorf("inner validator called %d times, want 2", got)
+	}
+}
+
+func TestCacheCollapsesConcurrentValidations(t *testing.T) {
+	inner := &fakeValidator{release: make(chan struct{})}
+	v := newCachedValidator(inner, freshRevocations(), 10, time.Hour, time.Minute)
+	token := testToken(t, uuid.NewString(), time.Now().Add(time.Hour))
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.ValidateIdentity(context.Background(), token)
+			errs <- err
+		}()
+	}
+	// callers arriving after the release find the cached result instead
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("ValidateIdentity() error = %v", err)
+		}
+	}
+	if got := inner.calls.Load(); got != 1 {
+		t.Errorf("inner validator called %d times for %d concurrent callers, want 1", got, callers)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	inner := &fakeValidator{}
+	v := newCachedValidator(inner, freshRevocations(), 2, time.Hour, time.Minute)
+	expiresAt := time.Now().Add(time.Hour)
+	first, second, third := testToken(t, "a", expiresAt), testToken(t, "b", expiresAt), testToken(t, "c", expiresAt)
+
+	for _, token := range []string{first, second, first, third} {
+		if _, err := v.ValidateIdentity(context.Background(), token); err != nil {
+			t.Fatalf("ValidateIdentity() error = %v", err)
+		}
+	}
+
+	if _, ok := v.cached(second); ok {
+		t.Error("least recently used token is still cached")
+	}
+	for _, token := range []string{first, third} {
+		if _, ok := v.cached(token); !ok {
+			t.Error("recently used token was evicted")
+		}
+	}
+	if got := inner.calls.Load(); got != 3 {
+		t.Errorf("inner validator called %d times, want 3", got)
+	}
+}
+
+func TestInvalidateRejectsTokenAndSession(t *testing.T) {
+	v := newCachedValidator(&fakeValidator{}, freshRevocations(), 10, time.Hour, time.Minute)
+	sessionId := uuid.NewString()
+	token := testToken(t, sessionId, time.Now().Add(time.Hour))
+	sameSession := testToken(t, sessionId, time.Now().Add(time.Hour))
+	otherSession := testToken(t, uuid.NewString(), time.Now().Add(time.Hour))
+
+	for _, token := range []string{token, sameSession, otherSession} {
+		if _, err := v.ValidateIdentity(context.Background(), token); err != nil {
+			t.Fatalf("ValidateIdentity() error = %v", err)
+		}
+	}
+
+	v.Invalidate(token)
+
+	for _, token := range []string{token, sameSession} {
+		if _, err := v.ValidateIdentity(context.Background(), token); !errors.Is(err, schemas.TokenError) {
+			t.Errorf("ValidateIdentity() after Invalidate error = %v, want %v", err, schemas.TokenError)
+		}
+	}
+	if _, err := v.ValidateIdentity(context.Background(), otherSession); err != nil {
+		t.Errorf("ValidateIdentity() of another session error = %v", err)
+	}
+}
+
+func TestInvalidateUserReloadsRevokedSessions(t *testing.T) {
+	revokedSessionId := uuid.NewString()
+	var revoked atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != revocationsPath {
+			http.NotFound(w, r)
+			return
+		}
+		sessions := `[]`
+		if revoked.Load() {
+			sessions = `[{"id":"` + revokedSessionId + `","expiresAt":"` +
+				time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}]`
+		}
+		_, _ = io.WriteString(w, `{"tokens":[],"sessions":`+sessions+`}`)
+	}))
+	defer server.Close()
+
+	client := HTTPclient.NewHTTPclient("sessions", config.ClientConfig{Timeout: time.Second})
+	revocations := newRevocationList(discardLogger, client, server.URL+revocationsPath, time.Hour, time.Hour)
+	v := newCachedValidator(&fakeValidator{}, revocations, 10, time.Hour, time.Minute)
+	token := testToken(t, revokedSessionId, time.Now().Add(time.Hour))
+
+	if _, err := v.ValidateIdentity(context.Background(), token); err != nil {
+		t.Fatalf("ValidateIdentity() error = %v", err)
+	}
+
+	revoked.Store(true)
+	if err := v.InvalidateUser(context.Background(), testUserId); err != nil {
+		t.Fatalf("InvalidateUser() error = %v", err)
+	}
+
+	if _, ok := v.cached(token); ok {
+		t.Error("token of the user is still cached")
+	}
+	if _, err := v.ValidateIdentity(context.Background(), token); !errors.Is(err, schemas.TokenError) {
+		t.Errorf("ValidateIdentity() in revoked session error = %v, want %v", err, schemas.TokenError)
+	}
+}
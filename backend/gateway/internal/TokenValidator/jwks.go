@@ -0,0 +1,135 @@
+package TokenValidator
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
+)
+
+// minKeysRefreshInterval stops tokens with made up key ids from making the
+// gateway download the key set on every request.
+const minKeysRefreshInterval = 30 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// keySet is a cache of the public keys published by the sessions service.
+type keySet struct {
+	logger *slog.Logger
+	client *HTTPclient.HTTPclient
+	url    string
+	ttl    time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	refreshedAt time.Time
+}
+
+func newKeySet(logger *slog.Logger, client *HTTPclient.HTTPclient, url string, ttl time.Duration) *keySet {
+	return &keySet{logger: logger, client: client, url: url, ttl: ttl, keys: map[string]*rsa.PublicKey{}}
+}
+
+// key returns the cached key with the given id. The cache is reloaded once it
+// is older than ttl or, at most every minKeysRefreshInterval, when asked for
+// a key it does not know.
+func (k *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, bool) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	stale := time.Since(k.fetchedAt) > k.ttl
+	canRefresh := time.Since(k.refreshedAt) > minKeysRefreshInterval
+	k.mu.RUnlock()
+
+	if (ok && !stale) || !canRefresh {
+		return key, ok
+	}
+
+	if err := k.refresh(ctx); err != nil {
+		k.logger.ErrorContext(ctx, "could not refresh token signing keys", "error", err)
+		return key, ok
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok = k.keys[kid]
+	return key, ok
+}
+
+func (k *keySet) refresh(ctx context.Context) error {
+	k.mu.Lock()
+	k.refreshedAt = time.Now()
+	k.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("key set endpoint responded with %v", resp.StatusCode)
+	}
+
+	var set jwks
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		publicKey, err := parseRSAPublicKey(key)
+		if err != nil {
+			k.logger.ErrorContext(ctx, "skipping token signing key", "kid", key.Kid, "error", err)
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+func parseRSAPublicKey(key jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > int64(^uint32(0)>>1) {
+		return nil, fmt.Errorf("exponent of key %s is too large", key.Kid)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
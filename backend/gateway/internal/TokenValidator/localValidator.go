@@ -0,0 +1,89 @@
+package TokenValidator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/Feokrat/music-dating-app/gateway/internal/config"
+	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+const jwksPath = "/.well-known/jwks.json"
+
+type sessionClaims struct {
+	jwt.StandardClaims
+	SessionId string `json:"sid"`
+	Role      string `json:"role"`
+}
+
+// localValidator checks RS256 tokens against the cached key set of the
+// sessions service and rejects the revoked ones. It only asks the sessions
+// service itself about tokens signed with a key it does not know, or when the
+// list of revoked tokens could not be refreshed for too long.
+type localValidator struct {
+	logger      *slog.Logger
+	keys        *keySet
+	revocations *revocationList
+	remote      identityValidator
+}
+
+func newLocalValidator(logger *slog.Logger, client *HTTPclient.HTTPclient, services config.ServicesConfig,
+	tokens config.TokenConfig, revocations *revocationList, remote identityValidator) identityValidator {
+	return localValidator{
+		logger:      logger,
+		keys:        newKeySet(logger, client, services.SessionService+jwksPath, tokens.JWKSCacheTTL),
+		revocations: revocations,
+		remote:      remote,
+	}
+}
+
+func (v localValidator) ValidateIdentity(ctx context.Context, token string) (Identity, error) {
+	var parser jwt.Parser
+	unverified, _, err := parser.ParseUnverified(token, &sessionClaims{})
+	if err != nil {
+		return Identity{}, schemas.TokenError
+	}
+
+	kid, _ := unverified.Header["kid"].(string)
+	if unverified.Method != jwt.SigningMethodRS256 || kid == "" {
+		return v.remote.ValidateIdentity(ctx, token)
+	}
+
+	key, ok := v.keys.key(ctx, kid)
+	if !ok {
+		return v.remote.ValidateIdentity(ctx, token)
+	}
+
+	parsed, err := jwt.ParseWithClaims(token, &sessionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, errors.New("invalid token signing method")
+		}
+		return key, nil
+	})
+	if err != nil {
+		v.logger.ErrorContext(ctx, "could not verify token", "error", err)
+		return Identity{}, schemas.TokenError
+	}
+
+	claims := parsed.Claims.(*sessionClaims)
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil || claims.SessionId == "" {
+		return Identity{}, schemas.TokenError
+	}
+
+	revoked, fresh := v.revocations.revoked(ctx, claims.Id, claims.SessionId)
+	if revoked {
+		return Identity{}, schemas.TokenError
+	}
+	if !fresh {
+		return v.remote.ValidateIdentity(ctx, token)
+	}
+
+	// the role of the token is current, changing the role of a user revokes
+	// their sessions
+	return Identity{UserId: userId, Role: claims.Role}, nil
+}
@@ -0,0 +1,193 @@
+package TokenValidator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
+	"golang.org/x/sync/singleflight"
+)
+
+const revocationsPath = "/auth/revocations"
+
+type revocation struct {
+	Id        string    `json:"id"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type revocations struct {
+	Tokens   []revocation `json:"tokens"`
+	Sessions []revocation `json:"sessions"`
+}
+
+// revocationList mirrors the revoked tokens and sessions of the sessions
+// service, each kept until the access tokens carrying its id expire. While it
+// is used the list is reloaded every interval, and once it is older than
+// maxAge it is stale: tokens it does not know to be revoked have to be
+// checked by the sessions service. Revocations the gateway made itself are
+// kept across reloads, so they apply before the sessions service lists them.
+type revocationList struct {
+	logger   *slog.Logger
+	client   *HTTPclient.HTTPclient
+	url      string
+	interval time.Duration
+	maxAge   time.Duration
+	group    singleflight.Group
+
+	mu          sync.RWMutex
+	tokens      map[string]time.Time
+	sessions    map[string]time.Time
+	local       map[string]time.Time
+	fetchedAt   time.Time
+	refreshedAt time.Time
+}
+
+func newRevocationList(logger *slog.Logger, client *HTTPclient.HTTPclient, url string,
+	interval, maxAge time.Duration) *revocationList {
+	return &revocationList{
+		logger:   logger,
+		client:   client,
+		url:      url,
+		interval: interval,
+		maxAge:   maxAge,
+		tokens:   map[string]time.Time{},
+		sessions: map[string]time.Time{},
+		local:    map[string]time.Time{},
+	}
+}
+
+// add revokes a token and its session. The session is kept for maxAge, by
+// then a reloaded list has it or is stale.
+func (r *revocationList) add(tokenId string, sessionId string, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if tokenId != "" {
+		r.local[tokenId] = expiresAt
+	}
+	if sessionId != "" {
+		r.local[sessionId] = time.Now().Add(r.maxAge)
+	}
+}
+
+// reload fetches the list right away, e.g. after sessions were revoked.
+func (r *revocationList) reload(ctx context.Context) error {
+	_, err, _ := r.group.Do("reload", func() (interface{}, error) {
+		return nil, r.refresh(ctx)
+	})
+	return err
+}
+
+// revoked reports whether the token with the given id or its session is
+// revoked, and whether the list was fresh enough to trust a negative answer.
+func (r *revocationList) revoked(ctx context.Context, tokenId, sessionId string) (revoked bool, fresh bool) {
+	fresh = r.sync(ctx)
+
+	now := time.Now()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return now.Before(r.tokens[tokenId]) || now.Before(r.sessions[sessionId]) ||
+		now.Before(r.local[tokenId]) || now.Before(r.local[sessionId]), fresh
+}
+
+// sync reloads the list once it is older than interval, at most once per
+// interval. A list that is still fresh is reloaded in the background, a stale
+// one before returning.
+func (r *revocationList) sync(ctx context.Context) bool {
+	r.mu.RLock()
+	age := time.Since(r.fetchedAt)
+	canRefresh := time.Since(r.refreshedAt) > r.interval
+	r.mu.RUnlock()
+
+	if age <= r.interval || !canRefresh {
+		return age <= r.maxAge
+	}
+
+	if age <= r.maxAge {
+		r.group.DoChan("refresh", func() (interface{}, error) {
+			return nil, r.refresh(context.Background())
+		})
+		return true
+	}
+
+	if _, err, _ := r.group.Do("refresh", func() (interface{}, error) {
+		return nil, r.refresh(ctx)
+	}); err != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return time.Since(r.fetchedAt) <= r.maxAge
+}
+
+func (r *revocationList) refresh(ctx context.Context) error {
+	startedAt := time.Now()
+	r.mu.Lock()
+	r.refreshedAt = startedAt
+	r.mu.Unlock()
+
+	list, err := r.fetch(ctx)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "could not refresh revoked tokens", "error", err)
+		return err
+	}
+
+	tokens := expirations(list.Tokens, startedAt)
+	sessions := expirations(list.Sessions, startedAt)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// a reload may overtake a slower background refresh
+	if startedAt.Before(r.fetchedAt) {
+		return nil
+	}
+	r.tokens = tokens
+	r.sessions = sessions
+	r.fetchedAt = startedAt
+	for id, expiresAt := range r.local {
+		if !expiresAt.After(startedAt) {
+			delete(r.local, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *revocationList) fetch(ctx context.Context) (revocations, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return revocations{}, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return revocations{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return revocations{}, fmt.Errorf("revocations endpoint responded with %v", resp.StatusCode)
+	}
+
+	var list revocations
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	return list, err
+}
+
+// expirations indexes the revocations by id, leaving out the expired ones.
+func expirations(list []revocation, now time.Time) map[string]time.Time {
+	index := make(map[string]time.Time, len(list))
+	for _, revocation := range list {
+		if revocation.ExpiresAt.After(now) {
+			index[revocation.Id] = revocation.ExpiresAt
+		}
+	}
+	return index
+}
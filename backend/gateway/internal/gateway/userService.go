@@ -2,355 +2,533 @@ package gateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/Feokrat/music-dating-app/gateway/internal/config"
 	"github.com/Feokrat/music-dating-app/gateway/internal/models"
 	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
 	"github.com/google/uuid"
 )
 
 type UsersService interface {
-	AddUser(request schemas.UserRequest) (uuid.UUID, error)
-	UpdateUserInfo(id uuid.UUID, user models.UpdateUserInfo) (int, error)
-	GetUserById(id uuid.UUID) (schemas.UserResponse, int, error)
-	DeleteUserById(id uuid.UUID) (int, error)
-	GetAllUsers(page, size int) (schemas.UsersResponse, int, error)
-	GetAllMusics(page, size int) (schemas.MusicsResponse, int, error)
-	GetUserRecommendations(id uuid.UUID) (schemas.UsersResponse, int, error)
-	GetUserImage(userId uuid.UUID) (schemas.UserImageResponse, int, error)
-	LikeUser(whoLikedId uuid.UUID, whomLikedId uuid.UUID) (schemas.LikeResponse, int, error)
-	CreateChatForMatch(whoLikedId uuid.UUID, whomLikedId uuid.UUID) (uuid.UUID, int, error)
+	AddUser(ctx context.Context, request schemas.UserRequest) (uuid.UUID, error)
+	UpdateUserInfo(ctx context.Context, id uuid.UUID, user models.UpdateUserInfo) (int, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (schemas.UserResponse, int, error)
+	DeleteUserById(ctx context.Context, id uuid.UUID) (int, error)
+	GetAllUsers(ctx context.Context, page, size int) (schemas.UsersResponse, int, error)
+	GetAllMusics(ctx context.Context, page, size int) (schemas.MusicsResponse, int, error)
+	AddMusic(ctx context.Context, music schemas.MusicRequest) (int, error)
+	DeleteMusicById(ctx context.Context, id uuid.UUID) (int, error)
+	GetUserRecommendations(ctx context.Context, id uuid.UUID, page, size int) (schemas.RecommendationsResponse, int, error)
+	GetUserImage(ctx context.Context, userId uuid.UUID) (schemas.UserImageResponse, int, error)
+	LikeUser(ctx context.Context, whoLikedId uuid.UUID, whomLikedId uuid.UUID) (schemas.LikeResponse, int, error)
+	CreateChatForMatch(ctx context.Context, matchId uuid.UUID, whoLikedId uuid.UUID, whomLikedId uuid.UUID) (uuid.UUID, int, error)
+	DislikeUser(ctx context.Context, id uuid.UUID, dislikedId uuid.UUID) (schemas.SwipeResponse, int, error)
+	PassUser(ctx context.Context, id uuid.UUID, passedId uuid.UUID) (schemas.SwipeResponse, int, error)
+	GetSwipes(ctx context.Context, id uuid.UUID, page, size int) (schemas.SwipesResponse, int, error)
+	UndoLastSwipe(ctx context.Context, id uuid.UUID) (schemas.SwipeResponse, int, error)
 }
 
 type usersService struct {
-	config config.ServicesConfig
-	client *http.Client
-	logger *log.Logger
+	config  config.ServicesConfig
+	clients HTTPclient.Clients
+	logger  *slog.Logger
 }
 
-func NewUsersService(cfg config.ServicesConfig, logger *log.Logger) UsersService {
-	return usersService{cfg, http.DefaultClient, logger}
+func NewUsersService(cfg config.ServicesConfig, clients HTTPclient.Clients, logger *slog.Logger) UsersService {
+	return usersService{cfg, clients, logger}
 }
 
-func (s usersService) CreateChatForMatch(whoLikedId uuid.UUID, whomLikedId uuid.UUID) (uuid.UUID, int, error) {
-	likeUserUrl := s.config.NotificationService + "/api/v1/chats" + fmt.Sprintf("?user_id1=%v&user_id2=%v", whoLikedId, whomLikedId)
-	s.logger.Print(likeUserUrl)
-	req, err := http.NewRequest("POST", likeUserUrl, nil)
+// CreateChatForMatch opens the chat of the match, the notification service
+// opens it once and responds with 200 instead of 201 when it is open already.
+func (s usersService) CreateChatForMatch(ctx context.Context, matchId uuid.UUID, whoLikedId uuid.UUID, whomLikedId uuid.UUID) (uuid.UUID, int, error) {
+	likeUserUrl := s.config.NotificationService + "/api/v1/chats" +
+		fmt.Sprintf("?match_id=%v&user_id1=%v&user_id2=%v", matchId, whoLikedId, whomLikedId)
+	s.logger.DebugContext(ctx, "calling upstream", "url", likeUserUrl)
+	req, err := http.NewRequestWithContext(ctx, "POST", likeUserUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return uuid.UUID{}, 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Notifications.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get like info, error: %s", err.Error())
-		return uuid.UUID{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get like info", "error", err)
+		return uuid.UUID{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return uuid.UUID{}, resp.StatusCode, s.clients.Notifications.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return uuid.UUID{}, 0, err
 	}
 
 	var id uuid.UUID
 	err = json.Unmarshal(body, &id)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return uuid.UUID{}, 0, err
 	}
 
 	return id, resp.StatusCode, nil
 }
 
-func (s usersService) LikeUser(whoLikedId uuid.UUID, whomLikedId uuid.UUID) (schemas.LikeResponse, int, error) {
+func (s usersService) LikeUser(ctx context.Context, whoLikedId uuid.UUID, whomLikedId uuid.UUID) (schemas.LikeResponse, int, error) {
 	likeUserUrl := s.config.UserService + "/api/v1/users/" + fmt.Sprintf("like/%v?liked=%v", whoLikedId, whomLikedId)
-	s.logger.Print(likeUserUrl)
-	req, err := http.NewRequest("POST", likeUserUrl, nil)
+	s.logger.DebugContext(ctx, "calling upstream", "url", likeUserUrl)
+	req, err := http.NewRequestWithContext(ctx, "POST", likeUserUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.LikeResponse{}, 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Users.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get like info, error: %s", err.Error())
-		return schemas.LikeResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get like info", "error", err)
+		return schemas.LikeResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.LikeResponse{}, resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.LikeResponse{}, 0, err
 	}
 
 	var like schemas.LikeResponse
 	err = json.Unmarshal(body, &like)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.LikeResponse{}, 0, err
 	}
 
 	return like, resp.StatusCode, nil
 }
 
-func (s usersService) UpdateUserInfo(id uuid.UUID, user models.UpdateUserInfo) (int, error) {
+func (s usersService) DislikeUser(ctx context.Context, id uuid.UUID, dislikedId uuid.UUID) (schemas.SwipeResponse, int, error) {
+	dislikeUserUrl := s.config.UserService + "/api/v1/users/" + fmt.Sprintf("dislike/%v?disliked=%v", id, dislikedId)
+	return s.swipe(ctx, "POST", dislikeUserUrl)
+}
+
+func (s usersService) PassUser(ctx context.Context, id uuid.UUID, passedId uuid.UUID) (schemas.SwipeResponse, int, error) {
+	passUserUrl := s.config.UserService + "/api/v1/users/" + fmt.Sprintf("pass/%v?passed=%v", id, passedId)
+	return s.swipe(ctx, "POST", passUserUrl)
+}
+
+func (s usersService) UndoLastSwipe(ctx context.Context, id uuid.UUID) (schemas.SwipeResponse, int, error) {
+	// POST is not retried, a retry would undo the swipe before the last one
+	undoSwipeUrl := s.config.UserService + fmt.Sprintf("/api/v1/users/%v/swipes/undo", id)
+	return s.swipe(ctx, "POST", undoSwipeUrl)
+}
+
+// swipe calls a users service route that responds with a single swipe.
+func (s usersService) swipe(ctx context.Context, method, url string) (schemas.SwipeResponse, int, error) {
+	s.logger.DebugContext(ctx, "calling upstream", "url", url)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.SwipeResponse{}, 0, err
+	}
+
+	resp, err := s.clients.Users.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get swipe info", "error", err)
+		return schemas.SwipeResponse{}, HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.SwipeResponse{}, resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.SwipeResponse{}, 0, err
+	}
+
+	var swipe schemas.SwipeResponse
+	err = json.Unmarshal(body, &swipe)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.SwipeResponse{}, 0, err
+	}
+
+	return swipe, resp.StatusCode, nil
+}
+
+func (s usersService) GetSwipes(ctx context.Context, id uuid.UUID, page, size int) (schemas.SwipesResponse, int, error) {
+	swipesUrl := s.config.UserService + fmt.Sprintf("/api/v1/users/%v/swipes?page=%v&size=%v", id, page, size)
+	s.logger.DebugContext(ctx, "calling upstream", "url", swipesUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", swipesUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.SwipesResponse{}, 0, err
+	}
+
+	resp, err := s.clients.Users.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get swipes", "error", err)
+		return schemas.SwipesResponse{}, HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.SwipesResponse{}, resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.SwipesResponse{}, 0, err
+	}
+
+	var swipes schemas.SwipesResponse
+	err = json.Unmarshal(body, &swipes)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.SwipesResponse{}, 0, err
+	}
+
+	return swipes, resp.StatusCode, nil
+}
+
+func (s usersService) UpdateUserInfo(ctx context.Context, id uuid.UUID, user models.UpdateUserInfo) (int, error) {
 	userServiceUrl := s.config.UserService + fmt.Sprintf("/api/v1/users/%v", id)
-	s.logger.Print(userServiceUrl)
+	s.logger.DebugContext(ctx, "calling upstream", "url", userServiceUrl)
 
 	var userBytes bytes.Buffer
 	err := json.NewEncoder(&userBytes).Encode(user)
 	if err != nil {
-		s.logger.Printf("could not convert to io read user, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not convert to io read user", "error", err)
 		return 0, err
 	}
 
-	req, err := http.NewRequest("PUT", userServiceUrl, &userBytes)
+	req, err := http.NewRequestWithContext(ctx, "PUT", userServiceUrl, &userBytes)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Users.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get user info, error: %s", err.Error())
-		return 0, err
+		s.logger.ErrorContext(ctx, "could not get user info", "error", err)
+		return HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return resp.StatusCode, errors.New("error occurred during updating user info")
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode, s.clients.Users.ResponseError(resp)
 	}
 
 	return 0, nil
 }
 
-func (s usersService) GetUserImage(userId uuid.UUID) (schemas.UserImageResponse, int, error) {
+func (s usersService) GetUserImage(ctx context.Context, userId uuid.UUID) (schemas.UserImageResponse, int, error) {
 	getUserImageByidUrl := s.config.UserService + "/api/v1/users" + fmt.Sprintf("/%v/image", userId)
-	s.logger.Print(getUserImageByidUrl)
-	req, err := http.NewRequest("GET", getUserImageByidUrl, nil)
+	s.logger.DebugContext(ctx, "calling upstream", "url", getUserImageByidUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", getUserImageByidUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.UserImageResponse{}, 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Users.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get user image info, error: %s", err.Error())
-		return schemas.UserImageResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get user image info", "error", err)
+		return schemas.UserImageResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.UserImageResponse{}, resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.UserImageResponse{}, 0, err
 	}
 
 	var userImage schemas.UserImageResponse
 	err = json.Unmarshal(body, &userImage)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.UserImageResponse{}, 0, err
 	}
 
 	return userImage, resp.StatusCode, nil
 }
 
-func (s usersService) AddUser(user schemas.UserRequest) (uuid.UUID, error) {
+func (s usersService) AddUser(ctx context.Context, user schemas.UserRequest) (uuid.UUID, error) {
 	userServiceUrl := s.config.UserService + fmt.Sprintf("/api/v1/users")
-	s.logger.Print(userServiceUrl)
+	s.logger.DebugContext(ctx, "calling upstream", "url", userServiceUrl)
 
 	var userBytes bytes.Buffer
 	err := json.NewEncoder(&userBytes).Encode(user)
 	if err != nil {
-		s.logger.Printf("could not convert to io read user, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not convert to io read user", "error", err)
 		return uuid.UUID{}, err
 	}
 
-	req, err := http.NewRequest("POST", userServiceUrl, &userBytes)
+	req, err := http.NewRequestWithContext(ctx, "POST", userServiceUrl, &userBytes)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return uuid.UUID{}, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Users.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get user info, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not get user info", "error", err)
 		return uuid.UUID{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return uuid.UUID{}, s.clients.Users.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return uuid.UUID{}, err
 	}
 
 	var id uuid.UUID
 	err = json.Unmarshal(body, &id)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return uuid.UUID{}, err
 	}
 
 	return id, nil
 }
 
-func (s usersService) GetUserById(id uuid.UUID) (schemas.UserResponse, int, error) {
+func (s usersService) GetUserById(ctx context.Context, id uuid.UUID) (schemas.UserResponse, int, error) {
 	getUserByidUrl := s.config.UserService + "/api/v1/users" + fmt.Sprintf("/%v", id)
-	s.logger.Print(getUserByidUrl)
-	req, err := http.NewRequest("GET", getUserByidUrl, nil)
+	s.logger.DebugContext(ctx, "calling upstream", "url", getUserByidUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", getUserByidUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.UserResponse{}, 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Users.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get user info, error: %s", err.Error())
-		return schemas.UserResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get user info", "error", err)
+		return schemas.UserResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.UserResponse{}, resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.UserResponse{}, 0, err
 	}
 
 	var user schemas.UserResponse
 	err = json.Unmarshal(body, &user)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.UserResponse{}, 0, err
 	}
 
 	return user, resp.StatusCode, nil
 }
 
-func (s usersService) DeleteUserById(id uuid.UUID) (int, error) {
-	deleteUserByIdUrl := s.config.UserService + fmt.Sprintf("/%v", id)
-	req, err := http.NewRequest("DELETE", deleteUserByIdUrl, nil)
+func (s usersService) DeleteUserById(ctx context.Context, id uuid.UUID) (int, error) {
+	deleteUserByIdUrl := s.config.UserService + "/api/v1/users" + fmt.Sprintf("/%v", id)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", deleteUserByIdUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Users.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get user info, error: %s", err.Error())
-		return 0, err
+		s.logger.ErrorContext(ctx, "could not get user info", "error", err)
+		return HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
 	return resp.StatusCode, nil
 }
 
-func (s usersService) GetAllUsers(page, size int) (schemas.UsersResponse, int, error) {
-	getUsersUrl := s.config.UserService + fmt.Sprintf("/list?page=%v&size=%v", page, size)
-	req, err := http.NewRequest("GET", getUsersUrl, nil)
+func (s usersService) GetAllUsers(ctx context.Context, page, size int) (schemas.UsersResponse, int, error) {
+	getUsersUrl := s.config.UserService + "/api/v1/users" + fmt.Sprintf("/list?page=%v&size=%v", page, size)
+	req, err := http.NewRequestWithContext(ctx, "GET", getUsersUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.UsersResponse{}, 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Users.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get users, error: %s", err.Error())
-		return schemas.UsersResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get users", "error", err)
+		return schemas.UsersResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.UsersResponse{}, resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.UsersResponse{}, 0, err
 	}
 
 	var users schemas.UsersResponse
 	err = json.Unmarshal(body, &users)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.UsersResponse{}, 0, err
 	}
 
 	return users, resp.StatusCode, nil
 }
 
-func (s usersService) GetAllMusics(page, size int) (schemas.MusicsResponse, int, error) {
+func (s usersService) GetAllMusics(ctx context.Context, page, size int) (schemas.MusicsResponse, int, error) {
 	getMusicsUrl := s.config.MusicService + fmt.Sprintf("?page=%v&size=%v", page, size)
-	req, err := http.NewRequest("GET", getMusicsUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", getMusicsUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.MusicsResponse{}, 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	resp, err := s.clients.Music.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get musics, error: %s", err.Error())
-		return schemas.MusicsResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get musics", "error", err)
+		return schemas.MusicsResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.MusicsResponse{}, resp.StatusCode, s.clients.Music.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.MusicsResponse{}, 0, err
 	}
 
 	var musics schemas.MusicsResponse
 	err = json.Unmarshal(body, &musics)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.MusicsResponse{}, 0, err
 	}
 
 	return musics, resp.StatusCode, nil
 }
 
-func (s usersService) GetUserRecommendations(id uuid.UUID) (schemas.UsersResponse, int, error) {
-	getRecommendationsUrl := s.config.UserService + "/api/v1/users" + fmt.Sprintf("/recommendation-list/%v", id)
-	req, err := http.NewRequest("GET", getRecommendationsUrl, nil)
+func (s usersService) AddMusic(ctx context.Context, music schemas.MusicRequest) (int, error) {
+	addMusicUrl := s.config.MusicService + "/"
+
+	var musicBytes bytes.Buffer
+	err := json.NewEncoder(&musicBytes).Encode(music)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
-		return schemas.UsersResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not convert to io read music", "error", err)
+		return 0, err
 	}
 
-	resp, err := s.client.Do(req)
+	req, err := http.NewRequestWithContext(ctx, "POST", addMusicUrl, &musicBytes)
 	if err != nil {
-		s.logger.Printf("could not get recommendations, error: %s", err.Error())
-		return schemas.UsersResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return 0, err
+	}
+
+	resp, err := s.clients.Music.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not add music", "error", err)
+		return HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode, s.clients.Music.ResponseError(resp)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (s usersService) DeleteMusicById(ctx context.Context, id uuid.UUID) (int, error) {
+	deleteMusicUrl := s.config.MusicService + fmt.Sprintf("/%v", id)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", deleteMusicUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return 0, err
+	}
+
+	resp, err := s.clients.Music.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not delete music", "error", err)
+		return HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode, s.clients.Music.ResponseError(resp)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (s usersService) GetUserRecommendations(ctx context.Context, id uuid.UUID, page, size int) (schemas.RecommendationsResponse, int, error) {
+	getRecommendationsUrl := s.config.UserService + "/api/v1/users" + fmt.Sprintf("/recommendation-list/%v?page=%v&size=%v", id, page, size)
+	req, err := http.NewRequestWithContext(ctx, "GET", getRecommendationsUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.RecommendationsResponse{}, 0, err
+	}
+
+	resp, err := s.clients.Users.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get recommendations", "error", err)
+		return schemas.RecommendationsResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.RecommendationsResponse{}, resp.StatusCode, s.clients.Users.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
-		return schemas.UsersResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.RecommendationsResponse{}, 0, err
 	}
 
-	var users schemas.UsersResponse
+	var users schemas.RecommendationsResponse
 	err = json.Unmarshal(body, &users)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
-		return schemas.UsersResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.RecommendationsResponse{}, 0, err
 	}
 
 	return users, resp.StatusCode, nil
@@ -1,193 +1,228 @@
 package gateway
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Feokrat/music-dating-app/gateway/internal/TokenValidator"
+	"github.com/Feokrat/music-dating-app/gateway/internal/middleware"
 	"github.com/Feokrat/music-dating-app/gateway/internal/models"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type handler struct {
-	service           UsersService
-	logger            *log.Logger
-	validationService TokenValidator.ValidationService
+	service UsersService
+	logger  *slog.Logger
 }
 
-func RegisterUsersHandlers(rg *gin.RouterGroup, service UsersService, validationService TokenValidator.ValidationService, logger *log.Logger) {
-	h := handler{service, logger, validationService}
-
-	rg.PUT("/users", h.updateUserById)
-	rg.GET("/users", h.getUserById)
-	rg.DELETE("/users/:id", h.deleteUserById)
-	rg.GET("/users/list", h.getAllUsers)
-	rg.GET("/musics", h.getAllMusic)
-	rg.GET("recommendation-list", h.getUserRecommendations)
-	rg.POST("/users/like/:id", h.LikeUser)
-	rg.POST("/users/dislike", h.DislikeUser)
+func RegisterUsersHandlers(rg *gin.RouterGroup, service UsersService, validationService TokenValidator.ValidationService, logger *slog.Logger) {
+	h := handler{service, logger}
+	authenticated := rg.Group("", middleware.Authenticate(validationService))
+	anonymous := rg.Group("", middleware.AllowAnonymous(validationService))
+	admin := middleware.RequireRole(models.AdminRole)
+
+	authenticated.PUT("/users", h.updateUserById)
+	authenticated.GET("/users", h.getUserById)
+	authenticated.DELETE("/users/:id", admin, h.deleteUserById)
+	authenticated.GET("/users/list", admin, h.getAllUsers)
+	anonymous.GET("/musics", h.getAllMusic)
+	authenticated.POST("/musics", admin, h.addMusic)
+	authenticated.DELETE("/musics/:id", admin, h.deleteMusicById)
+	authenticated.GET("recommendation-list", h.getUserRecommendations)
+	authenticated.POST("/users/like/:id", h.LikeUser)
+	authenticated.POST("/users/dislike/:id", h.DislikeUser)
+	authenticated.POST("/users/pass/:id", h.PassUser)
+	authenticated.GET("/users/swipes", h.getSwipes)
+	// the users service checks the Prime subscription with the payment service
+	authenticated.POST("/users/swipes/undo", h.undoLastSwipe)
 }
 
 func (h handler) LikeUser(ctx *gin.Context) {
-
-	reqToken := ctx.Request.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	reqToken = splitToken[1]
-
-	userId, err := h.validationService.Validate(reqToken)
-
-	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
-
-		return
-	}
+	userId := middleware.UserId(ctx)
 
 	likedUserIdStr := ctx.Param("id")
 	likedId, err := uuid.Parse(likedUserIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			likedUserIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "liked_user_id", likedUserIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
-
-	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+	if likedId == userId {
+		problem.Respond(ctx, problem.InvalidParam("id", errors.New("users can not like themselves")))
 
 		return
 	}
 
-	liked, code, err := h.service.LikeUser(userId, likedId)
+	liked, code, err := h.service.LikeUser(ctx.Request.Context(), userId, likedId)
 	if err != nil {
-		h.logger.Printf("could not create user %v like for, error: %s",
-			userId, likedId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not create user like", "user_id", userId, "liked_id", likedId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
-	if liked.IsMatch == true {
-		chatId1, code, err := h.service.CreateChatForMatch(userId, likedId)
+	// the chat of a match is opened once however often this runs, so every
+	// like of a matched pair makes sure it is open and a like that failed to
+	// open it can be repeated
+	if liked.IsMatch && liked.MatchId != nil {
+		chatId, chatCode, err := h.service.CreateChatForMatch(ctx.Request.Context(), *liked.MatchId, userId, likedId)
 		if err != nil {
-			h.logger.Printf("Error occured during creating new chat %v", code)
+			h.logger.ErrorContext(ctx.Request.Context(), "error occurred during creating new chat", "match_id", liked.MatchId, "status", chatCode, "error", err)
+			problem.Respond(ctx, err)
+
+			return
+		}
+		if chatCode == http.StatusCreated {
+			h.logger.InfoContext(ctx.Request.Context(), "created chat", "match_id", liked.MatchId, "chat_id", chatId)
 		}
-		h.logger.Printf("new chats %v", chatId1)
 	}
 
 	ctx.JSON(code, liked)
-
 }
 
 func (h handler) DislikeUser(ctx *gin.Context) {
-	ctx.JSON(http.StatusOK, schemas.IdResponse{})
+	h.swipeUser(ctx, h.service.DislikeUser)
 }
 
-func (h handler) createEmptyUser(ctx *gin.Context) {
-	var requestModel = schemas.UserRequest{}
-	id, err := h.service.AddUser(requestModel)
+func (h handler) PassUser(ctx *gin.Context) {
+	h.swipeUser(ctx, h.service.PassUser)
+}
+
+// swipeUser handles the swipes other than likes of the caller on the user in
+// the path.
+func (h handler) swipeUser(ctx *gin.Context,
+	swipe func(ctx context.Context, id uuid.UUID, swipedId uuid.UUID) (schemas.SwipeResponse, int, error)) {
+	userId := middleware.UserId(ctx)
+
+	swipedUserIdStr := ctx.Param("id")
+	swipedId, err := uuid.Parse(swipedUserIdStr)
 	if err != nil {
-		h.logger.Printf("could not add user %v, error: %s",
-			requestModel, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "swiped_user_id", swipedUserIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
 		return
 	}
+	if swipedId == userId {
+		problem.Respond(ctx, problem.InvalidParam("id", errors.New("users can not swipe themselves")))
 
-	ctx.Header("Location", fmt.Sprintf("/api/v1/users/%v", id))
-	ctx.JSON(http.StatusCreated, id)
+		return
+	}
+
+	swiped, code, err := swipe(ctx.Request.Context(), userId, swipedId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not swipe user", "user_id", userId, "swiped_id", swipedId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(code, swiped)
 }
 
-func (h handler) updateUserById(ctx *gin.Context) {
+func (h handler) getSwipes(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
 
-	reqToken := ctx.Request.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	reqToken = splitToken[1]
+	pageStr := ctx.Query("page")
+	if pageStr == "" {
+		pageStr = "1"
+	}
 
-	userId, err := h.validationService.Validate(reqToken)
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
+
+		return
+	}
 
+	sizeStr := ctx.Query("size")
+	if sizeStr == "" {
+		sizeStr = "100"
+	}
+	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
 
 		return
 	}
 
-	var requestModel models.UpdateUserInfo
-	if err := ctx.BindJSON(&requestModel); err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong request model",
-			Errors:  err.Error(),
-		})
+	swipes, code, err := h.service.GetSwipes(ctx.Request.Context(), userId, page, size)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get swipes", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+
 		return
 	}
 
-	code, err := h.service.UpdateUserInfo(userId, requestModel)
+	ctx.JSON(code, swipes)
+}
+
+// undoLastSwipe takes back the latest like, dislike or pass of the caller, it
+// is a Prime feature.
+func (h handler) undoLastSwipe(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
 
+	swipe, code, err := h.service.UndoLastSwipe(ctx.Request.Context(), userId)
 	if err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ValidationErrorResponse{
-			Message: "error has occured during updating user info",
-			Errors:  err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not undo last swipe", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
 	}
 
-	ctx.JSON(code, "")
+	ctx.JSON(code, swipe)
 }
 
-func (h handler) getUserById(ctx *gin.Context) {
-	reqToken := ctx.Request.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	reqToken = splitToken[1]
+func (h handler) createEmptyUser(ctx *gin.Context) {
+	var requestModel = schemas.UserRequest{}
+	id, err := h.service.AddUser(ctx.Request.Context(), requestModel)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not add user", "request_model", requestModel, "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
 
-	userId, err := h.validationService.Validate(reqToken)
+	ctx.Header("Location", fmt.Sprintf("/api/v1/users/%v", id))
+	ctx.JSON(http.StatusCreated, id)
+}
 
-	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+func (h handler) updateUserById(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
 
+	var requestModel models.UpdateUserInfo
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	user, code, err := h.service.GetUserById(userId)
+	code, err := h.service.UpdateUserInfo(ctx.Request.Context(), userId, requestModel)
+
 	if err != nil {
-		h.logger.Printf("could not get user %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+
+	ctx.JSON(code, "")
+}
+
+func (h handler) getUserById(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
+
+	user, code, err := h.service.GetUserById(ctx.Request.Context(), userId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -199,23 +234,16 @@ func (h handler) deleteUserById(ctx *gin.Context) {
 	userIdStr := ctx.Param("id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	code, err := h.service.DeleteUserById(userId)
+	code, err := h.service.DeleteUserById(ctx.Request.Context(), userId)
 	if err != nil {
-		h.logger.Printf("could not get user %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -231,10 +259,8 @@ func (h handler) getAllUsers(ctx *gin.Context) {
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil {
-		h.logger.Printf("could not convert page param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "page param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
 
 		return
 	}
@@ -245,21 +271,16 @@ func (h handler) getAllUsers(ctx *gin.Context) {
 	}
 	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
-		h.logger.Printf("could not convert size param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "size param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
 
 		return
 	}
 
-	users, code, err := h.service.GetAllUsers(page, size)
+	users, code, err := h.service.GetAllUsers(ctx.Request.Context(), page, size)
 	if err != nil {
-		h.logger.Printf("could not get users, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get users", "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -275,10 +296,8 @@ func (h handler) getAllMusic(ctx *gin.Context) {
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil {
-		h.logger.Printf("could not convert page param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "page param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
 
 		return
 	}
@@ -289,21 +308,16 @@ func (h handler) getAllMusic(ctx *gin.Context) {
 	}
 	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
-		h.logger.Printf("could not convert size param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "size param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
 
 		return
 	}
 
-	musics, code, err := h.service.GetAllMusics(page, size)
+	musics, code, err := h.service.GetAllMusics(ctx.Request.Context(), page, size)
 	if err != nil {
-		h.logger.Printf("could not get musics, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get musics", "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -311,33 +325,77 @@ func (h handler) getAllMusic(ctx *gin.Context) {
 	ctx.JSON(code, musics)
 }
 
+func (h handler) addMusic(ctx *gin.Context) {
+	var requestModel schemas.MusicRequest
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	code, err := h.service.AddMusic(ctx.Request.Context(), requestModel)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not add music", "request_model", requestModel, "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+
+	ctx.Status(code)
+}
+
+func (h handler) deleteMusicById(ctx *gin.Context) {
+	musicIdStr := ctx.Param("id")
+	musicId, err := uuid.Parse(musicIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse music id", "music_id", musicIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	code, err := h.service.DeleteMusicById(ctx.Request.Context(), musicId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not delete music", "music_id", musicId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.Status(code)
+}
+
 func (h handler) getUserRecommendations(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
 
-	reqToken := ctx.Request.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	reqToken = splitToken[1]
+	pageStr := ctx.Query("page")
+	if pageStr == "" {
+		pageStr = "1"
+	}
 
-	userId, err := h.validationService.Validate(reqToken)
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
 
+		return
+	}
+
+	sizeStr := ctx.Query("size")
+	if sizeStr == "" {
+		sizeStr = "100"
+	}
+	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
 
 		return
 	}
 
-	users, code, err := h.service.GetUserRecommendations(userId)
+	users, code, err := h.service.GetUserRecommendations(ctx.Request.Context(), userId, page, size)
 	if err != nil {
-		h.logger.Printf("could not get recommendation list for id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: fmt.Sprintf("could not get recommendation list for id %v", userId),
-			Errors:  err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get recommendation list", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
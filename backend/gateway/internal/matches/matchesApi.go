@@ -0,0 +1,160 @@
+package matches
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/Feokrat/music-dating-app/gateway/internal/TokenValidator"
+	"github.com/Feokrat/music-dating-app/gateway/internal/middleware"
+	"github.com/Feokrat/music-dating-app/gateway/internal/notifications"
+	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type handler struct {
+	service             MatchService
+	notificationService notifications.NotificationService
+	logger              *slog.Logger
+}
+
+func RegisterMatchHandlers(rg *gin.RouterGroup, service MatchService, notificationService notifications.NotificationService,
+	validator TokenValidator.ValidationService, logger *slog.Logger) {
+	h := handler{service, notificationService, logger}
+	rg.Use(middleware.Authenticate(validator))
+
+	rg.GET("", h.getMatches)
+	rg.DELETE("/:id", h.unmatch)
+	rg.POST("/blocks", h.block)
+}
+
+func (h handler) getMatches(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
+
+	pageStr := ctx.Query("page")
+	if pageStr == "" {
+		pageStr = "1"
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
+
+		return
+	}
+
+	sizeStr := ctx.Query("size")
+	if sizeStr == "" {
+		sizeStr = "100"
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
+
+		return
+	}
+
+	matches, code, err := h.service.GetMatches(ctx.Request.Context(), userId, page, size)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get matches", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(code, matches)
+}
+
+// unmatch ends the match of the caller and archives the chat of the pair.
+func (h handler) unmatch(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
+
+	matchIdStr := ctx.Param("id")
+	matchId, err := uuid.Parse(matchIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse match id", "match_id", matchIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	match, _, err := h.service.GetMatch(ctx.Request.Context(), userId, matchId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get match", "user_id", userId, "match_id", matchId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	// like a block, the chat is archived before the match ends, so that a
+	// failure leaves the match to unmatch again
+	if !h.archiveChats(ctx, userId, match.User.Id) {
+		return
+	}
+
+	match, code, err := h.service.Unmatch(ctx.Request.Context(), userId, matchId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not unmatch", "user_id", userId, "match_id", matchId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(code, match)
+}
+
+// block blocks or reports a user for the caller. Their chat is archived and
+// their match ends.
+func (h handler) block(ctx *gin.Context) {
+	userId := middleware.UserId(ctx)
+
+	var requestModel schemas.BlockRequest
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+	if requestModel.UserId == userId {
+		problem.Respond(ctx, problem.InvalidParam("userId", errors.New("users can not block themselves")))
+		return
+	}
+	if requestModel.Report && strings.TrimSpace(requestModel.Reason) == "" {
+		problem.Respond(ctx, problem.InvalidParam("reason", errors.New("a report needs a reason")))
+		return
+	}
+
+	// the chat is archived before the block, a failure fails the request and
+	// the block, which can be repeated, is retried until the chat is archived
+	if !h.archiveChats(ctx, userId, requestModel.UserId) {
+		return
+	}
+
+	block, code, err := h.service.Block(ctx.Request.Context(), userId, requestModel)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not block user", "user_id", userId, "blocked_id", requestModel.UserId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(code, block)
+}
+
+// archiveChats archives the chats of the pair before their match ends. A
+// failure is responded with and false is returned.
+func (h handler) archiveChats(ctx *gin.Context, userId uuid.UUID, otherId uuid.UUID) bool {
+	code, err := h.notificationService.ArchiveChats(ctx.Request.Context(), userId, otherId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "error occurred during archiving chats", "user_id", userId, "other_id", otherId, "status", code, "error", err)
+		problem.Respond(ctx, err)
+
+		return false
+	}
+
+	return true
+}
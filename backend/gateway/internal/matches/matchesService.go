@@ -0,0 +1,185 @@
+package matches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/Feokrat/music-dating-app/gateway/internal/config"
+	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
+	"github.com/google/uuid"
+)
+
+type MatchService interface {
+	GetMatches(ctx context.Context, userId uuid.UUID, page, size int) (schemas.MatchesResponse, int, error)
+	GetMatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, int, error)
+	Unmatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, int, error)
+	Block(ctx context.Context, userId uuid.UUID, request schemas.BlockRequest) (schemas.BlockResponse, int, error)
+}
+
+type matchService struct {
+	config config.ServicesConfig
+	client *HTTPclient.HTTPclient
+	logger *slog.Logger
+}
+
+func NewMatchService(cfg config.ServicesConfig, clients HTTPclient.Clients, logger *slog.Logger) MatchService {
+	return matchService{cfg, clients.Users, logger}
+}
+
+func (s matchService) GetMatches(ctx context.Context, userId uuid.UUID, page, size int) (schemas.MatchesResponse, int, error) {
+	matchesUrl := s.config.UserService + fmt.Sprintf("/api/v1/matches/%v?page=%v&size=%v", userId, page, size)
+	s.logger.DebugContext(ctx, "calling upstream", "url", matchesUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", matchesUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.MatchesResponse{}, 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get matches", "error", err)
+		return schemas.MatchesResponse{}, HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.MatchesResponse{}, resp.StatusCode, s.client.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.MatchesResponse{}, 0, err
+	}
+
+	var matches schemas.MatchesResponse
+	err = json.Unmarshal(body, &matches)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.MatchesResponse{}, 0, err
+	}
+
+	return matches, resp.StatusCode, nil
+}
+
+func (s matchService) GetMatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, int, error) {
+	matchUrl := s.config.UserService + fmt.Sprintf("/api/v1/matches/%v/%v", userId, matchId)
+	s.logger.DebugContext(ctx, "calling upstream", "url", matchUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", matchUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.MatchResponse{}, 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get match", "error", err)
+		return schemas.MatchResponse{}, HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.MatchResponse{}, resp.StatusCode, s.client.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.MatchResponse{}, 0, err
+	}
+
+	var match schemas.MatchResponse
+	err = json.Unmarshal(body, &match)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.MatchResponse{}, 0, err
+	}
+
+	return match, resp.StatusCode, nil
+}
+
+func (s matchService) Unmatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, int, error) {
+	unmatchUrl := s.config.UserService + fmt.Sprintf("/api/v1/matches/%v/%v", userId, matchId)
+	s.logger.DebugContext(ctx, "calling upstream", "url", unmatchUrl)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", unmatchUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.MatchResponse{}, 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmatch", "error", err)
+		return schemas.MatchResponse{}, HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.MatchResponse{}, resp.StatusCode, s.client.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.MatchResponse{}, 0, err
+	}
+
+	var match schemas.MatchResponse
+	err = json.Unmarshal(body, &match)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.MatchResponse{}, 0, err
+	}
+
+	return match, resp.StatusCode, nil
+}
+
+func (s matchService) Block(ctx context.Context, userId uuid.UUID, request schemas.BlockRequest) (schemas.BlockResponse, int, error) {
+	blockUrl := s.config.UserService + fmt.Sprintf("/api/v1/matches/%v/blocks", userId)
+	s.logger.DebugContext(ctx, "calling upstream", "url", blockUrl)
+
+	var requestBytes bytes.Buffer
+	err := json.NewEncoder(&requestBytes).Encode(request)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not convert to io read block", "error", err)
+		return schemas.BlockResponse{}, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", blockUrl, &requestBytes)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.BlockResponse{}, 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not block user", "error", err)
+		return schemas.BlockResponse{}, HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.BlockResponse{}, resp.StatusCode, s.client.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.BlockResponse{}, 0, err
+	}
+
+	var block schemas.BlockResponse
+	err = json.Unmarshal(body, &block)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.BlockResponse{}, 0, err
+	}
+
+	return block, resp.StatusCode, nil
+}
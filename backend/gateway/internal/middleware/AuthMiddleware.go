@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Feokrat/music-dating-app/gateway/internal/TokenValidator"
+	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	identityKey = "identity"
+	realm       = "music-dating-app"
+)
+
+// Authenticate rejects requests without a valid bearer token and stores the
+// identity of the caller in the context for the handlers down the chain.
+func Authenticate(validator TokenValidator.ValidationService) gin.HandlerFunc {
+	return authenticate(validator, false)
+}
+
+// AllowAnonymous is Authenticate for routes that also serve anonymous
+// callers: requests without an Authorization header pass through without an
+// identity, while a malformed or invalid token is still rejected.
+func AllowAnonymous(validator TokenValidator.ValidationService) gin.HandlerFunc {
+	return authenticate(validator, true)
+}
+
+func authenticate(validator TokenValidator.ValidationService, anonymous bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqToken := c.Request.Header.Get("Authorization")
+		if reqToken == "" && anonymous {
+			c.Next()
+			return
+		}
+
+		if !strings.HasPrefix(reqToken, "Bearer ") {
+			unauthorized(c, "", problem.Unauthorized("missing bearer token"))
+			return
+		}
+
+		identity, err := validator.ValidateIdentity(c.Request.Context(), strings.TrimPrefix(reqToken, "Bearer "))
+		if err != nil {
+			if !errors.Is(err, schemas.TokenError) {
+				problem.Respond(c, err)
+				return
+			}
+			unauthorized(c, "invalid_token", err)
+			return
+		}
+
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
+
+// Identity returns the caller identity stored by Authenticate.
+func Identity(c *gin.Context) (TokenValidator.Identity, bool) {
+	value, ok := c.Get(identityKey)
+	if !ok {
+		return TokenValidator.Identity{}, false
+	}
+	identity, ok := value.(TokenValidator.Identity)
+	return identity, ok
+}
+
+// UserId returns the id of the authenticated caller or uuid.Nil for anonymous
+// requests.
+func UserId(c *gin.Context) uuid.UUID {
+	identity, _ := Identity(c)
+	return identity.UserId
+}
+
+func unauthorized(c *gin.Context, code string, err error) {
+	challenge(c, code)
+	problem.Respond(c, err)
+}
+
+// challenge sets the WWW-Authenticate header as described in RFC 6750.
+func challenge(c *gin.Context, code string) {
+	value := fmt.Sprintf("Bearer realm=%q", realm)
+	if code != "" {
+		value += fmt.Sprintf(", error=%q", code)
+	}
+	c.Header("WWW-Authenticate", value)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole lets the request through only when the caller authenticated
+// by Authenticate has one of the given roles.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := Identity(c)
+		if !ok {
+			unauthorized(c, "", problem.Unauthorized("missing bearer token"))
+			return
+		}
+
+		for _, role := range roles {
+			if identity.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		challenge(c, "insufficient_scope")
+		problem.Respond(c, problem.Forbidden("not enough rights"))
+	}
+}
@@ -6,5 +6,6 @@ type Music struct {
 	Id     uuid.UUID `json:"id"`
 	Name   string    `json:"name"`
 	Author string    `json:"author"`
+	Genre  string    `json:"genre"`
 	Url    string    `json:"url"`
 }
@@ -0,0 +1,7 @@
+package models
+
+const (
+	AdminRole       = "admin"
+	UserRole        = "user"
+	PrimaryUserRole = "primary_user"
+)
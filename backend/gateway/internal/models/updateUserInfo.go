@@ -1,8 +1,12 @@
 package models
 
 type UpdateUserInfo struct {
-	Name        *string `json:"name"`
-	Surname     *string `json:"surname"`
-	Description *string `json:"description"`
-	Image       *string `json:"image"`
+	Name        *string  `json:"name"`
+	Surname     *string  `json:"surname"`
+	Description *string  `json:"description"`
+	BirthDate   *string  `json:"birthDate"`
+	Gender      *string  `json:"gender"`
+	Interests   []string `json:"interests"`
+	City        *string  `json:"city"`
+	Image       *string  `json:"image"`
 }
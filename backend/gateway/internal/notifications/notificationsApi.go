@@ -3,24 +3,25 @@ package notifications
 import (
 	"github.com/Feokrat/music-dating-app/gateway/internal/TokenValidator"
 	"github.com/Feokrat/music-dating-app/gateway/internal/gateway"
+	"github.com/Feokrat/music-dating-app/gateway/internal/middleware"
 	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
 )
 
 type handler struct {
 	service     NotificationService
-	validator   TokenValidator.ValidationService
 	userService gateway.UsersService
-	logger      *log.Logger
+	logger      *slog.Logger
 }
 
 func RegisterChatHandlers(rg *gin.RouterGroup, service NotificationService,
-	validator TokenValidator.ValidationService, usersService gateway.UsersService, logger *log.Logger) {
-	h := handler{service, validator, usersService, logger}
+	validator TokenValidator.ValidationService, usersService gateway.UsersService, logger *slog.Logger) {
+	h := handler{service, usersService, logger}
+	rg.Use(middleware.Authenticate(validator))
 
 	rg.GET("/", h.GetAllChats)
 	rg.GET("/:id", h.GetChatById)
@@ -28,35 +29,18 @@ func RegisterChatHandlers(rg *gin.RouterGroup, service NotificationService,
 }
 
 func (h handler) GetAllChats(ctx *gin.Context) {
-	reqToken := ctx.Request.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	reqToken = splitToken[1]
-
-	userId, err := h.validator.Validate(reqToken)
+	userId := middleware.UserId(ctx)
 
+	chats, code, err := h.service.GetAllChatsByUserId(ctx.Request.Context(), userId)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
-
+		h.logger.ErrorContext(ctx.Request.Context(), "error occurred during getting all chats in gateway", "error", err)
+		problem.Respond(ctx, err)
 		return
 	}
 
-	chats, code, err := h.service.GetAllChatsByUserId(userId)
-	if err != nil {
-		h.logger.Printf("Error occurred during getting all chats in gateway. Error: %v", err.Error())
-		if code == http.StatusNotFound {
-			ctx.JSON(http.StatusNotFound, schemas.ErrorResponse{Message: err.Error()})
-		}
-
-		ctx.JSON(http.StatusInternalServerError, schemas.Error500response{Message: err.Error()})
-	}
-
 	if code == http.StatusNotFound {
 		ctx.JSON(code, schemas.ChatsResponse{})
+		return
 	}
 
 	var chatsResponse schemas.ChatsResponse
@@ -71,12 +55,12 @@ func (h handler) GetAllChats(ctx *gin.Context) {
 		} else {
 			UserID = chats.Chats[i].UserId2
 		}
-		user, code, err := h.userService.GetUserById(UserID)
+		user, code, err := h.userService.GetUserById(ctx.Request.Context(), UserID)
 		if err != nil {
 			if code == http.StatusNotFound {
-				h.logger.Printf("Image for user %v was not found", userId)
+				h.logger.InfoContext(ctx.Request.Context(), "image for user was not found", "user_id", userId)
 			}
-			h.logger.Printf("Error occured during getting image for user %v ", userId)
+			h.logger.ErrorContext(ctx.Request.Context(), "error occurred during getting image for user", "user_id", userId)
 		} else {
 			chatModel.User.Image = user.Image
 			chatModel.User.Name = user.Name
@@ -89,44 +73,21 @@ func (h handler) GetAllChats(ctx *gin.Context) {
 }
 
 func (h handler) GetChatById(ctx *gin.Context) {
-	reqToken := ctx.Request.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	reqToken = splitToken[1]
-
-	userId, err := h.validator.Validate(reqToken)
-
-	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
-
-		return
-	}
+	userId := middleware.UserId(ctx)
 
 	chatIdStr := ctx.Param("id")
 	chatId, err := uuid.Parse(chatIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse chat id %v, error: %s",
-			chatIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse chat id", "chat_id", chatIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	messages, code, err := h.service.GetMessagesByChatId(chatId)
+	messages, code, err := h.service.GetMessagesByChatId(ctx.Request.Context(), chatId, userId)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			chatIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get messages of chat", "chat_id", chatIdStr, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -148,31 +109,12 @@ func (h handler) GetChatById(ctx *gin.Context) {
 }
 
 func (h handler) CreateMessageInChat(ctx *gin.Context) {
-	reqToken := ctx.Request.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	reqToken = splitToken[1]
-
-	userId, err := h.validator.Validate(reqToken)
-
-	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
-
-		return
-	}
+	userId := middleware.UserId(ctx)
 
 	var messageFrontRequest schemas.MessageFrontRequest
-	if err := ctx.BindJSON(&messageFrontRequest); err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong request model",
-			Errors:  err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&messageFrontRequest); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 	var messageRequest schemas.MessageRequest
@@ -180,14 +122,11 @@ func (h handler) CreateMessageInChat(ctx *gin.Context) {
 	messageRequest.ChatId = messageFrontRequest.ChatId
 	messageRequest.UserId = userId
 
-	messageId, code, err := h.service.CreateMessageForChat(messageRequest)
+	messageId, code, err := h.service.CreateMessageForChat(ctx.Request.Context(), messageRequest)
 	if err != nil {
-		h.logger.Printf("Error occurred during getting all chats in gateway. Error: %v", err.Error())
-		if code == http.StatusNotFound {
-			ctx.JSON(http.StatusNotFound, schemas.ErrorResponse{Message: err.Error()})
-		}
-
-		ctx.JSON(http.StatusInternalServerError, schemas.Error500response{Message: err.Error()})
+		h.logger.ErrorContext(ctx.Request.Context(), "error occurred during getting all chats in gateway", "error", err)
+		problem.Respond(ctx, err)
+		return
 	}
 
 	ctx.JSON(code, messageId)
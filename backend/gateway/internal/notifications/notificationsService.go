@@ -2,147 +2,180 @@ package notifications
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Feokrat/music-dating-app/gateway/internal/config"
 	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
 	"github.com/google/uuid"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 type NotificationService interface {
-	GetAllChatsByUserId(userId uuid.UUID) (schemas.ChatsNotiResponse, int, error)
-	GetMessagesByChatId(chatId uuid.UUID) (schemas.MessageNotiResponse, int, error)
-	CreateMessageForChat(request schemas.MessageRequest) (uuid.UUID, int, error)
+	GetAllChatsByUserId(ctx context.Context, userId uuid.UUID) (schemas.ChatsNotiResponse, int, error)
+	GetMessagesByChatId(ctx context.Context, chatId uuid.UUID, userId uuid.UUID) (schemas.MessageNotiResponse, int, error)
+	CreateMessageForChat(ctx context.Context, request schemas.MessageRequest) (uuid.UUID, int, error)
+	ArchiveChats(ctx context.Context, userId1 uuid.UUID, userId2 uuid.UUID) (int, error)
 }
 
 type notificationService struct {
 	config config.ServicesConfig
-	client *http.Client
-	logger *log.Logger
+	client *HTTPclient.HTTPclient
+	logger *slog.Logger
 }
 
-func NewNotificationService(cfg config.ServicesConfig, logger *log.Logger) NotificationService {
-	return notificationService{cfg, http.DefaultClient, logger}
+func NewNotificationService(cfg config.ServicesConfig, clients HTTPclient.Clients, logger *slog.Logger) NotificationService {
+	return notificationService{cfg, clients.Notifications, logger}
 }
 
-func (s notificationService) CreateMessageForChat(request schemas.MessageRequest) (uuid.UUID, int, error) {
+func (s notificationService) CreateMessageForChat(ctx context.Context, request schemas.MessageRequest) (uuid.UUID, int, error) {
 	messagesUrl := s.config.NotificationService + "/api/v1/messages/"
-	s.logger.Print(messagesUrl)
+	s.logger.DebugContext(ctx, "calling upstream", "url", messagesUrl)
 
 	var messageBytes bytes.Buffer
 	err := json.NewEncoder(&messageBytes).Encode(request)
 	if err != nil {
-		s.logger.Printf("could not convert to io read messages, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not convert to io read messages", "error", err)
 		return uuid.UUID{}, 0, err
 	}
 
-	req, err := http.NewRequest("POST", messagesUrl, &messageBytes)
+	req, err := http.NewRequestWithContext(ctx, "POST", messagesUrl, &messageBytes)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return uuid.UUID{}, 0, err
 	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get messages info, error: %s", err.Error())
-		return uuid.UUID{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get messages info", "error", err)
+		return uuid.UUID{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
-		return uuid.UUID{}, 0, err
+	if resp.StatusCode >= http.StatusBadRequest {
+		return uuid.UUID{}, resp.StatusCode, s.client.ResponseError(resp)
 	}
 
-	if resp.StatusCode == http.StatusNotFound {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return uuid.UUID{}, 0, err
 	}
 
 	var messageId uuid.UUID
 	err = json.Unmarshal(body, &messageId)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return uuid.UUID{}, 0, err
 	}
 
 	return messageId, resp.StatusCode, nil
 }
 
-func (s notificationService) GetMessagesByChatId(chatId uuid.UUID) (schemas.MessageNotiResponse, int, error) {
-	chatsUrl := s.config.NotificationService + "/api/v1/messages/chat/" + fmt.Sprintf("%v", chatId)
-	s.logger.Print(chatsUrl)
-	req, err := http.NewRequest("GET", chatsUrl, nil)
+// GetMessagesByChatId lists the messages of the chat, the chats of other users
+// are not found.
+func (s notificationService) GetMessagesByChatId(ctx context.Context, chatId uuid.UUID, userId uuid.UUID) (schemas.MessageNotiResponse, int, error) {
+	chatsUrl := s.config.NotificationService + "/api/v1/messages/chat/" + fmt.Sprintf("%v?user_id=%v", chatId, userId)
+	s.logger.DebugContext(ctx, "calling upstream", "url", chatsUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", chatsUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.MessageNotiResponse{}, 0, err
 	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get chats info, error: %s", err.Error())
-		return schemas.MessageNotiResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get chats info", "error", err)
+		return schemas.MessageNotiResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return schemas.MessageNotiResponse{}, resp.StatusCode, nil
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.MessageNotiResponse{}, resp.StatusCode, s.client.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.MessageNotiResponse{}, 0, err
 	}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return schemas.MessageNotiResponse{}, resp.StatusCode, nil
-	}
-
 	var messages schemas.MessageNotiResponse
 	err = json.Unmarshal(body, &messages)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.MessageNotiResponse{}, 0, err
 	}
 
 	return messages, resp.StatusCode, nil
 }
 
-func (s notificationService) GetAllChatsByUserId(userId uuid.UUID) (schemas.ChatsNotiResponse, int, error) {
+func (s notificationService) GetAllChatsByUserId(ctx context.Context, userId uuid.UUID) (schemas.ChatsNotiResponse, int, error) {
 	chatsUrl := s.config.NotificationService + "/api/v1/chats/" + fmt.Sprintf("%v", userId)
-	s.logger.Print(chatsUrl)
-	req, err := http.NewRequest("GET", chatsUrl, nil)
+	s.logger.DebugContext(ctx, "calling upstream", "url", chatsUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", chatsUrl, nil)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.ChatsNotiResponse{}, 0, err
 	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get chats info, error: %s", err.Error())
-		return schemas.ChatsNotiResponse{}, 0, err
+		s.logger.ErrorContext(ctx, "could not get chats info", "error", err)
+		return schemas.ChatsNotiResponse{}, HTTPclient.ResponseStatus(0, err), err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return schemas.ChatsNotiResponse{}, resp.StatusCode, nil
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return schemas.ChatsNotiResponse{}, resp.StatusCode, s.client.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.ChatsNotiResponse{}, 0, err
 	}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return schemas.ChatsNotiResponse{}, resp.StatusCode, nil
-	}
-
 	var chats schemas.ChatsNotiResponse
 	err = json.Unmarshal(body, &chats)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.ChatsNotiResponse{}, 0, err
 	}
 
 	return chats, resp.StatusCode, nil
 }
+
+// ArchiveChats hides the chats between the users after they unmatched or one
+// blocked the other.
+func (s notificationService) ArchiveChats(ctx context.Context, userId1 uuid.UUID, userId2 uuid.UUID) (int, error) {
+	archiveUrl := s.config.NotificationService + "/api/v1/chats/archive" + fmt.Sprintf("?user_id1=%v&user_id2=%v", userId1, userId2)
+	s.logger.DebugContext(ctx, "calling upstream", "url", archiveUrl)
+	req, err := http.NewRequestWithContext(ctx, "PUT", archiveUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not archive chats", "error", err)
+		return HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode, s.client.ResponseError(resp)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,281 @@
+package schemas
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/google/uuid"
+)
+
+// contract pins a payload the gateway exchanges to the schema the owner of
+// the payload documents for it: responses of a backend are checked against
+// its document, and the public responses of the gateway against its own.
+type contract struct {
+	spec   string
+	method string
+	path   string
+	// response status, empty to check the request body
+	status string
+	value  interface{}
+}
+
+var contracts = []contract{
+	{"users", "GET", "/api/v1/users/{id}", "200", UserResponse{}},
+	{"users", "GET", "/api/v1/users/list", "200", UsersResponse{}},
+	{"users", "GET", "/api/v1/users/recommendation-list/{id}", "200", RecommendationsResponse{}},
+	{"users", "GET", "/api/v1/users/{id}/image", "200", UserImageResponse{}},
+	{"users", "POST", "/api/v1/users/like/{id}", "200", LikeResponse{}},
+	{"users", "POST", "/api/v1/users/dislike/{id}", "201", SwipeResponse{}},
+	{"users", "POST", "/api/v1/users/pass/{id}", "201", SwipeResponse{}},
+	{"users", "GET", "/api/v1/users/{id}/swipes", "200", SwipesResponse{}},
+	{"users", "POST", "/api/v1/users/{id}/swipes/undo", "200", SwipeResponse{}},
+	{"users", "GET", "/api/v1/matches/{id}", "200", MatchesResponse{}},
+	{"users", "GET", "/api/v1/matches/{id}/{match_id}", "200", MatchResponse{}},
+	{"users", "DELETE", "/api/v1/matches/{id}/{match_id}", "200", MatchResponse{}},
+	{"users", "POST", "/api/v1/matches/{id}/blocks", "201", BlockResponse{}},
+	{"users", "POST", "/api/v1/matches/{id}/blocks", "", BlockRequest{}},
+	{"users", "GET", "/api/v1/musics/", "200", MusicsResponse{}},
+	{"users", "POST", "/api/v1/users/", "", UserRequest{}},
+	{"users", "POST", "/api/v1/musics/", "", MusicRequest{}},
+	{"notifications", "GET", "/api/v1/chats/{user_id}", "200", ChatsNotiResponse{}},
+	{"notifications", "GET", "/api/v1/messages/chat/{id}", "201", MessageNotiResponse{}},
+	{"notifications", "POST", "/api/v1/messages", "", MessageRequest{}},
+	{"sessions", "POST", "/auth/sign-in", "200", TokenResponse{}},
+	{"sessions", "GET", "/auth/sessions", "200", struct {
+		Data []SessionResponse `json:"data"`
+	}{}},
+	{"gateway", "GET", "/api/v1/users", "200", UserResponse{}},
+	{"gateway", "GET", "/api/v1/users/list", "200", UsersResponse{}},
+	{"gateway", "GET", "/api/v1/recommendation-list", "200", RecommendationsResponse{}},
+	{"gateway", "POST", "/api/v1/users/like/{id}", "200", LikeResponse{}},
+	{"gateway", "POST", "/api/v1/users/dislike/{id}", "201", SwipeResponse{}},
+	{"gateway", "POST", "/api/v1/users/pass/{id}", "201", SwipeResponse{}},
+	{"gateway", "GET", "/api/v1/users/swipes", "200", SwipesResponse{}},
+	{"gateway", "POST", "/api/v1/users/swipes/undo", "200", SwipeResponse{}},
+	{"gateway", "GET", "/api/v1/musics", "200", MusicsResponse{}},
+	{"gateway", "POST", "/api/v1/musics", "", MusicRequest{}},
+	{"gateway", "GET", "/api/v1/matches", "200", MatchesResponse{}},
+	{"gateway", "DELETE", "/api/v1/matches/{id}", "200", MatchResponse{}},
+	{"gateway", "POST", "/api/v1/matches/blocks", "201", BlockResponse{}},
+	{"gateway", "POST", "/api/v1/matches/blocks", "", BlockRequest{}},
+	{"gateway", "GET", "/api/v1/chats/", "200", ChatsResponse{}},
+	{"gateway", "GET", "/api/v1/chats/{id}", "200", MessageResponse{}},
+	{"gateway", "POST", "/api/v1/chats/sendMessage", "", MessageFrontRequest{}},
+	{"gateway", "POST", "/api/v1/sessions/login", "200", TokenResponse{}},
+	{"gateway", "GET", "/api/v1/sessions", "200", SessionsResponse{}},
+}
+
+func TestContracts(t *testing.T) {
+	docs := map[string]*openapi3.T{}
+	for _, c := range contracts {
+		doc, ok := docs[c.spec]
+		if !ok {
+			doc = loadSpec(t, c.spec)
+			docs[c.spec] = doc
+		}
+
+		name := c.spec + " " + c.method + " " + c.path + " " + c.status
+		t.Run(name, func(t *testing.T) {
+			schema := payloadSchema(t, doc, c)
+			checkSchema(t, reflect.TypeOf(c.value), schema, reflect.TypeOf(c.value).Name())
+		})
+	}
+}
+
+func loadSpec(t *testing.T, service string) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromFile(filepath.Join("..", "..", "..", service, "api", "openapi.yaml"))
+	if err != nil {
+		t.Fatalf("failed to load OpenAPI document of %s: %v", service, err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("invalid OpenAPI document of %s: %v", service, err)
+	}
+	return doc
+}
+
+func payloadSchema(t *testing.T, doc *openapi3.T, c contract) *openapi3.Schema {
+	t.Helper()
+	item := doc.Paths.Find(c.path)
+	if item == nil {
+		t.Fatalf("path is not documented")
+	}
+	operation := item.GetOperation(c.method)
+	if operation == nil {
+		t.Fatalf("method is not documented")
+	}
+
+	var content openapi3.Content
+	if c.status == "" {
+		if operation.RequestBody == nil {
+			t.Fatalf("request body is not documented")
+		}
+		content = operation.RequestBody.Value.Content
+	} else {
+		response := operation.Responses.Value(c.status)
+		if response == nil {
+			t.Fatalf("response is not documented")
+		}
+		content = response.Value.Content
+	}
+
+	media := content.Get("application/json")
+	if media == nil || media.Schema == nil {
+		t.Fatalf("JSON payload is not documented")
+	}
+	return media.Schema.Value
+}
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// checkSchema reports every JSON field of typ that the schema does not
+// document or documents with another type.
+func checkSchema(t *testing.T, typ reflect.Type, schema *openapi3.Schema, field string) {
+	t.Helper()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	want := jsonType(typ)
+	if want == "" {
+		return
+	}
+	if got := schemaType(schema); got != "" && got != want {
+		t.Errorf("%s: schema type is %s, client expects %s", field, got, want)
+		return
+	}
+
+	switch want {
+	case "array":
+		if schema.Items != nil {
+			checkSchema(t, typ.Elem(), schema.Items.Value, field+"[]")
+		}
+	case "object":
+		properties := schemaProperties(schema)
+		for _, f := range jsonFields(typ) {
+			name, _ := jsonName(f)
+			property := lookupProperty(properties, name)
+			if property == nil {
+				t.Errorf("%s.%s: field is not documented", field, name)
+				continue
+			}
+			checkSchema(t, f.Type, property, field+"."+name)
+		}
+	}
+}
+
+// jsonFields lists the fields encoding/json encodes typ with, the fields
+// of embedded structs are promoted.
+func jsonFields(typ reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		if _, ok := jsonName(f); ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func jsonType(typ reflect.Type) string {
+	switch {
+	case typ == uuidType || typ == timeType:
+		return "string"
+	case typ.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()):
+		return ""
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct:
+		return "object"
+	}
+	return ""
+}
+
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
+
+// schemaType is the type of the schema, or of all its parts when it is a
+// oneOf or an allOf.
+func schemaType(schema *openapi3.Schema) string {
+	if types := schema.Type.Slice(); len(types) == 1 {
+		return types[0]
+	}
+	var typ string
+	for _, alternative := range parts(schema) {
+		alternativeType := schemaType(alternative.Value)
+		if typ != "" && alternativeType != typ {
+			return ""
+		}
+		typ = alternativeType
+	}
+	return typ
+}
+
+// schemaProperties merges the properties of allOf parts and of oneOf
+// alternatives, a client struct decodes any of them.
+func schemaProperties(schema *openapi3.Schema) openapi3.Schemas {
+	properties := openapi3.Schemas{}
+	for name, property := range schema.Properties {
+		properties[name] = property
+	}
+	for _, part := range parts(schema) {
+		for name, property := range schemaProperties(part.Value) {
+			properties[name] = property
+		}
+	}
+	return properties
+}
+
+func parts(schema *openapi3.Schema) openapi3.SchemaRefs {
+	refs := make(openapi3.SchemaRefs, 0, len(schema.OneOf)+len(schema.AllOf))
+	return append(append(refs, schema.OneOf...), schema.AllOf...)
+}
+
+// lookupProperty matches names the way encoding/json does, case-insensitively.
+func lookupProperty(properties openapi3.Schemas, name string) *openapi3.Schema {
+	if property, ok := properties[name]; ok {
+		return property.Value
+	}
+	for key, property := range properties {
+		if strings.EqualFold(key, name) {
+			return property.Value
+		}
+	}
+	return nil
+}
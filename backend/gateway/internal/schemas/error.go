@@ -1,10 +1,12 @@
 package schemas
 
-import "errors"
+import (
+	"net/http"
+
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
+)
 
 var (
-	RightsError             = errors.New("session doesn't belong to user")
-	UserAlreadyExistsError  = errors.New("user with specified login already exists")
-	InvalidCredentialsError = errors.New("invalid login or password")
-	TokenError              = errors.New("invalid token")
+	RightsError = problem.Forbidden("session doesn't belong to user")
+	TokenError  = problem.New(http.StatusUnauthorized, "invalid_token", "invalid token")
 )
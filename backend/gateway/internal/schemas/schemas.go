@@ -7,11 +7,6 @@ import (
 	"time"
 )
 
-type Error500response struct {
-	Message string
-	Code    int
-}
-
 type Success200response struct {
 	Message string
 	Code    int
@@ -34,8 +29,12 @@ type ChatResponse struct {
 	IsRead      bool             `json:"isRead"`
 }
 
+// LikeResponse tells whether the users matched, IsNewMatch is only set for
+// the like that made the match.
 type LikeResponse struct {
-	IsMatch bool
+	IsMatch    bool
+	MatchId    *uuid.UUID `json:"matchId,omitempty"`
+	IsNewMatch bool       `json:"isNewMatch"`
 }
 
 type ChatsNotiResponse struct {
@@ -102,20 +101,84 @@ type ChatsModel struct {
 	IsRead      bool      `json:"isRead"`
 }
 
+// TokenResponse carries either a token pair or, with status "mfa_required",
+// a challenge token to complete the sign in with.
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token          string `json:"token,omitempty"`
+	RefreshToken   string `json:"refreshToken,omitempty"`
+	ExpiresIn      int64  `json:"expiresIn"`
+	Status         string `json:"status,omitempty"`
+	ChallengeToken string `json:"challengeToken,omitempty"`
+}
+
+type SessionResponse struct {
+	Id         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+type SessionsResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
 }
 
 type idResponse struct {
 	ID interface{} `json:"id"`
 }
 
-type IdResponse struct {
-	ID uuid.UUID `json:"id"`
+// SwipeResponse is a like, dislike or pass given to the user UserId. Passes
+// expire, the user is recommended again after ExpiresAt.
+type SwipeResponse struct {
+	Id        uuid.UUID  `json:"id"`
+	UserId    uuid.UUID  `json:"userId"`
+	Kind      string     `json:"kind"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type SwipesResponse struct {
+	Swipes []SwipeResponse `json:"swipes"`
+}
+
+// MatchUserResponse is the preview of the profile of the other user of a
+// match.
+type MatchUserResponse struct {
+	Id      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Surname string    `json:"surname"`
+	Image   string    `json:"image"`
+}
+
+type MatchResponse struct {
+	Id        uuid.UUID         `json:"id"`
+	CreatedAt time.Time         `json:"createdAt"`
+	User      MatchUserResponse `json:"user"`
+}
+
+type MatchesResponse struct {
+	Matches []MatchResponse `json:"matches"`
 }
 
-type messageResponse struct {
-	Message string `json:"message"`
+// BlockRequest blocks the user UserId, a report also needs the reason.
+type BlockRequest struct {
+	UserId uuid.UUID `json:"userId" binding:"required"`
+	Reason string    `json:"reason" binding:"max=1000"`
+	Report bool      `json:"report"`
+}
+
+type BlockResponse struct {
+	Id        uuid.UUID `json:"id"`
+	UserId    uuid.UUID `json:"userId"`
+	Reason    string    `json:"reason"`
+	Reported  bool      `json:"reported"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type IdResponse struct {
+	ID uuid.UUID `json:"id"`
 }
 
 type UserRequest struct {
@@ -126,10 +189,6 @@ type UserRequest struct {
 	HasAccess   bool   `json:"hasAccess"`
 }
 
-func RespondWithError(c *gin.Context, statusCode int, message string) {
-	c.AbortWithStatusJSON(statusCode, messageResponse{message})
-}
-
 func RespondWithData(c *gin.Context, statusCode int, data interface{}) {
 	c.JSON(statusCode, dataResponse{data})
 }
@@ -142,20 +201,15 @@ func RespondWithToken(c *gin.Context, statusCode int, token string) {
 	c.JSON(statusCode, idResponse{token})
 }
 
-type ValidationErrorResponse struct {
-	Message string `json:"message"`
-	Errors  string `json:"errors"`
-}
-
-type ErrorResponse struct {
-	Message string `json:"message"`
-}
-
 type UserResponse struct {
 	Id               uuid.UUID `json:"id"`
 	Name             string    `json:"name"`
 	Surname          string    `json:"surname"`
 	Description      string    `json:"description"`
+	BirthDate        string    `json:"birthDate,omitempty"`
+	Gender           string    `json:"gender"`
+	Interests        []string  `json:"interests"`
+	City             string    `json:"city"`
 	Image            string    `json:"image"`
 	SubscriptionType int       `json:"subscriptionType"`
 	MusicIds         []string  `json:"musicIds"`
@@ -166,6 +220,10 @@ type UserResponseDto struct {
 	Name             string    `json:"name"`
 	Surname          string    `json:"surname"`
 	Description      string    `json:"description"`
+	BirthDate        string    `json:"birthDate,omitempty"`
+	Gender           string    `json:"gender"`
+	Interests        []string  `json:"interests"`
+	City             string    `json:"city"`
 	Image            string    `json:"image"`
 	SubscriptionType int       `json:"subscriptionType"`
 	MusicIds         []string  `json:"musicIds"`
@@ -175,6 +233,25 @@ type UsersResponse struct {
 	Users []UserResponse `json:"users"`
 }
 
+// RecommendationResponse is a recommended user with its compatibility score
+// in percent and why it was recommended.
+type RecommendationResponse struct {
+	UserResponse
+	Score       int    `json:"score"`
+	Explanation string `json:"explanation"`
+}
+
+type RecommendationsResponse struct {
+	Users []RecommendationResponse `json:"users"`
+}
+
+type MusicRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Author string `json:"author" binding:"required"`
+	Genre  string `json:"genre"`
+	Url    string `json:"url" binding:"required"`
+}
+
 type MusicsResponse struct {
 	Musics []models.Music `json:"musics"`
 }
@@ -11,4 +11,37 @@ type Register struct {
 	UserId   uuid.UUID `json:"user_id"`
 	Login    string    `json:"login" bson:"login"`
 	Password string    `json:"password" bson:"password"`
+	Email    string    `json:"email"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type Refresh struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// Device is the client a session is opened for, forwarded to the sessions service.
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+type MFACode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAVerify struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
 }
@@ -1,31 +1,38 @@
 package session
 
 import (
+	"errors"
+	"github.com/Feokrat/music-dating-app/gateway/internal/TokenValidator"
 	"github.com/Feokrat/music-dating-app/gateway/internal/gateway"
 	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
 	"github.com/Feokrat/music-dating-app/gateway/internal/session/models"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
 	"github.com/gin-gonic/gin"
-	"log"
+	"github.com/google/uuid"
+	"log/slog"
 	"net/http"
+	"strings"
 )
 
 type handler struct {
-	logger      *log.Logger
+	logger      *slog.Logger
 	service     SessionService
 	userService gateway.UsersService
+	validator   TokenValidator.ValidationService
 }
 
 func (h handler) Authorize(ctx *gin.Context) {
 	var userCredentials models.Auth
 	if err := ctx.ShouldBindJSON(&userCredentials); err != nil {
-		schemas.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	answ, err := h.service.Authorize(userCredentials)
+	answ, err := h.service.Authorize(ctx.Request.Context(), userCredentials, device(ctx))
 	if err != nil {
-		h.logger.Println("authorization failed for %v", userCredentials.Login)
-		ctx.JSON(http.StatusUnauthorized, schemas.Error500response{Message: "authorization failed for user", Code: 500})
+		h.logger.WarnContext(ctx.Request.Context(), "authorization failed", "login", userCredentials.Login)
+		problem.Respond(ctx, err)
+		return
 	}
 
 	ctx.JSON(http.StatusOK, answ)
@@ -34,33 +41,304 @@ func (h handler) Authorize(ctx *gin.Context) {
 func (h handler) Register(ctx *gin.Context) {
 	var userCredentials models.Register
 	if err := ctx.ShouldBindJSON(&userCredentials); err != nil {
-		schemas.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	var user = schemas.UserRequest{}
-	id, err := h.userService.AddUser(user)
+	var user = schemas.UserRequest{Email: userCredentials.Email}
+	id, err := h.userService.AddUser(ctx.Request.Context(), user)
 	if err != nil {
-		h.logger.Printf("could not add user %v, error: %s",
-			user, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not add user", "email", user.Email, "error", err)
+		problem.Respond(ctx, err)
 		return
 	}
 	userCredentials.UserId = id
 
-	answ, err := h.service.Register(userCredentials)
+	answ, err := h.service.Register(ctx.Request.Context(), userCredentials, device(ctx))
 	if err != nil {
-		h.logger.Println("registration failed for %v", userCredentials.Login)
-		ctx.JSON(http.StatusUnauthorized, schemas.Error500response{Message: "registration failed for user", Code: 500})
+		h.logger.WarnContext(ctx.Request.Context(), "registration failed", "login", userCredentials.Login)
+		if rejected(err) {
+			h.deleteUser(ctx, id)
+		}
+		problem.Respond(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, answ)
+}
+
+// deleteUser removes the user added for a registration the sessions service
+// rejected, so that the email can be registered again.
+func (h handler) deleteUser(ctx *gin.Context, id uuid.UUID) {
+	if _, err := h.userService.DeleteUserById(ctx.Request.Context(), id); err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not delete user of rejected registration",
+			"user_id", id, "error", err)
+	}
+}
+
+// rejected tells a request the upstream refused apart from a failure, after
+// which the upstream may have done its part.
+func rejected(err error) bool {
+	var p *problem.Problem
+	return errors.As(err, &p) && p.Status < http.StatusInternalServerError
+}
+
+func (h handler) Refresh(ctx *gin.Context) {
+	var refresh models.Refresh
+	if err := ctx.ShouldBindJSON(&refresh); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	answ, err := h.service.Refresh(ctx.Request.Context(), refresh)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "token refresh failed", "error", err)
+		problem.Respond(ctx, err)
+		return
 	}
 
 	ctx.JSON(http.StatusOK, answ)
 }
 
-func RegisterAuthHandlers(rg *gin.RouterGroup, service SessionService, logger *log.Logger, userService gateway.UsersService) {
-	h := handler{logger: logger, service: service, userService: userService}
+func (h handler) Logout(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	_, err := h.service.Logout(ctx.Request.Context(), token)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "logout failed", "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+	h.validator.Invalidate(token)
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h handler) GetSessions(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	sessions, _, err := h.service.GetSessions(ctx.Request.Context(), token)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get sessions", "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func (h handler) RevokeSession(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	sessionIdStr := ctx.Param("id")
+	sessionId, err := uuid.Parse(sessionIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse session id", "session_id", sessionIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+		return
+	}
+
+	_, err = h.service.RevokeSession(ctx.Request.Context(), token, sessionId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not revoke session", "session_id", sessionId, "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+	h.invalidateUser(ctx, token)
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h handler) RevokeAllSessions(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	_, err := h.service.RevokeAllSessions(ctx.Request.Context(), token)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not revoke sessions", "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+	h.invalidateUser(ctx, token)
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h handler) VerifyEmail(ctx *gin.Context) {
+	var verification models.VerifyEmail
+	if err := ctx.ShouldBindJSON(&verification); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	code, err := h.service.VerifyEmail(ctx.Request.Context(), verification)
+	h.respondWithStatus(ctx, code, err)
+}
+
+func (h handler) ResendVerification(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	code, err := h.service.ResendVerification(ctx.Request.Context(), token)
+	h.respondWithStatus(ctx, code, err)
+}
+
+func (h handler) ForgotPassword(ctx *gin.Context) {
+	var forgot models.ForgotPassword
+	if err := ctx.ShouldBindJSON(&forgot); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	code, err := h.service.ForgotPassword(ctx.Request.Context(), forgot)
+	h.respondWithStatus(ctx, code, err)
+}
+
+func (h handler) ResetPassword(ctx *gin.Context) {
+	var reset models.ResetPassword
+	if err := ctx.ShouldBindJSON(&reset); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	code, err := h.service.ResetPassword(ctx.Request.Context(), reset)
+	h.respondWithStatus(ctx, code, err)
+}
+
+func (h handler) VerifyMFA(ctx *gin.Context) {
+	var verification models.MFAVerify
+	if err := ctx.ShouldBindJSON(&verification); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	answ, code, err := h.service.VerifyMFA(ctx.Request.Context(), verification, device(ctx))
+	if err != nil {
+		h.respondWithStatus(ctx, code, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, answ)
+}
+
+func (h handler) EnrollMFA(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	body, code, err := h.service.EnrollMFA(ctx.Request.Context(), token)
+	if err != nil {
+		h.respondWithStatus(ctx, code, err)
+		return
+	}
+
+	ctx.Data(code, "application/json; charset=utf-8", body)
+}
+
+func (h handler) ConfirmMFA(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	var mfaCode models.MFACode
+	if err := ctx.ShouldBindJSON(&mfaCode); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	body, code, err := h.service.ConfirmMFA(ctx.Request.Context(), token, mfaCode)
+	if err != nil {
+		h.respondWithStatus(ctx, code, err)
+		return
+	}
+
+	ctx.Data(code, "application/json; charset=utf-8", body)
+}
+
+func (h handler) DisableMFA(ctx *gin.Context) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return
+	}
+
+	var mfaCode models.MFACode
+	if err := ctx.ShouldBindJSON(&mfaCode); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	code, err := h.service.DisableMFA(ctx.Request.Context(), token, mfaCode)
+	h.respondWithStatus(ctx, code, err)
+}
+
+func (h handler) respondWithStatus(ctx *gin.Context, code int, err error) {
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "session service request failed", "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+
+	ctx.Status(code)
+}
+
+// invalidateUser drops the cached validations of every token of the caller
+// and reloads the revoked sessions, since the gateway cannot tell which of
+// the tokens belonged to them.
+func (h handler) invalidateUser(ctx *gin.Context, token string) {
+	userId, err := h.validator.Validate(ctx.Request.Context(), token)
+	if err != nil {
+		h.validator.Invalidate(token)
+		return
+	}
+	if err = h.validator.InvalidateUser(ctx.Request.Context(), userId); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not reload revoked sessions", "error", err)
+	}
+}
+
+func bearerToken(ctx *gin.Context) (string, bool) {
+	reqToken := ctx.Request.Header.Get("Authorization")
+	if !strings.HasPrefix(reqToken, "Bearer ") {
+		problem.Respond(ctx, problem.Unauthorized("missing bearer token"))
+		return "", false
+	}
+	return strings.TrimPrefix(reqToken, "Bearer "), true
+}
+
+func device(ctx *gin.Context) models.Device {
+	return models.Device{UserAgent: ctx.Request.UserAgent(), IP: ctx.ClientIP()}
+}
+
+func RegisterAuthHandlers(rg *gin.RouterGroup, service SessionService, logger *slog.Logger, userService gateway.UsersService,
+	validator TokenValidator.ValidationService) {
+	h := handler{logger: logger, service: service, userService: userService, validator: validator}
 	rg.POST("/login", h.Authorize)
 	rg.POST("/register", h.Register)
+	rg.POST("/refresh", h.Refresh)
+	rg.POST("/logout", h.Logout)
+	rg.GET("", h.GetSessions)
+	rg.DELETE("", h.RevokeAllSessions)
+	rg.DELETE("/:id", h.RevokeSession)
+	rg.POST("/email/verify", h.VerifyEmail)
+	rg.POST("/email/verify/resend", h.ResendVerification)
+	rg.POST("/password/forgot", h.ForgotPassword)
+	rg.POST("/password/reset", h.ResetPassword)
+	rg.POST("/mfa/verify", h.VerifyMFA)
+	rg.POST("/mfa/enroll", h.EnrollMFA)
+	rg.POST("/mfa/confirm", h.ConfirmMFA)
+	rg.DELETE("/mfa", h.DisableMFA)
 }
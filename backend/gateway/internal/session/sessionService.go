@@ -2,113 +2,352 @@ package session
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Feokrat/music-dating-app/gateway/internal/config"
 	"github.com/Feokrat/music-dating-app/gateway/internal/schemas"
 	"github.com/Feokrat/music-dating-app/gateway/internal/session/models"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/HTTPclient"
+	"github.com/google/uuid"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 type service struct {
-	logger *log.Logger
-	client http.Client
+	logger *slog.Logger
+	client *HTTPclient.HTTPclient
 	config config.ServicesConfig
 }
 
-func (s service) Authorize(auth models.Auth) (schemas.TokenResponse, error) {
+func (s service) Authorize(ctx context.Context, auth models.Auth, device models.Device) (schemas.TokenResponse, error) {
 	getAuthUrl := s.config.SessionService + fmt.Sprintf("/auth/sign-in")
 
 	var authBytes bytes.Buffer
 	err := json.NewEncoder(&authBytes).Encode(auth)
 	if err != nil {
-		s.logger.Printf("could not convert to io read auth, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not convert to io read auth", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 
-	req, err := http.NewRequest("POST", getAuthUrl, &authBytes)
+	req, err := http.NewRequestWithContext(ctx, "POST", getAuthUrl, &authBytes)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.TokenResponse{}, err
 	}
+	setDevice(req, device)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get users, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not get users", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		s.logger.ErrorContext(ctx, "session service rejected sign in", "status", resp.StatusCode)
+		return schemas.TokenResponse{}, s.client.ResponseError(resp)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 
 	var token schemas.TokenResponse
 	err = json.Unmarshal(body, &token)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 
 	return token, err
 }
 
-func (s service) Register(auth models.Register) (schemas.TokenResponse, error) {
+func (s service) Register(ctx context.Context, auth models.Register, device models.Device) (schemas.TokenResponse, error) {
 	getAuthUrl := s.config.SessionService + fmt.Sprintf("/auth/register")
 
 	var authBytes bytes.Buffer
 	err := json.NewEncoder(&authBytes).Encode(auth)
 	if err != nil {
-		s.logger.Printf("could not convert to io read auth, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not convert to io read auth", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 
-	req, err := http.NewRequest("POST", getAuthUrl, &authBytes)
+	req, err := http.NewRequestWithContext(ctx, "POST", getAuthUrl, &authBytes)
 	if err != nil {
-		s.logger.Printf("could not create request, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
 		return schemas.TokenResponse{}, err
 	}
+	setDevice(req, device)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		s.logger.Printf("could not get users, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not get users", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		fmt.Println("Non-OK HTTP status:", resp.StatusCode)
-		// You may read / inspect response body
-		return schemas.TokenResponse{}, schemas.UserAlreadyExistsError
+		s.logger.WarnContext(ctx, "session service rejected registration", "status", resp.StatusCode)
+		return schemas.TokenResponse{}, s.client.ResponseError(resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		s.logger.Printf("could not read response body, error: %s",
-			err.Error())
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 
 	var token schemas.TokenResponse
 	err = json.Unmarshal(body, &token)
 	if err != nil {
-		s.logger.Printf("could not unmarshal response body, error: %s", err.Error())
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
 		return schemas.TokenResponse{}, err
 	}
 
 	return token, err
 }
 
+func (s service) Refresh(ctx context.Context, refresh models.Refresh) (schemas.TokenResponse, error) {
+	refreshUrl := s.config.SessionService + "/auth/token/refresh"
+
+	var refreshBytes bytes.Buffer
+	err := json.NewEncoder(&refreshBytes).Encode(refresh)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not convert to io read refresh token", "error", err)
+		return schemas.TokenResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", refreshUrl, &refreshBytes)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.TokenResponse{}, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not refresh tokens", "error", err)
+		return schemas.TokenResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		s.logger.ErrorContext(ctx, "session service rejected refresh tokens", "status", resp.StatusCode)
+		return schemas.TokenResponse{}, s.client.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.TokenResponse{}, err
+	}
+
+	var token schemas.TokenResponse
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.TokenResponse{}, err
+	}
+
+	return token, nil
+}
+
+func (s service) GetSessions(ctx context.Context, token string) (schemas.SessionsResponse, int, error) {
+	sessionsUrl := s.config.SessionService + "/auth/sessions"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", sessionsUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return schemas.SessionsResponse{}, 0, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get sessions", "error", err)
+		return schemas.SessionsResponse{}, HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return schemas.SessionsResponse{}, resp.StatusCode, s.client.ResponseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return schemas.SessionsResponse{}, 0, err
+	}
+
+	var sessions struct {
+		Data []schemas.SessionResponse `json:"data"`
+	}
+	err = json.Unmarshal(body, &sessions)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.SessionsResponse{}, 0, err
+	}
+
+	return schemas.SessionsResponse{Sessions: sessions.Data}, resp.StatusCode, nil
+}
+
+func (s service) Logout(ctx context.Context, token string) (int, error) {
+	return s.revoke(ctx, "POST", token, s.config.SessionService+"/auth/sign-out")
+}
+
+func (s service) RevokeSession(ctx context.Context, token string, sessionId uuid.UUID) (int, error) {
+	return s.revoke(ctx, "DELETE", token, s.config.SessionService+fmt.Sprintf("/auth/sessions/%v", sessionId))
+}
+
+func (s service) RevokeAllSessions(ctx context.Context, token string) (int, error) {
+	return s.revoke(ctx, "DELETE", token, s.config.SessionService+"/auth/sessions")
+}
+
+func (s service) revoke(ctx context.Context, method string, token string, sessionsUrl string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, sessionsUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return 0, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not revoke sessions", "error", err)
+		return HTTPclient.ResponseStatus(0, err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return resp.StatusCode, s.client.ResponseError(resp)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (s service) VerifyEmail(ctx context.Context, verification models.VerifyEmail) (int, error) {
+	return s.post(ctx, s.config.SessionService+"/auth/email/verify", "", verification)
+}
+
+func (s service) ResendVerification(ctx context.Context, token string) (int, error) {
+	return s.post(ctx, s.config.SessionService+"/auth/email/verify/resend", token, nil)
+}
+
+func (s service) ForgotPassword(ctx context.Context, forgot models.ForgotPassword) (int, error) {
+	return s.post(ctx, s.config.SessionService+"/auth/password/forgot", "", forgot)
+}
+
+func (s service) ResetPassword(ctx context.Context, reset models.ResetPassword) (int, error) {
+	return s.post(ctx, s.config.SessionService+"/auth/password/reset", "", reset)
+}
+
+func (s service) VerifyMFA(ctx context.Context, verification models.MFAVerify, device models.Device) (schemas.TokenResponse, int, error) {
+	code, body, err := s.forward(ctx, "POST", s.config.SessionService+"/auth/mfa/verify", "", verification, device)
+	if err != nil {
+		return schemas.TokenResponse{}, code, err
+	}
+
+	var token schemas.TokenResponse
+	if err = json.Unmarshal(body, &token); err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return schemas.TokenResponse{}, 0, err
+	}
+	return token, code, nil
+}
+
+func (s service) EnrollMFA(ctx context.Context, token string) ([]byte, int, error) {
+	code, body, err := s.forward(ctx, "POST", s.config.SessionService+"/auth/mfa/enroll", token, nil, models.Device{})
+	return body, code, err
+}
+
+func (s service) ConfirmMFA(ctx context.Context, token string, mfaCode models.MFACode) ([]byte, int, error) {
+	code, body, err := s.forward(ctx, "POST", s.config.SessionService+"/auth/mfa/confirm", token, mfaCode, models.Device{})
+	return body, code, err
+}
+
+func (s service) DisableMFA(ctx context.Context, token string, mfaCode models.MFACode) (int, error) {
+	code, _, err := s.forward(ctx, "DELETE", s.config.SessionService+"/auth/mfa", token, mfaCode, models.Device{})
+	return code, err
+}
+
+// post sends a request that is answered without a body, any 2xx status is a success.
+func (s service) post(ctx context.Context, accountUrl string, token string, body interface{}) (int, error) {
+	code, _, err := s.forward(ctx, "POST", accountUrl, token, body, models.Device{})
+	return code, err
+}
+
+// forward sends a request to the session service and returns the response
+// body, non-2xx responses are turned into errors carrying their problem.
+func (s service) forward(ctx context.Context, method string, sessionUrl string, token string, body interface{},
+	device models.Device) (int, []byte, error) {
+	var bodyBytes bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&bodyBytes).Encode(body); err != nil {
+			s.logger.ErrorContext(ctx, "could not encode request body", "error", err)
+			return 0, nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, sessionUrl, &bodyBytes)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return 0, nil, err
+	}
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	setDevice(req, device)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not reach session service", "error", err)
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, nil, s.client.ResponseError(resp)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not read response body", "error", err)
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, respBody, nil
+}
+
+func setDevice(req *http.Request, device models.Device) {
+	if device.UserAgent != "" {
+		req.Header.Set("User-Agent", device.UserAgent)
+	}
+	if device.IP != "" {
+		req.Header.Set("X-Forwarded-For", device.IP)
+	}
+}
+
 type SessionService interface {
-	Authorize(auth models.Auth) (schemas.TokenResponse, error)
-	Register(auth models.Register) (schemas.TokenResponse, error)
+	Authorize(ctx context.Context, auth models.Auth, device models.Device) (schemas.TokenResponse, error)
+	Register(ctx context.Context, auth models.Register, device models.Device) (schemas.TokenResponse, error)
+	Refresh(ctx context.Context, refresh models.Refresh) (schemas.TokenResponse, error)
+	Logout(ctx context.Context, token string) (int, error)
+	GetSessions(ctx context.Context, token string) (schemas.SessionsResponse, int, error)
+	RevokeSession(ctx context.Context, token string, sessionId uuid.UUID) (int, error)
+	RevokeAllSessions(ctx context.Context, token string) (int, error)
+	VerifyEmail(ctx context.Context, verification models.VerifyEmail) (int, error)
+	ResendVerification(ctx context.Context, token string) (int, error)
+	ForgotPassword(ctx context.Context, forgot models.ForgotPassword) (int, error)
+	ResetPassword(ctx context.Context, reset models.ResetPassword) (int, error)
+	VerifyMFA(ctx context.Context, verification models.MFAVerify, device models.Device) (schemas.TokenResponse, int, error)
+	EnrollMFA(ctx context.Context, token string) ([]byte, int, error)
+	ConfirmMFA(ctx context.Context, token string, mfaCode models.MFACode) ([]byte, int, error)
+	DisableMFA(ctx context.Context, token string, mfaCode models.MFACode) (int, error)
 }
 
-func NewSessionService(logger *log.Logger, config config.ServicesConfig) SessionService {
-	return service{logger: logger, client: http.Client{}, config: config}
+func NewSessionService(logger *slog.Logger, config config.ServicesConfig, clients HTTPclient.Clients) SessionService {
+	return service{logger: logger, client: clients.Sessions, config: config}
 }
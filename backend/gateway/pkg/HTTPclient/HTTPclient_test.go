@@ -0,0 +1,324 @@
+package HTTPclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/gateway/internal/config"
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
+)
+
+// upstream responds with the statuses in turn, repeating the last one, and
+// counts the requests it got.
+type upstream struct {
+	*httptest.Server
+	calls    atomic.Int32
+	statuses []int
+	// delay, when set, is how long the upstream takes to respond
+	delay time.Duration
+}
+
+func newUpstream(t *testing.T, statuses ...int) *upstream {
+	u := &upstream{statuses: statuses}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(u.calls.Add(1))
+		if u.delay > 0 {
+			select {
+			case <-time.After(u.delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(u.statuses[min(call, len(u.statuses))-1])
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func send(t *testing.T, c *HTTPclient, method, url, body string) (*http.Response, error) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err == nil {
+		t.Cleanup(func() { resp.Body.Close() })
+	}
+	return resp, err
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			u := newUpstream(t, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK)
+			c := NewHTTPclient("test", config.ClientConfig{Timeout: time.Second, Retries: 2, RetryBackoff: time.Millisecond})
+
+			resp, err := send(t, c, method, u.URL, "")
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if resp.StatusCode != http.StatusOK || u.calls.Load() != 3 {
+				t.Errorf("Do() = %d after %d calls, want %d after 3", resp.StatusCode, u.calls.Load(), http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestDoesNotRetryOtherRequests(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		t.Run(method, func(t *testing.T) {
+			u := newUpstream(t, http.StatusServiceUnavailable, http.StatusOK)
+			c := NewHTTPclient("test", config.ClientConfig{Timeout: time.Second, Retries: 2, RetryBackoff: time.Millisecond})
+
+			resp, err := send(t, c, method, u.URL, `{}`)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if resp.StatusCode != http.StatusServiceUnavailable || u.calls.Load() != 1 {
+				t.Errorf("Do() = %d after %d calls, want %d after 1", resp.StatusCode, u.calls.Load(),
+					http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+func TestDoesNotRetryOtherStatuses(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusNotFound, http.StatusTooManyRequests} {
+		u := newUpstream(t, status, http.StatusOK)
+		c := NewHTTPclient("test", config.ClientConfig{Timeout: time.Second, Retries: 2, RetryBackoff: time.Millisecond})
+
+		resp, err := send(t, c, http.MethodGet, u.URL, "")
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if resp.StatusCode != status || u.calls.Load() != 1 {
+			t.Errorf("Do() = %d after %d calls, want %d after 1", resp.StatusCode, u.calls.Load(), status)
+		}
+	}
+}
+
+func TestRetriesResendTheBody(t *testing.T) {
+	u := newUpstream(t, http.StatusServiceUnavailable, http.StatusOK)
+	c := NewHTTPclient("test", config.ClientConfig{Timeout: time.Second, Retries: 1, RetryBackoff: time.Millisecond})
+
+	resp, err := send(t, c, http.MethodPut, u.URL, `{"name":"listener"}`)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"name":"listener"}` {
+		t.Errorf("retried request body = %q", body)
+	}
+	if got := resp.Request.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("request Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestReturnsLastResponseWhenRetriesRunOut(t *testing.T) {
+	u := newUpstream(t, http.StatusGatewayTimeout)
+	c := NewHTTPclient("test", config.ClientConfig{Timeout: time.Second, Retries: 2, RetryBackoff: time.Millisecond})
+
+	resp, err := send(t, c, http.MethodGet, u.URL, "")
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusGatewayTimeout || u.calls.Load() != 3 {
+		t.Errorf("Do() = %d after %d calls, want %d after 3", resp.StatusCode, u.calls.Load(), http.StatusGatewayTimeout)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("body of the last response is not readable: %v", err)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	c := NewHTTPclient("test", config.ClientConfig{RetryBackoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond})
+
+	// the doubled backoff overflows at the last attempt
+	for _, attempt := range []int{1, 2, 3, 10, 64} {
+		start := time.Now()
+		if err := c.wait(context.Background(), attempt); err != nil {
+			t.Fatalf("wait() error = %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 30*time.Millisecond+20*time.Millisecond {
+			t.Errorf("wait() before attempt %d took %v, want at most %v", attempt, elapsed, 30*time.Millisecond)
+		}
+	}
+}
+
+func TestBackoffStopsWithContext(t *testing.T) {
+	c := NewHTTPclient("test", config.ClientConfig{RetryBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := c.wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	u := newUpstream(t, http.StatusServiceUnavailable)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.URL, nil)
+	_, err := c.Do(req)
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Do() cancelled while backing off error = %v, want a 504 upstream error", err)
+	}
+}
+
+func TestTransportErrorsMapToStatuses(t *testing.T) {
+	slow := newUpstream(t, http.StatusOK)
+	slow.delay = time.Second
+	closed := newUpstream(t, http.StatusOK)
+	closed.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"timeout", slow.URL, http.StatusGatewayTimeout},
+		{"connection refused", closed.URL, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewHTTPclient("test", config.ClientConfig{Timeout: 20 * time.Millisecond})
+
+			_, err := send(t, c, http.MethodGet, tt.url, "")
+			var upstreamErr *UpstreamError
+			if !errors.As(err, &upstreamErr) {
+				t.Fatalf("Do() error = %v, want *UpstreamError", err)
+			}
+			if upstreamErr.StatusCode != tt.want || ResponseStatus(0, err) != tt.want {
+				t.Errorf("Do() error status = %d, want %d", upstreamErr.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	u := newUpstream(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+		http.StatusOK)
+	c := NewHTTPclient("test", config.ClientConfig{Timeout: time.Second, FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if _, err := send(t, c, http.MethodGet, u.URL, ""); err != nil {
+			t.Fatalf("Do() while closed error = %v", err)
+		}
+	}
+
+	// open: calls fail without reaching the upstream
+	_, err := send(t, c, http.MethodGet, u.URL, "")
+	var upstreamErr *UpstreamError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Do() while open error = %v, want %v with status 503", err, ErrCircuitOpen)
+	}
+	if got := u.calls.Load(); got != 2 {
+		t.Fatalf("upstream called %d times, want 2", got)
+	}
+
+	// half-open: a failed probe opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	if resp, err := send(t, c, http.MethodGet, u.URL, ""); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Do() probe = %v, %v, want the upstream response", resp, err)
+	}
+	if _, err := send(t, c, http.MethodGet, u.URL, ""); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Do() after a failed probe error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// half-open: a successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if resp, err := send(t, c, http.MethodGet, u.URL, ""); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("Do() after a successful probe = %v, %v, want %d", resp, err, http.StatusOK)
+		}
+	}
+	if got := u.calls.Load(); got != 6 {
+		t.Errorf("upstream called %d times, want 6", got)
+	}
+}
+
+func TestBreakerLetsOneProbeThrough(t *testing.T) {
+	b := newBreaker(1, time.Millisecond)
+	b.failure()
+	if b.allow() {
+		t.Fatal("open breaker allowed a call")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("breaker allowed no probe after the open timeout")
+	}
+	if b.allow() {
+		t.Error("breaker allowed a second call while probing")
+	}
+
+	b.success()
+	if !b.allow() || !b.allow() {
+		t.Error("closed breaker rejected calls")
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		err  error
+		want int
+	}{
+		{http.StatusBadGateway, nil, http.StatusBadGateway},
+		{http.StatusServiceUnavailable, nil, http.StatusServiceUnavailable},
+		{http.StatusGatewayTimeout, nil, http.StatusGatewayTimeout},
+		{http.StatusInternalServerError, nil, http.StatusBadGateway},
+		{http.StatusNotFound, nil, http.StatusNotFound},
+		{http.StatusOK, nil, http.StatusInternalServerError},
+		{0, &UpstreamError{StatusCode: http.StatusGatewayTimeout}, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		if got := ResponseStatus(tt.code, tt.err); got != tt.want {
+			t.Errorf("ResponseStatus(%d, %v) = %d, want %d", tt.code, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	c := NewHTTPclient("test", config.ClientConfig{})
+	response := func(status int, contentType, body string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+		resp.Header.Set("Content-Type", contentType)
+		return resp
+	}
+
+	err := c.ResponseError(response(http.StatusConflict, problem.ContentType, `{"code":"login_taken","detail":"taken"}`))
+	var document *problem.Problem
+	if !errors.As(err, &document) || document.Code != "login_taken" || document.Status != http.StatusConflict {
+		t.Errorf("ResponseError() of a problem document = %v, want it passed on", err)
+	}
+
+	for status, want := range map[int]int{
+		http.StatusInternalServerError: http.StatusBadGateway,
+		http.StatusBadGateway:          http.StatusBadGateway,
+		http.StatusServiceUnavailable:  http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:      http.StatusGatewayTimeout,
+	} {
+		var upstreamErr *UpstreamError
+		if err := c.ResponseError(response(status, "text/plain", "")); !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != want {
+			t.Errorf("ResponseError() of %d = %v, want an upstream error with status %d", status, err, want)
+		}
+	}
+
+	err = c.ResponseError(response(http.StatusNotFound, "text/plain", ""))
+	if !errors.As(err, &document) || document.Status != http.StatusNotFound || document.Code != problem.CodeNotFound {
+		t.Errorf("ResponseError() of %d = %v, want a not found problem", http.StatusNotFound, err)
+	}
+}
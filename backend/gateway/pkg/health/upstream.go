@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Upstream checks that the service at baseURL answers its liveness probe. Only
+// the scheme and host of baseURL are used, so a URL with an API prefix works.
+func Upstream(client *http.Client, baseURL string) (Check, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("upstream url %q has no scheme or host", baseURL)
+	}
+	probe := u.Scheme + "://" + u.Host + "/healthz"
+
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,116 @@
+// Package openapi serves the OpenAPI document of a service and validates
+// incoming requests against it.
+package openapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Feokrat/music-dating-app/gateway/pkg/problem"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+const Path = "/openapi.json"
+
+// Load parses the document and checks that it is a valid OpenAPI 3 one.
+func Load(spec []byte) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("could not load OpenAPI document: %w", err)
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+// RegisterHandler serves the document as JSON at Path.
+func RegisterHandler(router gin.IRoutes, doc *openapi3.T) {
+	router.GET(Path, func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+}
+
+// Validator checks path and query parameters and JSON bodies of requests
+// against the document and answers mismatches with a validation problem.
+// Requests the document does not describe pass unchecked, security
+// requirements are left to the authentication middlewares.
+func Validator(doc *openapi3.T) (gin.HandlerFunc, error) {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("could not build OpenAPI router: %w", err)
+	}
+	options := &openapi3filter.Options{
+		MultiError:         true,
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(c *gin.Context) {
+		route, pathParams, err := router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		err = openapi3filter.ValidateRequest(c.Request.Context(), &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		})
+		if err != nil {
+			problem.Respond(c, problem.Validation("request does not match the API specification",
+				fieldErrors(err, "", nil)...))
+			return
+		}
+
+		c.Next()
+	}, nil
+}
+
+// fieldErrors flattens the errors of openapi3filter, field is the parameter
+// or body path the error was found at.
+func fieldErrors(err error, field string, fields []problem.FieldError) []problem.FieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			fields = fieldErrors(inner, field, fields)
+		}
+		return fields
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			field = e.Parameter.Name
+		case e.RequestBody != nil:
+			field = "body"
+		}
+		if e.Err == nil {
+			return append(fields, problem.FieldError{Field: field, Code: "invalid", Message: e.Reason})
+		}
+		return fieldErrors(e.Err, field, fields)
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			if field == "body" {
+				field = ""
+			}
+			field = strings.TrimPrefix(field+"."+strings.Join(pointer, "."), ".")
+		}
+		return append(fields, problem.FieldError{Field: field, Code: e.SchemaField, Message: e.Reason})
+	case *openapi3filter.ParseError:
+		return append(fields, problem.FieldError{Field: field, Code: "type", Message: e.Error()})
+	}
+	return append(fields, problem.FieldError{Field: field, Code: "invalid", Message: err.Error()})
+}
+
+// The formats are opt-in in kin-openapi, every service uses them for ids and
+// addresses.
+func init() {
+	openapi3.DefineStringFormat("uuid", openapi3.FormatOfStringForUUIDOfRFC4122)
+	openapi3.DefineStringFormat("email", openapi3.FormatOfStringForEmail)
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/Feokrat/music-dating-app/notifications/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds ids taken from the caller, longer ones are replaced.
+const maxRequestIDLength = 128
+
+// RequestID keeps the X-Request-ID of the caller, or generates one, stores it
+// in the request context for logging and echoes it in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(logging.RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(logging.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
@@ -1,9 +1,18 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Chats struct {
 	Id      uuid.UUID `json:"id" db:"id"`
 	UserId1 uuid.UUID `json:"user_id1" db:"user_id1"`
 	UserId2 uuid.UUID `json:"user_id2" db:"user_id2"`
+	// MatchId is the match that opened the chat, nil for chats opened before
+	// chats were tied to matches
+	MatchId *uuid.UUID `json:"match_id" db:"match_id"`
+	// ArchivedAt is set once the users unmatched or one blocked the other
+	ArchivedAt *time.Time `json:"archived_at" db:"archived_at"`
 }
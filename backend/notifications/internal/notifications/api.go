@@ -2,23 +2,25 @@ package notifications
 
 import (
 	"github.com/Feokrat/music-dating-app/notifications/internal/schemas"
+	"github.com/Feokrat/music-dating-app/notifications/pkg/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 type handler struct {
 	s      Service
-	logger *log.Logger
+	logger *slog.Logger
 }
 
-func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *log.Logger) {
+func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *slog.Logger) {
 	h := handler{logger: logger, s: service}
 
 	rg.GET("/chats/:user_id", h.GetChatsByUserID)
 	rg.GET("/messages/chat/:id", h.GetMessagesByChatId)
 	rg.POST("/chats", h.CreateChat)
+	rg.PUT("/chats/archive", h.ArchiveChats)
 	rg.POST("/messages", h.CreateMessage)
 }
 
@@ -26,33 +28,23 @@ func (h handler) GetChatsByUserID(ctx *gin.Context) {
 	userIdStr := ctx.Param("user_id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id", err))
 
 		return
 	}
 
-	chats, err := h.s.GetAllChats(userId)
+	chats, err := h.s.GetAllChats(ctx.Request.Context(), userId)
 	if err != nil {
-		h.logger.Printf("could not get chats for user %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get chats for user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
 	if len(chats) == 0 {
-		h.logger.Printf("chats weren't found %v",
-			userId)
-		ctx.JSON(http.StatusNotFound, schemas.ErrorResponse{
-			Message: "Chats weren't found",
-		})
+		h.logger.InfoContext(ctx.Request.Context(), "chats weren't found", "user_id", userId)
+		problem.Respond(ctx, problem.NotFound("chats weren't found"))
 		return
 	}
 
@@ -64,9 +56,9 @@ func (h handler) GetChatsByUserID(ctx *gin.Context) {
 		chat.IsRead = true
 		chat.UserId1 = chats[i].UserId1
 		chat.UserId2 = chats[i].UserId2
-		messages, error := h.s.GetAllMessages(chats[i].Id)
+		messages, error := h.s.GetAllMessages(ctx.Request.Context(), chats[i].Id, userId)
 		if error != nil {
-			h.logger.Printf("Error occured during getting messages of chat %v", chats[i].Id)
+			h.logger.ErrorContext(ctx.Request.Context(), "error occurred during getting messages of chat", "chat_id", chats[i].Id)
 		} else {
 			if len(messages) != 0 {
 				chat.LastMessage = messages[len(messages)-1].Content
@@ -80,15 +72,20 @@ func (h handler) GetChatsByUserID(ctx *gin.Context) {
 }
 
 func (h handler) CreateChat(ctx *gin.Context) {
+	matchIdStr := ctx.Query("match_id")
+	matchId, err := uuid.Parse(matchIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse match id", "match_id", matchIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("match_id", err))
+
+		return
+	}
+
 	userIdStr1 := ctx.Query("user_id1")
 	userId1, err := uuid.Parse(userIdStr1)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr1, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id1", userIdStr1, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id1", err))
 
 		return
 	}
@@ -96,51 +93,81 @@ func (h handler) CreateChat(ctx *gin.Context) {
 	userIdStr2 := ctx.Query("user_id2")
 	userId2, err := uuid.Parse(userIdStr2)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr2, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id2", userIdStr2, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id2", err))
 
 		return
 	}
 
-	chatId, err := h.s.CreateChat(userId1, userId2)
+	chatId, created, err := h.s.CreateChat(ctx.Request.Context(), matchId, userId1, userId2)
 	if err != nil {
-		h.logger.Printf("could not create chats for user %v and %v, error: %s",
-			userId1, userId2, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not create chat", "match_id", matchId, "user_id1", userId1, "user_id2", userId2, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
+	if !created {
+		ctx.JSON(http.StatusOK, chatId)
+
+		return
+	}
 	ctx.JSON(http.StatusCreated, chatId)
 }
 
+func (h handler) ArchiveChats(ctx *gin.Context) {
+	userIdStr1 := ctx.Query("user_id1")
+	userId1, err := uuid.Parse(userIdStr1)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id1", userIdStr1, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id1", err))
+
+		return
+	}
+
+	userIdStr2 := ctx.Query("user_id2")
+	userId2, err := uuid.Parse(userIdStr2)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id2", userIdStr2, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id2", err))
+
+		return
+	}
+
+	err = h.s.ArchiveChats(ctx.Request.Context(), userId1, userId2)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not archive chats", "user_id1", userId1, "user_id2", userId2, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
 func (h handler) GetMessagesByChatId(ctx *gin.Context) {
 	chatIdStr := ctx.Param("id")
 	chatId, err := uuid.Parse(chatIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse chat id %v, error: %s",
-			chatIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong char id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse chat id", "chat_id", chatIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	userIdStr := ctx.Query("user_id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id", err))
 
 		return
 	}
 
-	messages, err := h.s.GetAllMessages(chatId)
+	messages, err := h.s.GetAllMessages(ctx.Request.Context(), chatId, userId)
 	if err != nil {
-		h.logger.Printf("could not get chats for user %v, error: %s",
-			chatId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get chats for user", "chat_id", chatId, "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -152,23 +179,16 @@ func (h handler) GetMessagesByChatId(ctx *gin.Context) {
 
 func (h handler) CreateMessage(ctx *gin.Context) {
 	var messageRequest schemas.MessageRequest
-	if err := ctx.BindJSON(&messageRequest); err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong request model",
-			Errors:  err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&messageRequest); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	messageId, err := h.s.CreateMessage(messageRequest.ChatId, messageRequest.UserId, messageRequest.Message)
+	messageId, err := h.s.CreateMessage(ctx.Request.Context(), messageRequest.ChatId, messageRequest.UserId, messageRequest.Message)
 	if err != nil {
-		h.logger.Printf("could not get chats for user %v and chat %v, error: %s",
-			messageRequest.UserId, messageRequest.ChatId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get chats for user and chat", "user_id", messageRequest.UserId, "chat_id", messageRequest.ChatId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
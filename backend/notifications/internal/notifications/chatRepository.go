@@ -1,77 +1,110 @@
 package notifications
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/Feokrat/music-dating-app/notifications/internal/models"
 	"github.com/Feokrat/music-dating-app/notifications/internal/schemas"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	"log"
+	"log/slog"
 )
 
 type chatRepository struct {
 	db     *sqlx.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 type ChatRepository interface {
-	GetAllChatsByUserId(userId uuid.UUID) ([]models.Chats, error)
-	CreateChat(userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, error)
-	GetChatByUserId(userId uuid.UUID) (models.Chats, error)
+	GetAllChatsByUserId(ctx context.Context, userId uuid.UUID) ([]models.Chats, error)
+	CreateChat(ctx context.Context, matchId uuid.UUID, userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, bool, error)
+	GetChatByUserId(ctx context.Context, chatId uuid.UUID, userId uuid.UUID) (models.Chats, error)
+	ArchiveChats(ctx context.Context, userId1 uuid.UUID, userId2 uuid.UUID) (int64, error)
 }
 
 const (
 	chatTable = "chats"
 )
 
-func NewChatRepository(db *sqlx.DB, logger *log.Logger) ChatRepository {
+func NewChatRepository(db *sqlx.DB, logger *slog.Logger) ChatRepository {
 	return chatRepository{
 		db:     db,
 		logger: logger,
 	}
 }
 
-func (c chatRepository) GetAllChatsByUserId(userId uuid.UUID) ([]models.Chats, error) {
+func (c chatRepository) GetAllChatsByUserId(ctx context.Context, userId uuid.UUID) ([]models.Chats, error) {
 
 	var users []models.Chats
-	query := fmt.Sprintf("SELECT * FROM %s WHERE user_id1 = $1 OR user_id2 = $2", chatTable)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE (user_id1 = $1 OR user_id2 = $2) AND archived_at IS NULL", chatTable)
 
-	err := c.db.Select(&users, query, userId, userId)
+	err := c.db.SelectContext(ctx, &users, query, userId, userId)
 	if err != nil {
-		c.logger.Printf("error in db while trying to get all chats, error: %s", err.Error())
+		c.logger.ErrorContext(ctx, "error in db while trying to get all chats", "error", err)
 		return nil, err
 	}
 
 	return users, nil
 }
 
-func (c chatRepository) CreateChat(userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, error) {
-
-	var chatId = uuid.New()
-	query := fmt.Sprintf("INSERT INTO %s (id, user_id1, user_id2)"+
-		" values ($1, $2, $3) RETURNING id", chatTable)
-
+// CreateChat opens the chat of the match and reports whether it did, the
+// chat is opened once however often it is called. An open chat of the users
+// from before chats were tied to matches is taken over by the match.
+func (c chatRepository) CreateChat(ctx context.Context, matchId uuid.UUID, userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, bool, error) {
 	var id uuid.UUID
+	query := fmt.Sprintf(`UPDATE %s SET match_id = $1 WHERE id = (SELECT id FROM %s
+		WHERE ((user_id1 = $2 AND user_id2 = $3) OR (user_id1 = $3 AND user_id2 = $2))
+		AND match_id IS NULL AND archived_at IS NULL LIMIT 1) RETURNING id`, chatTable, chatTable)
+	err := c.db.GetContext(ctx, &id, query, matchId, userId1, userId2)
+	if err == nil {
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		c.logger.ErrorContext(ctx, "error in db while trying to take over chat", "match_id", matchId, "error", err)
+		return uuid.Nil, false, err
+	}
 
-	row := c.db.QueryRow(query, chatId, userId1, userId2)
-
-	if err := row.Scan(&id); err != nil {
-		c.logger.Printf("error in db while trying to create chat %v %v, error: %s",
-			userId1, userId2, err.Error())
-		return uuid.Nil, err
+	query = fmt.Sprintf("INSERT INTO %s (id, match_id, user_id1, user_id2)"+
+		" values ($1, $2, $3, $4) ON CONFLICT (match_id) DO NOTHING RETURNING id", chatTable)
+	err = c.db.GetContext(ctx, &id, query, uuid.New(), matchId, userId1, userId2)
+	created := err == nil
+	if err == sql.ErrNoRows {
+		query = fmt.Sprintf(`SELECT id FROM %s WHERE match_id = $1`, chatTable)
+		err = c.db.GetContext(ctx, &id, query, matchId)
+	}
+	if err != nil {
+		c.logger.ErrorContext(ctx, "error in db while trying to create chat", "match_id", matchId, "user_id1", userId1, "user_id2", userId2, "error", err)
+		return uuid.Nil, false, err
 	}
 
-	return id, nil
+	return id, created, nil
 }
 
-func (c chatRepository) GetChatByUserId(userId uuid.UUID) (models.Chats, error) {
+// GetChatByUserId returns the chat when the user is one of its users, the
+// chats of other users are not found.
+func (c chatRepository) GetChatByUserId(ctx context.Context, chatId uuid.UUID, userId uuid.UUID) (models.Chats, error) {
 	var chat models.Chats
-	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, chatTable)
-	err := c.db.Get(&chat, query, userId)
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 AND (user_id1 = $2 OR user_id2 = $2)`, chatTable)
+	err := c.db.GetContext(ctx, &chat, query, chatId, userId)
 	if err == sql.ErrNoRows {
-		return chat, schemas.NotFoundError{Message: fmt.Sprintf("Not found any chat of user with id %v", userId)}
+		return chat, schemas.NotFoundError{Message: fmt.Sprintf("Not found any chat with id %v of user with id %v", chatId, userId)}
 	}
 
 	return chat, err
 }
+
+// ArchiveChats archives the chats between the users and returns how many
+// were open.
+func (c chatRepository) ArchiveChats(ctx context.Context, userId1 uuid.UUID, userId2 uuid.UUID) (int64, error) {
+	query := fmt.Sprintf(`UPDATE %s SET archived_at = now()
+		WHERE ((user_id1 = $1 AND user_id2 = $2) OR (user_id1 = $2 AND user_id2 = $1)) AND archived_at IS NULL`, chatTable)
+
+	res, err := c.db.ExecContext(ctx, query, userId1, userId2)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "error in db while trying to archive chats", "user_id1", userId1, "user_id2", userId2, "error", err)
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
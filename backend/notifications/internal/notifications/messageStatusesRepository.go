@@ -2,12 +2,12 @@ package notifications
 
 import (
 	"github.com/jmoiron/sqlx"
-	"log"
+	"log/slog"
 )
 
 type messageStatusesRepository struct {
 	db     *sqlx.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 type MessageStatusesRepository interface {
@@ -18,7 +18,7 @@ const (
 	messageStatusesTable = "messagestatuses"
 )
 
-func NewMessageStatusesRepository(db *sqlx.DB, logger *log.Logger) MessageStatusesRepository {
+func NewMessageStatusesRepository(db *sqlx.DB, logger *slog.Logger) MessageStatusesRepository {
 	return messageStatusesRepository{
 		db:     db,
 		logger: logger,
@@ -0,0 +1,23 @@
+package notifications
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	chatsCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "chats_created_total",
+		Help: "Number of chats created for matches.",
+	})
+
+	messagesSentTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "chat_messages_sent_total",
+		Help: "Number of messages sent in chats.",
+	})
+
+	chatsArchivedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "chats_archived_total",
+		Help: "Number of chats archived after an unmatch or a block.",
+	})
+)
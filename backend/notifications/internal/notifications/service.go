@@ -1,50 +1,90 @@
 package notifications
 
 import (
+	"context"
+	"fmt"
 	"github.com/Feokrat/music-dating-app/notifications/internal/models"
+	"github.com/Feokrat/music-dating-app/notifications/internal/schemas"
 	"github.com/google/uuid"
-	"log"
+	"log/slog"
 )
 
 type service struct {
 	_chatRepository            ChatRepository
 	_messageRepository         MessageRepository
 	_messageStatusesRepository MessageStatusesRepository
-	logger                     *log.Logger
+	logger                     *slog.Logger
 }
 
 type Service interface {
-	GetAllChats(userId uuid.UUID) ([]models.Chats, error)
-	GetAllMessages(chatId uuid.UUID) ([]models.Messages, error)
-	CreateMessage(chatId uuid.UUID, userId uuid.UUID, message string) (uuid.UUID, error)
-	CreateChat(userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, error)
+	GetAllChats(ctx context.Context, userId uuid.UUID) ([]models.Chats, error)
+	GetAllMessages(ctx context.Context, chatId uuid.UUID, userId uuid.UUID) ([]models.Messages, error)
+	CreateMessage(ctx context.Context, chatId uuid.UUID, userId uuid.UUID, message string) (uuid.UUID, error)
+	CreateChat(ctx context.Context, matchId uuid.UUID, userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, bool, error)
+	ArchiveChats(ctx context.Context, userId1 uuid.UUID, userId2 uuid.UUID) error
 }
 
-func NewChatService(logger *log.Logger, chatr ChatRepository, messager MessageRepository, messagesr MessageStatusesRepository) Service {
+func NewChatService(logger *slog.Logger, chatr ChatRepository, messager MessageRepository, messagesr MessageStatusesRepository) Service {
 	return service{chatr,
 		messager,
 		messagesr,
 		logger}
 }
 
-func (s service) GetAllChats(userId uuid.UUID) ([]models.Chats, error) {
-	chats, err := s._chatRepository.GetAllChatsByUserId(userId)
+func (s service) GetAllChats(ctx context.Context, userId uuid.UUID) ([]models.Chats, error) {
+	chats, err := s._chatRepository.GetAllChatsByUserId(ctx, userId)
 	if err != nil {
-		s.logger.Printf("Error occured during getting chats for user %v", userId)
+		s.logger.ErrorContext(ctx, "error occurred during getting chats for user", "user_id", userId)
 		return nil, err
 	}
 
 	return chats, err
 }
 
-func (s service) GetAllMessages(chatId uuid.UUID) ([]models.Messages, error) {
-	return s._messageRepository.GetAllMessages(chatId)
+// GetAllMessages lists the messages of the chat, if the user is one of its
+// users.
+func (s service) GetAllMessages(ctx context.Context, chatId uuid.UUID, userId uuid.UUID) ([]models.Messages, error) {
+	if _, err := s._chatRepository.GetChatByUserId(ctx, chatId, userId); err != nil {
+		return nil, err
+	}
+
+	return s._messageRepository.GetAllMessages(ctx, chatId)
+}
+
+func (s service) CreateMessage(ctx context.Context, chatId uuid.UUID, userId uuid.UUID, message string) (uuid.UUID, error) {
+	chat, err := s._chatRepository.GetChatByUserId(ctx, chatId, userId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if chat.ArchivedAt != nil {
+		return uuid.Nil, schemas.ConflictError{Message: fmt.Sprintf("chat with id %v is archived", chatId)}
+	}
+
+	id, err := s._messageRepository.CreateMessage(ctx, message, chatId, userId)
+	if err == nil {
+		messagesSentTotal.Inc()
+	}
+	return id, err
 }
 
-func (s service) CreateMessage(chatId uuid.UUID, userId uuid.UUID, message string) (uuid.UUID, error) {
-	return s._messageRepository.CreateMessage(message, chatId, userId)
+// CreateChat opens the chat of the match, if it is open already its id is
+// returned with false.
+func (s service) CreateChat(ctx context.Context, matchId uuid.UUID, userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, bool, error) {
+	id, created, err := s._chatRepository.CreateChat(ctx, matchId, userId1, userId2)
+	if created {
+		chatsCreatedTotal.Inc()
+	}
+	return id, created, err
 }
 
-func (s service) CreateChat(userId1 uuid.UUID, userId2 uuid.UUID) (uuid.UUID, error) {
-	return s._chatRepository.CreateChat(userId1, userId2)
+// ArchiveChats hides the chats between the users, no messages can be sent
+// in them anymore.
+func (s service) ArchiveChats(ctx context.Context, userId1 uuid.UUID, userId2 uuid.UUID) error {
+	archived, err := s._chatRepository.ArchiveChats(ctx, userId1, userId2)
+	if err != nil {
+		return err
+	}
+
+	chatsArchivedTotal.Add(float64(archived))
+	return nil
 }
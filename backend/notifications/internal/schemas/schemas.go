@@ -2,6 +2,7 @@ package schemas
 
 import (
 	"github.com/Feokrat/music-dating-app/notifications/internal/models"
+	"github.com/Feokrat/music-dating-app/notifications/pkg/problem"
 	"github.com/google/uuid"
 )
 
@@ -23,11 +24,6 @@ type MessageRequest struct {
 	Message string    `json:"message"`
 }
 
-type ValidationErrorResponse struct {
-	Message string `json:"message"`
-	Errors  string `json:"errors"`
-}
-
 type ChatsResponse struct {
 	Chats []ChatsModel
 }
@@ -44,18 +40,26 @@ type MessageResponse struct {
 	Messages []models.Messages `json:"messages"`
 }
 
-type ErrorResponse struct {
+type NotFoundError struct {
 	Message string `json:"message"`
 }
 
-func (e ErrorResponse) Error() string {
+func (e NotFoundError) Error() string {
 	return e.Message
 }
 
-type NotFoundError struct {
+func (e NotFoundError) Problem() *problem.Problem {
+	return problem.NotFound(e.Message)
+}
+
+type ConflictError struct {
 	Message string `json:"message"`
 }
 
-func (e NotFoundError) Error() string {
+func (e ConflictError) Error() string {
 	return e.Message
 }
+
+func (e ConflictError) Problem() *problem.Problem {
+	return problem.Conflict(e.Message)
+}
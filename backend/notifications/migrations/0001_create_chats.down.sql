@@ -0,0 +1,3 @@
+DROP TABLE messagestatuses;
+DROP TABLE messages;
+DROP TABLE chats;
@@ -1,12 +1,12 @@
-Create database chat;
-
-CREATE TABLE chats (
+-- the tables may already exist in databases created with the former
+-- dbScripts/chat.sql, which are adopted as they are
+CREATE TABLE IF NOT EXISTS chats (
     id                   uuid PRIMARY KEY,
     user_id1             uuid NOT NULL,
     user_id2             uuid NOT NULL
 );
 
-CREATE TABLE messages (
+CREATE TABLE IF NOT EXISTS messages (
     id                   uuid PRIMARY KEY,
     creator_user_id uuid,
     chat_id uuid NOT NULL,
@@ -19,7 +19,7 @@ CREATE TABLE messages (
     ON DELETE NO ACTION
 );
 
-CREATE TABLE messagesStatuses ( 
+CREATE TABLE IF NOT EXISTS messagestatuses (
     id                   uuid PRIMARY KEY,
     message_id uuid NOT NULL,
     user_id uuid NOT NULL,
@@ -0,0 +1,3 @@
+-- set when the users unmatched or one blocked the other, the chat is then
+-- hidden and read-only
+ALTER TABLE chats ADD COLUMN archived_at timestamptz;
@@ -0,0 +1,5 @@
+-- each match opens one chat, chats opened before are left without a match
+-- until a like of the pair takes them over
+ALTER TABLE chats ADD COLUMN match_id uuid;
+
+CREATE UNIQUE INDEX chats_match_id_idx ON chats (match_id);
@@ -1,67 +1,80 @@
 package config
 
 import (
-	"log"
-	"strings"
+	"log/slog"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type (
 	Config struct {
-		HTTP HTTPConfig
-		PostgreSQL PGConfig
+		HTTP       HTTPConfig    `mapstructure:"http"`
+		PostgreSQL PGConfig      `mapstructure:"postgres"`
+		Tracing    TracingConfig `mapstructure:"tracing"`
+		Log        LogConfig     `mapstructure:"log"`
 	}
 
 	HTTPConfig struct {
-		Host string `mapstructure:"host"`
-		Port string `mapstructure:"port"`
+		Host             string        `mapstructure:"host"`
+		Port             string        `mapstructure:"port"`
+		RequestTimeout   time.Duration `mapstructure:"request_timeout"`
+		ReadinessTimeout time.Duration `mapstructure:"readiness_timeout"`
+		ShutdownDelay    time.Duration `mapstructure:"shutdown_delay"`
+	}
+
+	LogConfig struct {
+		Level  string `mapstructure:"level"`
+		Format string `mapstructure:"format"`
+	}
+
+	TracingConfig struct {
+		Exporter    string  `mapstructure:"exporter"`
+		File        string  `mapstructure:"file"`
+		Endpoint    string  `mapstructure:"endpoint"`
+		Insecure    bool    `mapstructure:"insecure"`
+		SampleRatio float64 `mapstructure:"sample_ratio"`
 	}
 
 	PGConfig struct {
-		Host     string `mapstructure:"host"`
-		Port     string `mapstructure:"port"`
-		Username string `mapstructure:"username"`
-		Password string `mapstructure:"password"`
-		DBName   string `mapstructure:"dbname"`
-		SSLMode  string `mapstructure:"sslmode"`
+		Host        string `mapstructure:"host"`
+		Port        string `mapstructure:"port"`
+		Username    string `mapstructure:"username"`
+		Password    string `mapstructure:"password"`
+		DBName      string `mapstructure:"dbname"`
+		SSLMode     string `mapstructure:"sslmode"`
+		AutoMigrate bool   `mapstructure:"auto_migrate"`
 	}
 )
 
-func Init(path string, logger *log.Logger) (*Config, error) {
+// envPrefix prefixes the environment variables overriding the settings.
+const envPrefix = "PAYMENT"
+
+// secrets are the keys redacted by Print.
+var secrets = []string{"postgres.password"}
+
+func Init(path string, logger *slog.Logger) (*Config, error) {
 	if err := parseConfigFile(path); err != nil {
-		logger.Printf("failed to parse path to config file: %s", err)
+		logger.Error("failed to read config file", "path", path, "error", err)
 		return nil, err
 	}
 
-	var cfg Config
-	if err := unmarshal(&cfg, logger); err != nil {
-		logger.Printf("failed to unmarshal config: %s", err)
+	if err := bindEnv(); err != nil {
+		logger.Error("failed to read config from environment", "error", err)
 		return nil, err
 	}
 
-	return &cfg, nil
-}
-
-func unmarshal(cfg *Config, logger *log.Logger) error {
-	if err := viper.UnmarshalKey("http", &cfg.HTTP); err != nil {
-		logger.Printf("failed to unmarshal http key in config: %s", err)
-		return err
-	}
-
-	if err := viper.UnmarshalKey("postgres", &cfg.PostgreSQL); err != nil {
-		logger.Printf("failed to unmarshal postgres key in config: %s", err)
-		return err
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		logger.Error("failed to unmarshal config", "error", err)
+		return nil, err
 	}
 
-	return nil
+	return &cfg, nil
 }
 
-func parseConfigFile(filepath string) error {
-	path := strings.Split(filepath, "/")
-
-	viper.AddConfigPath(path[0])
-	viper.SetConfigName(path[1])
+func parseConfigFile(path string) error {
+	viper.SetConfigFile(path)
 
 	return viper.ReadInConfig()
 }
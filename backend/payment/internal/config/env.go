@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[redacted]"
+
+// bindEnv makes every setting overridable by an environment variable named
+// after its key, e.g. postgres.password by <PREFIX>_POSTGRES_PASSWORD. Secrets
+// can be mounted as files instead, the variable with a _FILE suffix holds
+// the path of the file with the value.
+func bindEnv() error {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+
+	for _, key := range keys(reflect.TypeOf(Config{}), "") {
+		if err := viper.BindEnv(key); err != nil {
+			return err
+		}
+		if err := readSecretFile(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readSecretFile(key string) error {
+	name := envName(key)
+	path, ok := os.LookupEnv(name + "_FILE")
+	if !ok {
+		return nil
+	}
+	if _, ok := os.LookupEnv(name); ok {
+		return fmt.Errorf("both %s and %s_FILE are set", name, name)
+	}
+
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	viper.Set(key, strings.TrimRight(string(value), "\r\n"))
+
+	return nil
+}
+
+func envName(key string) string {
+	return strings.ToUpper(envPrefix + "_" + strings.ReplaceAll(key, ".", "_"))
+}
+
+// keys lists the keys of all settings of typ. Nested sections are walked,
+// lists and maps are single settings.
+func keys(typ reflect.Type, prefix string) []string {
+	var result []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			result = append(result, keys(field.Type, prefix+key+".")...)
+			continue
+		}
+		result = append(result, prefix+key)
+	}
+
+	return result
+}
+
+// Print writes the effective config, with the environment applied, as YAML.
+// Secrets are redacted.
+func Print(w io.Writer) error {
+	settings := viper.AllSettings()
+	for _, key := range secrets {
+		redact(settings, strings.Split(key, "."))
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(settings); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+func redact(settings map[string]interface{}, path []string) {
+	value, ok := settings[path[0]]
+	if !ok {
+		return
+	}
+
+	if len(path) > 1 {
+		if section, ok := value.(map[string]interface{}); ok {
+			redact(section, path[1:])
+		}
+		return
+	}
+
+	if value != "" {
+		settings[path[0]] = redacted
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Validate reports every missing or insecure setting, the service refuses
+// to start with any of them.
+func (c *Config) Validate() error {
+	var errs []error
+	errs = append(errs, c.HTTP.validate()...)
+	errs = append(errs, c.PostgreSQL.validate()...)
+	errs = append(errs, c.Tracing.validate()...)
+
+	return errors.Join(errs...)
+}
+
+func (c HTTPConfig) validate() []error {
+	var errs []error
+	if err := validatePort("http.port", c.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.RequestTimeout < 0 || c.ReadinessTimeout < 0 || c.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("http timeouts must not be negative"))
+	}
+
+	return errs
+}
+
+func (c PGConfig) validate() []error {
+	var errs []error
+	if c.Host == "" {
+		errs = append(errs, required("postgres.host"))
+	}
+	if err := validatePort("postgres.port", c.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Username == "" {
+		errs = append(errs, required("postgres.username"))
+	}
+	if c.Password == "" {
+		errs = append(errs, required("postgres.password"))
+	}
+	if c.DBName == "" {
+		errs = append(errs, required("postgres.dbname"))
+	}
+	switch c.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("postgres.sslmode %q is not a libpq sslmode", c.SSLMode))
+	}
+
+	return errs
+}
+
+func (c TracingConfig) validate() []error {
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return []error{fmt.Errorf("tracing.sample_ratio %v is not between 0 and 1", c.SampleRatio)}
+	}
+
+	return nil
+}
+
+func validatePort(key, port string) error {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s %q is not a port", key, port)
+	}
+
+	return nil
+}
+
+// required reports a missing setting along with the environment variables
+// that can provide it.
+func required(key string) error {
+	return fmt.Errorf("%s is required, set it in the config file or with %s or %s_FILE",
+		key, envName(key), envName(key))
+}
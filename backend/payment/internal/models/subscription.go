@@ -8,6 +8,12 @@ type Subscription struct {
 	Status 		int `json:"status" db:"status"`
 }
 
+// UserSubscription is the subscription type a user has paid for and is active now.
+type UserSubscription struct {
+	UserId 				string `json:"userId" db:"user_id"`
+	SubscriptionType 	int `json:"subscriptionType" db:"subscription_type"`
+}
+
 const (
 	User = iota
 	PrimeUser
@@ -1,17 +1,20 @@
 package payments
 
 import (
+	"errors"
+
 	"github.com/Feokrat/music-dating-app/payment/internal/payments/repositories"
 	"github.com/Feokrat/music-dating-app/payment/internal/payments/schemas"
+	"github.com/Feokrat/music-dating-app/payment/pkg/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 )
 
 type handler struct {
-	logger *log.Logger
+	logger *slog.Logger
 	service PaymentsService
 }
 
@@ -20,43 +23,29 @@ func (h handler) CreatePayment(ctx *gin.Context) {
 	subscriptionType := ctx.Query("subscription_type")
 	_, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id", err))
 
 		return
 	}
 
 	i, err := strconv.Atoi(subscriptionType);
 	if err != nil {
-		h.logger.Printf("error has occured in subscription type conversation %v, error: %s",
-			subscriptionType, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong subscription type format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "error has occurred in subscription type conversation", "subscription_type", subscriptionType, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("subscription_type", err))
 
 		return
 	}
 
-	paymentId, err := h.service.CreatePayment(userIdStr, i)
+	paymentId, err := h.service.CreatePayment(ctx.Request.Context(), userIdStr, i)
 	if err != nil {
 		switch err {
 		case repositories.PaymentAlreadyExists:
-			h.logger.Printf("could not create payment for user %v, error: %s",
-				userIdStr, err.Error())
-			ctx.JSON(http.StatusConflict, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			h.logger.ErrorContext(ctx.Request.Context(), "could not create payment for user", "user_id", userIdStr, "error", err)
+			problem.Respond(ctx, problem.Conflict(err.Error()))
 		default:
-			h.logger.Printf("could not create payment for user %v, error: %s",
-				userIdStr, err.Error())
-			ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			h.logger.ErrorContext(ctx.Request.Context(), "could not create payment for user", "user_id", userIdStr, "error", err)
+			problem.Respond(ctx, err)
 		}
 
 		return
@@ -69,51 +58,69 @@ func (h handler) GetPayment(ctx *gin.Context) {
 	userIdStr := ctx.Param("user_id")
 	_, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id", err))
+
+		return
+	}
+
+	payment, err := h.service.GetPaymentByUserId(ctx.Request.Context(), userIdStr)
+	if err != nil {
+		if errors.Is(err, repositories.NotFoundError) {
+			problem.Respond(ctx, problem.NotFound("user has no active subscription"))
+			return
+		}
+
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get payment of user", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
-	payment, err := h.service.GetPaymentByUserId(userIdStr)
+	ctx.JSON(http.StatusOK, schemas.PaymentModelResponse{Payment: payment})
+}
+
+// GetSubscriptions returns the active subscriptions of all the user_id users at once.
+func (h handler) GetSubscriptions(ctx *gin.Context) {
+	userIds := ctx.QueryArray("user_id")
+	if len(userIds) == 0 {
+		problem.Respond(ctx, problem.InvalidParam("user_id", errors.New("at least one user id is required")))
+		return
+	}
+	for _, userIdStr := range userIds {
+		if _, err := uuid.Parse(userIdStr); err != nil {
+			h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+			problem.Respond(ctx, problem.InvalidParam("user_id", err))
+
+			return
+		}
+	}
+
+	subscriptions, err := h.service.GetSubscriptions(ctx.Request.Context(), userIds)
 	if err != nil {
-		h.logger.Printf("could not create payment for user %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get subscriptions of users", "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
-	ctx.JSON(http.StatusOK, schemas.PaymentModelResponse{payment})
+	ctx.JSON(http.StatusOK, schemas.SubscriptionsResponse{Subscriptions: subscriptions})
 }
 
 func (h handler) UpdatePayment(ctx *gin.Context) {
 	userIdStr := ctx.Param("user_id")
 	_, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("user_id", err))
 
 		return
 	}
 
-	err = h.service.CancelPayment(userIdStr)
+	err = h.service.CancelPayment(ctx.Request.Context(), userIdStr)
 	if err != nil {
-		h.logger.Printf("could not cancel payment for user %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not cancel payment for user", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -121,13 +128,14 @@ func (h handler) UpdatePayment(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, nil)
 }
 
-func RegisterHandlers(rg *gin.RouterGroup, service PaymentsService, logger *log.Logger) {
+func RegisterHandlers(rg *gin.RouterGroup, service PaymentsService, logger *slog.Logger) {
 	h := handler{
 		logger,
 		service,
 	}
 	// sessions?test=...&m=sd
 	rg.GET("/:user_id", h.GetPayment)
+	rg.GET("/", h.GetSubscriptions)
 	rg.POST("/", h.CreatePayment)
 	rg.PUT("/:user_id", h.UpdatePayment)
 
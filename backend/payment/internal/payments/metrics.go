@@ -0,0 +1,18 @@
+package payments
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	paymentsCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "payments_created_total",
+		Help: "Number of subscriptions paid.",
+	})
+
+	paymentsCancelledTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "payments_cancelled_total",
+		Help: "Number of subscriptions cancelled.",
+	})
+)
@@ -1,87 +1,102 @@
 package repositories
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"github.com/Feokrat/music-dating-app/payment/internal/models"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	"log"
+	"github.com/lib/pq"
+	"log/slog"
 	"time"
 )
 
 const (
 	paymentsTable = "payments"
 	active        = "active"
-	cancelled     = "canceled"
+	cancelled     = "cancelled"
 )
 
 type paymentsRepository struct {
 	db     *sqlx.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
-func (p paymentsRepository) CancellPayment(userId string) error {
+func (p paymentsRepository) CancellPayment(ctx context.Context, userId string) error {
 	query := fmt.Sprintf(`UPDATE %s SET status=$1 WHERE user_id = $2 AND status = $3`, paymentsTable)
-	_, err := p.db.Exec(query, cancelled, userId, active)
+	_, err := p.db.ExecContext(ctx, query, cancelled, userId, active)
 	return err
 }
 
-func (p paymentsRepository) GetPaymentsByUserId(userId string) (models.Payment, error) {
+func (p paymentsRepository) GetPaymentsByUserId(ctx context.Context, userId string) (models.Payment, error) {
 	var payment []models.Payment
 	var date = time.Now()
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1 AND active_till_to > $2`, paymentsTable)
-	err := p.db.Select(&payment, query, userId, date)
-	if err == sql.ErrNoRows {
+	err := p.db.SelectContext(ctx, &payment, query, userId, date)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if len(payment) == 0 {
 		return models.Payment{}, NotFoundError
 	}
-	return payment[0], err
+	return payment[0], nil
+}
+
+// GetSubscriptions returns the best active subscription of each of the users, users without one are left out.
+func (p paymentsRepository) GetSubscriptions(ctx context.Context, userIds []string) ([]models.UserSubscription, error) {
+	subscriptions := make([]models.UserSubscription, 0)
+	query := fmt.Sprintf(`SELECT DISTINCT ON (user_id) user_id, COALESCE(subscription_type, $3) AS subscription_type
+		FROM %s WHERE user_id = ANY($1) AND active_till_to > $2
+		ORDER BY user_id, subscription_type DESC NULLS LAST`, paymentsTable)
+	err := p.db.SelectContext(ctx, &subscriptions, query, pq.Array(userIds), time.Now(), models.User)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "error in db while trying to get subscriptions of users", "error", err)
+		return nil, err
+	}
+	return subscriptions, nil
 }
 
-func (p paymentsRepository) CreatePayment(userId string, subscriptionType int) (string, error) {
+func (p paymentsRepository) CreatePayment(ctx context.Context, userId string, subscriptionType int) (string, error) {
 	var paymentId = uuid.New().String()
 	var date = time.Now().AddDate(0, 1, 0)
-	if res, err := p.CheckIfSubscriptionExists(userId, subscriptionType); err != nil {
-		p.logger.Printf("error in db while trying to create payment %v, error: %s",
-			paymentId, err.Error())
+	if res, err := p.CheckIfSubscriptionExists(ctx, userId, subscriptionType); err != nil {
+		p.logger.ErrorContext(ctx, "error in db while trying to create payment", "payment_id", paymentId, "error", err)
 		return "", err
 	} else {
 		if res != true {
-			p.logger.Printf("error in db while trying to create payment %v. Payments count not equals zero",
-				paymentId)
+			p.logger.WarnContext(ctx, "user already has a subscription of this type", "user_id", userId, "subscription_type", subscriptionType)
 			return "", PaymentAlreadyExists
 		}
 	}
 	query := fmt.Sprintf(`INSERT INTO %s VALUES ($1, $2, $3, $4, $5) RETURNING id`, paymentsTable)
-	row := p.db.QueryRow(query, paymentId, userId, subscriptionType, date, active)
+	row := p.db.QueryRowContext(ctx, query, paymentId, userId, subscriptionType, date, active)
 
 	if err := row.Scan(&paymentId); err != nil {
-		p.logger.Printf("error in db while trying to create payment %v, error: %s",
-			paymentId, err.Error())
+		p.logger.ErrorContext(ctx, "error in db while trying to create payment", "payment_id", paymentId, "error", err)
 		return "", err
 	}
 
 	return paymentId, nil
 }
 
-func (p paymentsRepository) CheckIfSubscriptionExists(userId string, subscriptionType int) (bool, error) {
+func (p paymentsRepository) CheckIfSubscriptionExists(ctx context.Context, userId string, subscriptionType int) (bool, error) {
 	var paymentsCount int
 	query := fmt.Sprintf(`SELECT COUNT(*) as count FROM %s WHERE user_id = $1 AND subscription_type = $2 AND status=$3`, paymentsTable)
-	err := p.db.QueryRow(query, userId, subscriptionType, active).Scan(&paymentsCount)
+	err := p.db.QueryRowContext(ctx, query, userId, subscriptionType, active).Scan(&paymentsCount)
 	if err != nil {
-		p.logger.Printf("error in db while trying to check payments count %v, error: %s",
-			paymentsCount, err.Error())
+		p.logger.ErrorContext(ctx, "error in db while trying to check payments count", "payments_count", paymentsCount, "error", err)
 		return false, err
 	}
 	return paymentsCount == 0, err
 }
 
 type PaymentsRepository interface {
-	GetPaymentsByUserId(userId string) (models.Payment, error)
-	CreatePayment(userId string, subscriptionType int) (string, error)
-	CancellPayment(userId string) error
+	GetPaymentsByUserId(ctx context.Context, userId string) (models.Payment, error)
+	GetSubscriptions(ctx context.Context, userIds []string) ([]models.UserSubscription, error)
+	CreatePayment(ctx context.Context, userId string, subscriptionType int) (string, error)
+	CancellPayment(ctx context.Context, userId string) error
 }
 
-func NewPaymentsRepository(db *sqlx.DB, logger *log.Logger) PaymentsRepository {
+func NewPaymentsRepository(db *sqlx.DB, logger *slog.Logger) PaymentsRepository {
 	return paymentsRepository{db, logger}
 }
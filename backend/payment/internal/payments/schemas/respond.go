@@ -2,27 +2,14 @@ package schemas
 
 import "github.com/Feokrat/music-dating-app/payment/internal/models"
 
-type ValidationErrorResponse struct {
-	Message string `json:"message"`
-	Errors  string `json:"errors"`
-}
-
 type PaymentResponse struct {
 	PaymentId string `json:"paymentId"`
 }
 
-type ErrorResponse struct {
-	Message string `json:"message"`
-}
-
-func (e ErrorResponse) Error() string {
-	return e.Message
+type PaymentModelResponse struct {
+	Payment models.Payment `json:"payment"`
 }
 
-type NotFoundError struct {
-	Message string `json:"message"`
+type SubscriptionsResponse struct {
+	Subscriptions []models.UserSubscription `json:"subscriptions"`
 }
-
-type PaymentModelResponse struct {
-	Payment models.Payment `json:"payment"`
-}
\ No newline at end of file
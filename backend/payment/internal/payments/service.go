@@ -1,37 +1,51 @@
 package payments
 
 import (
+	"context"
 	"github.com/Feokrat/music-dating-app/payment/internal/models"
 	"github.com/Feokrat/music-dating-app/payment/internal/payments/repositories"
-	"log"
+	"log/slog"
 )
 
 type paymentsService struct {
-	logger *log.Logger
+	logger *slog.Logger
 	paymentsRepository repositories.PaymentsRepository
 }
 
-func (p paymentsService) CreatePayment(userId string, subscriptionType int) (string, error) {
+func (p paymentsService) CreatePayment(ctx context.Context, userId string, subscriptionType int) (string, error) {
 	// получить все
 	// проверить, если есть закенселенная, но работающая, то изменить статус
 	// иначе создать новую
-	return p.paymentsRepository.CreatePayment(userId, subscriptionType)
+	paymentId, err := p.paymentsRepository.CreatePayment(ctx, userId, subscriptionType)
+	if err == nil {
+		paymentsCreatedTotal.Inc()
+	}
+	return paymentId, err
 }
 
-func (p paymentsService) CancelPayment(userId string) error {
-	return p.paymentsRepository.CancellPayment(userId)
+func (p paymentsService) CancelPayment(ctx context.Context, userId string) error {
+	err := p.paymentsRepository.CancellPayment(ctx, userId)
+	if err == nil {
+		paymentsCancelledTotal.Inc()
+	}
+	return err
 }
 
-func (p paymentsService) GetPaymentByUserId(userId string) (models.Payment, error) {
-	return p.paymentsRepository.GetPaymentsByUserId(userId)
+func (p paymentsService) GetPaymentByUserId(ctx context.Context, userId string) (models.Payment, error) {
+	return p.paymentsRepository.GetPaymentsByUserId(ctx, userId)
+}
+
+func (p paymentsService) GetSubscriptions(ctx context.Context, userIds []string) ([]models.UserSubscription, error) {
+	return p.paymentsRepository.GetSubscriptions(ctx, userIds)
 }
 
 type PaymentsService interface {
-	CreatePayment(userId string, subscriptionType int) (string, error)
-	CancelPayment(userId string) error
-	GetPaymentByUserId(userId string) (models.Payment, error)
+	CreatePayment(ctx context.Context, userId string, subscriptionType int) (string, error)
+	CancelPayment(ctx context.Context, userId string) error
+	GetPaymentByUserId(ctx context.Context, userId string) (models.Payment, error)
+	GetSubscriptions(ctx context.Context, userIds []string) ([]models.UserSubscription, error)
 }
 
-func NewPaymentService(logger *log.Logger, paymentsRepository repositories.PaymentsRepository) PaymentsService {
+func NewPaymentService(logger *slog.Logger, paymentsRepository repositories.PaymentsRepository) PaymentsService {
 	return paymentsService{logger, paymentsRepository}
 }
\ No newline at end of file
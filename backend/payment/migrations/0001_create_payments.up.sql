@@ -1,6 +1,6 @@
-create database payments;
-
-CREATE TABLE payments (
+-- the tables may already exist in databases created with the former
+-- dbScripts/subscription.sql, which are adopted as they are
+CREATE TABLE IF NOT EXISTS payments (
     id                  uuid PRIMARY KEY,
     user_id             VARCHAR(255) NOT NULL,
     subscription_type   INT,
@@ -8,13 +8,14 @@ CREATE TABLE payments (
     status              VARCHAR(80) NOT NULL CHECK (status in ('active', 'cancelled'))
 );
 
-CREATE TABLE subscriptions (
+CREATE TABLE IF NOT EXISTS subscriptions (
     id                  INT PRIMARY KEY,
     name                VARCHAR(255) NOT NULL,
     description         VARCHAR(1023) NOT NULL,
     price               INT NOT NULL
 );
 
-INSERT INTO subscriptions VALUES 
-(0, 'Light', 'This is light subscription', 0), 
-(1, 'Prime', 'This is primary subscription. Allows to chat with anyone you want.', 1500);
\ No newline at end of file
+INSERT INTO subscriptions VALUES
+(0, 'Light', 'This is light subscription', 0),
+(1, 'Prime', 'This is primary subscription. Allows to chat with anyone you want.', 1500)
+ON CONFLICT (id) DO NOTHING;
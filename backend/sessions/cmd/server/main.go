@@ -2,49 +2,159 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/Feokrat/music-dating-app/sessions/pkg/hash/bcrypt"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/mail"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/mail/file"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/mail/smtp"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/otp/totp"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
 
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
 	"github.com/Feokrat/music-dating-app/sessions/pkg/token/jwt"
+	"github.com/google/uuid"
 
 	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
 
+	"github.com/Feokrat/music-dating-app/sessions/internal/payments"
 	"github.com/Feokrat/music-dating-app/sessions/internal/sessions"
+	"github.com/Feokrat/music-dating-app/sessions/internal/users"
 	"github.com/Feokrat/music-dating-app/sessions/pkg/database"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/health"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/logging"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/migrate"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/openapi"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/problem"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/Feokrat/music-dating-app/sessions/api"
 	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/internal/middleware"
+	"github.com/Feokrat/music-dating-app/sessions/migrations"
 	"github.com/Feokrat/music-dating-app/sessions/pkg/HTTPserver"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var configFile = "configs/config"
+var configFile = flag.String("config", "configs/config.yml",
+	"path to the config file, its settings can be overridden by environment variables")
+
+const serviceName = "sessions"
 
 func main() {
-	logger := log.New(os.Stdout, "logger: ", log.Lshortfile)
+	flag.Parse()
+	args := flag.Args()
+
+	// used until the configured logger can be built
+	bootLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	cfg, err := config.Init(*configFile, bootLogger)
+	if err != nil {
+		bootLogger.Error("failed to load application configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "config" {
+		if err := runConfig(args[1:]); err != nil {
+			bootLogger.Error("failed to run config command", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		bootLogger.Error("invalid application configuration", "error", err)
+		os.Exit(1)
+	}
 
-	cfg, err := config.Init(configFile, logger)
+	logger, err := logging.NewLogger(cfg.Log, os.Stdout)
 	if err != nil {
-		logger.Fatalf("failed to load application configuration: %s", err)
+		bootLogger.Error("failed to set up logging", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.NewTracerProvider(cfg.Tracing, serviceName)
+	if err != nil {
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
 	}
 
 	db, err := database.NewPostgresDB(cfg.PostgreSQL, logger)
 	if err != nil {
-		logger.Fatalf("%s", err)
+		logger.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, cfg.PostgreSQL.DBName))
+
+	migrator, err := migrate.NewMigrator(db, migrations.FS, logger)
+	if err != nil {
+		logger.Error("failed to load migrations", "error", err)
+		os.Exit(1)
 	}
 
-	handlers := buildHandler(logger, db, cfg)
-	server := HTTPserver.NewHTTPserver(cfg, handlers)
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(context.Background(), migrator, args[1:], logger); err != nil {
+			logger.Error("failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.PostgreSQL.AutoMigrate {
+		if err := migrator.Up(context.Background()); err != nil {
+			logger.Error("failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	tokenService, err := buildTokenService(cfg.Token, logger)
+	if err != nil {
+		logger.Error("failed to create token service", "error", err)
+		os.Exit(1)
+	}
+
+	passwordPolicy, err := buildPasswordPolicy(cfg.Password)
+	if err != nil {
+		logger.Error("failed to load password policy", "error", err)
+		os.Exit(1)
+	}
+
+	mailer, err := buildMailer(cfg.Mail, logger)
+	if err != nil {
+		logger.Error("failed to create mailer", "error", err)
+		os.Exit(1)
+	}
+
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	checker := health.NewChecker(cfg.HTTP.ReadinessTimeout)
+	checker.Add("postgres", db.PingContext)
+
+	handlers, err := buildHandler(jobs, logger, db, cfg, tokenService, passwordPolicy, mailer, checker)
+	if err != nil {
+		logger.Error("failed to build http handler", "error", err)
+		os.Exit(1)
+	}
+	server := HTTPserver.NewHTTPserver(cfg, handlers, checker)
 
 	go func() {
-		if err := server.Run(); err != nil {
-			logger.Printf("error occurred while running http server: %s\n", err.Error())
+		if err := server.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("error occurred while running http server", "error", err)
 		}
 	}()
 
@@ -53,34 +163,193 @@ func main() {
 	signal.Notify(c, syscall.SIGTERM)
 
 	sig := <-c
-	logger.Println("Got signal:", sig)
-	logger.Print("Shutting down server")
+	logger.Info("got signal", "signal", sig.String())
+	logger.Info("shutting down server")
 
 	ctx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdown()
 
 	server.Stop(ctx)
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
 }
 
-func buildHandler(logger *log.Logger, db *sqlx.DB, cfg *config.Config) http.Handler {
-	router := gin.Default()
+func buildHandler(jobs context.Context, logger *slog.Logger, db *sqlx.DB, cfg *config.Config,
+	tokenService token.TokenService, passwordPolicy password.Policy, mailer mail.Mailer, checker *health.Checker) (http.Handler, error) {
+	doc, err := openapi.Load(api.Spec)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := openapi.Validator(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	router := gin.New()
+	// the client address of sign ins is taken from X-Forwarded-For, which only
+	// the gateway may set
+	if err := router.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		return nil, err
+	}
 
 	router.Use(
-		gin.Recovery(),
-		gin.Logger(),
+		problem.Recovery(),
+		middleware.RequestID(),
+		middleware.Logger(logger),
+		middleware.Tracing(serviceName),
+		middleware.Metrics(),
+		middleware.Timeout(cfg.HTTP.RequestTimeout),
+		validator,
 	)
 
+	router.NoRoute(problem.NoRoute)
+
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	health.RegisterHandlers(router, checker)
+	openapi.RegisterHandler(router, doc)
+
+	if keySet, ok := tokenService.(token.KeySet); ok {
+		sessions.RegisterJWKSHandler(router, keySet)
+	}
+
 	rg := router.Group("auth")
 	sessionRepository := repostiroties.NewSessionRepository(db, logger)
 	credentialRepository := repostiroties.NewCredentialsRepository(db, logger)
-	tokenService := jwt.NewJWTokenService(cfg.Token.SigningKey, cfg.Token.Duration)
+	refreshTokenRepository := repostiroties.NewRefreshTokenRepository(db, logger)
+	revokedTokenRepository := repostiroties.NewRevokedTokenRepository(db, logger)
+	loginAttemptRepository := repostiroties.NewLoginAttemptRepository(db, logger)
+	credentialTokenRepository := repostiroties.NewCredentialTokenRepository(db, logger)
+	mfaRepository := repostiroties.NewMFARepository(db, logger)
+	mfaChallengeRepository := repostiroties.NewMFAChallengeRepository(db, logger)
 	hashService := bcrypt.NewBcryptHashService(cfg.Hash.Cost)
-	sessionService := sessions.NewService(logger, credentialRepository, sessionRepository, tokenService, hashService)
+	throttle := sessions.NewLoginThrottle(logger, loginAttemptRepository, cfg.Lockout)
+	accountService := sessions.NewAccountService(logger, credentialRepository, credentialTokenRepository,
+		sessionRepository, hashService, passwordPolicy, throttle, mailer, users.NewService(cfg.Services, logger),
+		cfg.Mail)
+	mfaService := sessions.NewMFAService(logger, credentialRepository, sessionRepository, mfaRepository,
+		mfaChallengeRepository, payments.NewService(cfg.Services, logger), totp.NewTOTPService(cfg.MFA.Issuer),
+		hashService, cfg.MFA)
+	sessionService := sessions.NewService(logger, credentialRepository, sessionRepository, refreshTokenRepository,
+		revokedTokenRepository, tokenService, hashService, passwordPolicy, throttle, accountService, mfaService,
+		cfg.Token.Duration, cfg.Token.RefreshDuration)
+
+	if cfg.Token.CleanupInterval > 0 {
+		go sessionService.CleanupRevokedTokens(jobs, cfg.Token.CleanupInterval)
+	}
 
 	sessions.RegisterHandlers(rg, sessionService, logger)
-	return router
+	sessions.RegisterAccountHandlers(rg, sessionService, accountService, logger)
+	sessions.RegisterMFAHandlers(rg, sessionService, mfaService, logger)
+	return router, nil
+}
+
+func buildTokenService(cfg config.TokenConfig, logger *slog.Logger) (token.TokenService, error) {
+	switch cfg.Algorithm {
+	case "", "HS256":
+		return jwt.NewJWTokenService(cfg.SigningKey, cfg.Duration), nil
+	case "RS256":
+		keys := make([]jwt.RSAKey, 0, len(cfg.Keys))
+		for _, keyCfg := range cfg.Keys {
+			pem, err := os.ReadFile(keyCfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			key, err := jwt.ParseRSAKey(keyCfg.Id, pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse key %s: %w", keyCfg.Id, err)
+			}
+			keys = append(keys, key)
+		}
+
+		// validation only lets this through with token.ephemeral_key
+		if len(keys) == 0 {
+			logger.Warn("no token signing keys configured, generating a temporary one")
+			key, err := jwt.GenerateRSAKey(uuid.New().String())
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+
+		return jwt.NewRSATokenService(keys, cfg.SigningKeyId, cfg.Duration)
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %s", cfg.Algorithm)
+	}
+}
+
+func buildPasswordPolicy(cfg config.PasswordConfig) (password.Policy, error) {
+	policy := password.Policy{
+		MinLength:     cfg.MinLength,
+		RequireUpper:  cfg.RequireUpper,
+		RequireLower:  cfg.RequireLower,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+	}
+	if cfg.DenylistFile == "" {
+		return policy, nil
+	}
+
+	denylist, err := password.LoadDenylist(cfg.DenylistFile)
+	if err != nil {
+		return password.Policy{}, err
+	}
+	policy.Denylist = denylist
+	return policy, nil
+}
+
+func buildMailer(cfg config.MailConfig, logger *slog.Logger) (mail.Mailer, error) {
+	switch cfg.Driver {
+	case "", "file":
+		return file.NewFileMailer(cfg.File, logger), nil
+	case "smtp":
+		return smtp.NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %s", cfg.Driver)
+	}
+}
+
+// runConfig handles "config print", which shows the effective config with
+// secrets redacted.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("unknown config command, expected print")
+	}
+
+	return config.Print(os.Stdout)
+}
+
+// runMigrate handles "migrate up", "migrate down [steps]" and "migrate version".
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string, logger *slog.Logger) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("schema version", "version", version)
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q, expected up, down or version", command)
 }
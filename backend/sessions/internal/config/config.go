@@ -1,8 +1,7 @@
 package config
 
 import (
-	"log"
-	"strings"
+	"log/slog"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,75 +9,186 @@ import (
 
 type (
 	Config struct {
-		HTTP       HTTPConfig
-		PostgreSQL PGConfig
-		Token      TokenConfig
-		Hash       HashConfig
+		HTTP       HTTPConfig     `mapstructure:"http"`
+		PostgreSQL PGConfig       `mapstructure:"postgres"`
+		Token      TokenConfig    `mapstructure:"token"`
+		Hash       HashConfig     `mapstructure:"hash"`
+		Password   PasswordConfig `mapstructure:"password"`
+		Lockout    LockoutConfig  `mapstructure:"lockout"`
+		Mail       MailConfig     `mapstructure:"mail"`
+		MFA        MFAConfig      `mapstructure:"mfa"`
+		Services   ServicesConfig `mapstructure:"services"`
+		Tracing    TracingConfig  `mapstructure:"tracing"`
+		Log        LogConfig      `mapstructure:"log"`
 	}
 
 	HTTPConfig struct {
-		Host string `mapstructure:"host"`
-		Port string `mapstructure:"port"`
+		Host             string        `mapstructure:"host"`
+		Port             string        `mapstructure:"port"`
+		RequestTimeout   time.Duration `mapstructure:"request_timeout"`
+		ReadinessTimeout time.Duration `mapstructure:"readiness_timeout"`
+		ShutdownDelay    time.Duration `mapstructure:"shutdown_delay"`
+		TrustedProxies   []string      `mapstructure:"trusted_proxies"`
+	}
+
+	LogConfig struct {
+		Level  string `mapstructure:"level"`
+		Format string `mapstructure:"format"`
+	}
+
+	TracingConfig struct {
+		Exporter    string  `mapstructure:"exporter"`
+		File        string  `mapstructure:"file"`
+		Endpoint    string  `mapstructure:"endpoint"`
+		Insecure    bool    `mapstructure:"insecure"`
+		SampleRatio float64 `mapstructure:"sample_ratio"`
 	}
 
 	PGConfig struct {
+		Host        string `mapstructure:"host"`
+		Port        string `mapstructure:"port"`
+		Username    string `mapstructure:"username"`
+		Password    string `mapstructure:"password"`
+		DBName      string `mapstructure:"dbname"`
+		SSLMode     string `mapstructure:"sslmode"`
+		AutoMigrate bool   `mapstructure:"auto_migrate"`
+	}
+
+	TokenConfig struct {
+		SigningKey      string        `mapstructure:"signing_key"`
+		Duration        time.Duration `mapstructure:"duration"`
+		RefreshDuration time.Duration `mapstructure:"refresh_duration"`
+		CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+		Algorithm       string        `mapstructure:"algorithm"`
+		SigningKeyId    string        `mapstructure:"signing_key_id"`
+		Keys            []KeyConfig   `mapstructure:"keys"`
+		EphemeralKey    bool          `mapstructure:"ephemeral_key"`
+	}
+
+	KeyConfig struct {
+		Id             string `mapstructure:"id"`
+		PrivateKeyFile string `mapstructure:"private_key_file"`
+	}
+
+	HashConfig struct {
+		Cost int `mapstructure:"cost"`
+	}
+
+	PasswordConfig struct {
+		MinLength     int    `mapstructure:"min_length"`
+		RequireUpper  bool   `mapstructure:"require_upper"`
+		RequireLower  bool   `mapstructure:"require_lower"`
+		RequireDigit  bool   `mapstructure:"require_digit"`
+		RequireSymbol bool   `mapstructure:"require_symbol"`
+		DenylistFile  string `mapstructure:"denylist_file"`
+	}
+
+	LockoutConfig struct {
+		LoginFreeAttempts int           `mapstructure:"login_free_attempts"`
+		IPFreeAttempts    int           `mapstructure:"ip_free_attempts"`
+		BaseDelay         time.Duration `mapstructure:"base_delay"`
+		MaxDelay          time.Duration `mapstructure:"max_delay"`
+		Window            time.Duration `mapstructure:"window"`
+	}
+
+	MailConfig struct {
+		Driver    string        `mapstructure:"driver"`
+		From      string        `mapstructure:"from"`
+		File      string        `mapstructure:"file"`
+		SMTP      SMTPConfig    `mapstructure:"smtp"`
+		VerifyURL string        `mapstructure:"verify_url"`
+		ResetURL  string        `mapstructure:"reset_url"`
+		VerifyTTL time.Duration `mapstructure:"verify_ttl"`
+		ResetTTL  time.Duration `mapstructure:"reset_ttl"`
+	}
+
+	SMTPConfig struct {
 		Host     string `mapstructure:"host"`
 		Port     string `mapstructure:"port"`
 		Username string `mapstructure:"username"`
 		Password string `mapstructure:"password"`
-		DBName   string `mapstructure:"dbname"`
-		SSLMode  string `mapstructure:"sslmode"`
 	}
 
-	TokenConfig struct {
-		SigningKey string
-		Duration   time.Duration `mapstructure:"duration"`
+	MFAConfig struct {
+		Issuer        string        `mapstructure:"issuer"`
+		ChallengeTTL  time.Duration `mapstructure:"challenge_ttl"`
+		MaxAttempts   int           `mapstructure:"max_attempts"`
+		RecoveryCodes int           `mapstructure:"recovery_codes"`
 	}
 
-	HashConfig struct {
-		Cost int
+	ServicesConfig struct {
+		UserService    string                  `mapstructure:"user_service"`
+		PaymentService string                  `mapstructure:"payment_service"`
+		Clients        map[string]ClientConfig `mapstructure:"clients"`
 	}
-)
 
-func Init(path string, logger *log.Logger) (*Config, error) {
-	if err := parseConfigFile(path); err != nil {
-		logger.Printf("failed to parse path to config file: %s", err)
-		return nil, err
+	// ClientConfig tunes calls to one upstream service, unset values are
+	// taken from the "default" entry of ServicesConfig.Clients.
+	ClientConfig struct {
+		Timeout          time.Duration `mapstructure:"timeout"`
+		Retries          int           `mapstructure:"retries"`
+		RetryBackoff     time.Duration `mapstructure:"retry_backoff"`
+		MaxBackoff       time.Duration `mapstructure:"max_backoff"`
+		FailureThreshold int           `mapstructure:"failure_threshold"`
+		OpenTimeout      time.Duration `mapstructure:"open_timeout"`
 	}
+)
 
-	var cfg Config
-	if err := unmarshal(&cfg, logger); err != nil {
-		logger.Printf("failed to unmarshal config: %s", err)
-		return nil, err
+// Client returns the client settings of the named upstream service.
+func (c ServicesConfig) Client(name string) ClientConfig {
+	cfg := c.Clients[name]
+	defaults := c.Clients["default"]
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = defaults.Retries
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = defaults.RetryBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaults.MaxBackoff
+	}
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = defaults.FailureThreshold
+	}
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = defaults.OpenTimeout
 	}
 
-	return &cfg, nil
+	return cfg
 }
 
-func unmarshal(cfg *Config, logger *log.Logger) error {
-	if err := viper.UnmarshalKey("http", &cfg.HTTP); err != nil {
-		logger.Printf("failed to unmarshal http key in config: %s", err)
-		return err
+// envPrefix prefixes the environment variables overriding the settings.
+const envPrefix = "SESSIONS"
+
+// secrets are the keys redacted by Print.
+var secrets = []string{"postgres.password", "token.signing_key", "mail.smtp.password"}
+
+func Init(path string, logger *slog.Logger) (*Config, error) {
+	if err := parseConfigFile(path); err != nil {
+		logger.Error("failed to read config file", "path", path, "error", err)
+		return nil, err
 	}
 
-	if err := viper.UnmarshalKey("postgres", &cfg.PostgreSQL); err != nil {
-		logger.Printf("failed to unmarshal postgres key in config: %s", err)
-		return err
+	if err := bindEnv(); err != nil {
+		logger.Error("failed to read config from environment", "error", err)
+		return nil, err
 	}
 
-	if err := viper.UnmarshalKey("token", &cfg.Token); err != nil {
-		logger.Printf("failed to unmarshal token key in config: %s", err)
-		return err
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		logger.Error("failed to unmarshal config", "error", err)
+		return nil, err
 	}
 
-	return nil
+	return &cfg, nil
 }
 
-func parseConfigFile(filepath string) error {
-	path := strings.Split(filepath, "/")
-
-	viper.AddConfigPath(path[0])
-	viper.SetConfigName(path[1])
+func parseConfigFile(path string) error {
+	viper.SetConfigFile(path)
 
 	return viper.ReadInConfig()
 }
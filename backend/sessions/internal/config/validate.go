@@ -0,0 +1,207 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minSigningKeyLength is the shortest HS256 key accepted, the size of the
+// SHA-256 output.
+const minSigningKeyLength = 32
+
+// Validate reports every missing or insecure setting, the service refuses
+// to start with any of them.
+func (c *Config) Validate() error {
+	var errs []error
+	errs = append(errs, c.HTTP.validate()...)
+	errs = append(errs, c.PostgreSQL.validate()...)
+	errs = append(errs, c.Token.validate()...)
+	errs = append(errs, c.Hash.validate()...)
+	errs = append(errs, c.Password.validate()...)
+	errs = append(errs, c.Mail.validate()...)
+	errs = append(errs, c.Services.validate()...)
+	errs = append(errs, c.Tracing.validate()...)
+
+	return errors.Join(errs...)
+}
+
+func (c HTTPConfig) validate() []error {
+	var errs []error
+	if err := validatePort("http.port", c.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.RequestTimeout < 0 || c.ReadinessTimeout < 0 || c.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("http timeouts must not be negative"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("http.trusted_proxies: %q is not an address or CIDR", proxy))
+			}
+		}
+	}
+
+	return errs
+}
+
+func (c PGConfig) validate() []error {
+	var errs []error
+	if c.Host == "" {
+		errs = append(errs, required("postgres.host"))
+	}
+	if err := validatePort("postgres.port", c.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Username == "" {
+		errs = append(errs, required("postgres.username"))
+	}
+	if c.Password == "" {
+		errs = append(errs, required("postgres.password"))
+	}
+	if c.DBName == "" {
+		errs = append(errs, required("postgres.dbname"))
+	}
+	switch c.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("postgres.sslmode %q is not a libpq sslmode", c.SSLMode))
+	}
+
+	return errs
+}
+
+func (c TokenConfig) validate() []error {
+	var errs []error
+	if c.Duration <= 0 {
+		errs = append(errs, errors.New("token.duration must be positive"))
+	}
+	if c.RefreshDuration <= c.Duration {
+		errs = append(errs, errors.New("token.refresh_duration must be longer than token.duration"))
+	}
+
+	switch c.Algorithm {
+	case "", "HS256":
+		if c.SigningKey == "" {
+			errs = append(errs, required("token.signing_key"))
+		} else if len(c.SigningKey) < minSigningKeyLength {
+			errs = append(errs, fmt.Errorf("token.signing_key is shorter than %d bytes", minSigningKeyLength))
+		}
+	case "RS256":
+		if len(c.Keys) == 0 && !c.EphemeralKey {
+			errs = append(errs, errors.New("token.keys is required with RS256, "+
+				"token.ephemeral_key generates a temporary key in development"))
+		}
+		ids := make(map[string]bool, len(c.Keys))
+		for i, key := range c.Keys {
+			if key.Id == "" || key.PrivateKeyFile == "" {
+				errs = append(errs, fmt.Errorf("token.keys[%d] needs an id and a private_key_file", i))
+			}
+			ids[key.Id] = true
+		}
+		if c.SigningKeyId != "" && !ids[c.SigningKeyId] {
+			errs = append(errs, fmt.Errorf("token.signing_key_id %q is not one of token.keys", c.SigningKeyId))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("token.algorithm %q is not supported, expected HS256 or RS256", c.Algorithm))
+	}
+
+	return errs
+}
+
+func (c HashConfig) validate() []error {
+	// zero leaves the cost to the library
+	if c.Cost != 0 && (c.Cost < bcrypt.DefaultCost || c.Cost > bcrypt.MaxCost) {
+		return []error{fmt.Errorf("hash.cost %d is not between %d and %d", c.Cost, bcrypt.DefaultCost, bcrypt.MaxCost)}
+	}
+
+	return nil
+}
+
+func (c PasswordConfig) validate() []error {
+	if c.MinLength < 8 {
+		return []error{fmt.Errorf("password.min_length %d is shorter than 8", c.MinLength)}
+	}
+
+	return nil
+}
+
+func (c MailConfig) validate() []error {
+	var errs []error
+	switch c.Driver {
+	case "", "file":
+	case "smtp":
+		if c.SMTP.Host == "" {
+			errs = append(errs, required("mail.smtp.host"))
+		}
+		if err := validatePort("mail.smtp.port", c.SMTP.Port); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.driver %q is not supported, expected file or smtp", c.Driver))
+	}
+	if err := validateURL("mail.verify_url", c.VerifyURL); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateURL("mail.reset_url", c.ResetURL); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+func (c ServicesConfig) validate() []error {
+	var errs []error
+	if err := validateURL("services.user_service", c.UserService); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateURL("services.payment_service", c.PaymentService); err != nil {
+		errs = append(errs, err)
+	}
+	for name, client := range c.Clients {
+		if client.Timeout < 0 || client.Retries < 0 || client.RetryBackoff < 0 || client.MaxBackoff < 0 ||
+			client.FailureThreshold < 0 || client.OpenTimeout < 0 {
+			errs = append(errs, fmt.Errorf("services.clients.%s must not have negative settings", name))
+		}
+	}
+
+	return errs
+}
+
+func (c TracingConfig) validate() []error {
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return []error{fmt.Errorf("tracing.sample_ratio %v is not between 0 and 1", c.SampleRatio)}
+	}
+
+	return nil
+}
+
+func validatePort(key, port string) error {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s %q is not a port", key, port)
+	}
+
+	return nil
+}
+
+func validateURL(key, value string) error {
+	if value == "" {
+		return required(key)
+	}
+	if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s %q is not an absolute URL", key, value)
+	}
+
+	return nil
+}
+
+// required reports a missing setting along with the environment variables
+// that can provide it.
+func required(key string) error {
+	return fmt.Errorf("%s is required, set it in the config file or with %s or %s_FILE",
+		key, envName(key), envName(key))
+}
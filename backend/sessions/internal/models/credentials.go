@@ -1,9 +1,48 @@
 package models
 
+import "time"
+
+const (
+	AdminRole       = "admin"
+	UserRole        = "user"
+	PrimaryUserRole = "primary_user"
+)
+
+const (
+	VerifyEmailPurpose   = "verify_email"
+	ResetPasswordPurpose = "reset_password"
+)
+
 type Credentials struct {
-	Id           string `json:"id" db:"id"`
-	Login        string `json:"login" db:"login"`
-	PasswordHash string `json:"passwordHash" db:"password_hash"`
-	SessionId    string `json:"sessionId" db:"session_id"`
-	Role         string `json:"role" db:"role"`
-}
\ No newline at end of file
+	Id            string `json:"id" db:"id"`
+	Login         string `json:"login" db:"login"`
+	PasswordHash  string `json:"passwordHash" db:"password_hash"`
+	UserId        string `json:"userId" db:"user_id"`
+	Role          string `json:"role" db:"role"`
+	Email         string `json:"email" db:"email"`
+	EmailVerified bool   `json:"emailVerified" db:"email_verified"`
+}
+
+// CredentialToken is a single-use token sent by mail to prove ownership of
+// the address, only its hash is stored.
+type CredentialToken struct {
+	TokenHash    string     `json:"-" db:"token_hash"`
+	CredentialId string     `json:"credentialId" db:"credential_id"`
+	Purpose      string     `json:"purpose" db:"purpose"`
+	ExpiresAt    time.Time  `json:"expiresAt" db:"expires_at"`
+	UsedAt       *time.Time `json:"usedAt" db:"used_at"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
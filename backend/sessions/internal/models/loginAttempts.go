@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	LoginAttemptKind = "login"
+	IPAttemptKind    = "ip"
+)
+
+// Lockout is an audit record of sign in being blocked for a login or an IP
+// address after repeated failures.
+type Lockout struct {
+	Id          string    `json:"id" db:"id"`
+	Kind        string    `json:"kind" db:"kind"`
+	Value       string    `json:"value" db:"value"`
+	Failures    int       `json:"failures" db:"failures"`
+	LockedUntil time.Time `json:"lockedUntil" db:"locked_until"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// MFA is the TOTP second factor of a credential. It is only enforced once
+// confirmed with a first code.
+type MFA struct {
+	CredentialId string    `json:"credentialId" db:"credential_id"`
+	Secret       string    `json:"-" db:"secret"`
+	Confirmed    bool      `json:"confirmed" db:"confirmed"`
+	LastUsedStep int64     `json:"-" db:"last_used_step"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
+
+type RecoveryCode struct {
+	Id           string     `json:"id" db:"id"`
+	CredentialId string     `json:"credentialId" db:"credential_id"`
+	CodeHash     string     `json:"-" db:"code_hash"`
+	UsedAt       *time.Time `json:"usedAt" db:"used_at"`
+}
+
+// MFAChallenge is issued by sign in when the password was right but a
+// second factor is still missing.
+type MFAChallenge struct {
+	TokenHash    string     `json:"-" db:"token_hash"`
+	CredentialId string     `json:"credentialId" db:"credential_id"`
+	Attempts     int        `json:"attempts" db:"attempts"`
+	ExpiresAt    time.Time  `json:"expiresAt" db:"expires_at"`
+	UsedAt       *time.Time `json:"usedAt" db:"used_at"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type Challenge struct {
+	Token     string
+	ExpiresIn time.Duration
+}
+
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+type MFACode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAVerify struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
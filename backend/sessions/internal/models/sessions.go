@@ -1,10 +1,51 @@
 package models
 
+import "time"
+
 type Sessions struct {
-	Id              string `json:"id" db:"id"`
-	RefreshToken    string `json:"refreshToken" db:"refresh_token"`
-	AccessToken     string `json:"accessToken" db:"access_token"`
-	ExpiresAt       string `json:"expiresAt" db:"expires_at"`
-	IsAuthenticated bool   `json:"isAuthenticated" db:"is_authenticated"`
-	UserID          string `json:"userId" db:"user_id"`
+	Id              string     `json:"id" db:"id"`
+	CredentialId    string     `json:"credentialId" db:"credential_id"`
+	UserID          string     `json:"userId" db:"user_id"`
+	UserAgent       string     `json:"userAgent" db:"user_agent"`
+	IP              string     `json:"ip" db:"ip"`
+	ExpiresAt       time.Time  `json:"expiresAt" db:"expires_at"`
+	LastSeenAt      time.Time  `json:"lastSeenAt" db:"last_seen_at"`
+	CreatedAt       time.Time  `json:"createdAt" db:"created_at"`
+	IsAuthenticated bool       `json:"isAuthenticated" db:"is_authenticated"`
+	RevokedAt       *time.Time `json:"-" db:"revoked_at"`
+}
+
+type RefreshTokens struct {
+	TokenHash string    `json:"-" db:"token_hash"`
+	SessionId string    `json:"sessionId" db:"session_id"`
+	IsRotated bool      `json:"isRotated" db:"is_rotated"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Revocation is a revoked token or session id, it has to be rejected until
+// ExpiresAt, when every access token it could have been issued to expires.
+type Revocation struct {
+	Id        string    `json:"id" db:"id"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
+}
+
+type Revocations struct {
+	Tokens   []Revocation
+	Sessions []Revocation
+}
+
+// Device describes where a session was opened from.
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+type Refresh struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
 }
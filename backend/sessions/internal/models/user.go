@@ -3,12 +3,13 @@ package models
 import "github.com/google/uuid"
 
 type Register struct {
-	Login    string    `json:"login" bson:"login"`
-	Password string    `json:"password" bson:"password"`
+	Login    string    `json:"login" bson:"login" binding:"required"`
+	Password string    `json:"password" bson:"password" binding:"required"`
+	Email    string    `json:"email" binding:"required,email"`
 	UserId   uuid.UUID `json:"user_id"`
 }
 
 type Auth struct {
-	Login    string `json:"login" bson:"login"`
-	Password string `json:"password" bson:"password"`
+	Login    string `json:"login" bson:"login" binding:"required"`
+	Password string `json:"password" bson:"password" binding:"required"`
 }
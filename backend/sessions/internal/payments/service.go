@@ -0,0 +1,78 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/HTTPclient"
+)
+
+// primeSubscription is the subscription type of Prime accounts in the payment
+// service
+const primeSubscription = 1
+
+type service struct {
+	logger *slog.Logger
+	client *HTTPclient.HTTPclient
+	config config.ServicesConfig
+}
+
+type subscription struct {
+	UserId           string `json:"userId"`
+	SubscriptionType int    `json:"subscriptionType"`
+}
+
+type subscriptionsResponse struct {
+	Subscriptions []subscription `json:"subscriptions"`
+}
+
+// HasPrime tells whether the user has paid for a Prime subscription that is
+// active now.
+func (s service) HasPrime(ctx context.Context, userId string) (bool, error) {
+	query := url.Values{}
+	query.Set("user_id", userId)
+	subscriptionsUrl := s.config.PaymentService + "/payments/?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", subscriptionsUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return false, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get subscription of user", "user_id", userId, "error", err)
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, s.client.ResponseError(resp)
+	}
+
+	var response subscriptionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return false, err
+	}
+
+	for _, subscription := range response.Subscriptions {
+		if subscription.UserId == userId && subscription.SubscriptionType == primeSubscription {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type Service interface {
+	HasPrime(ctx context.Context, userId string) (bool, error)
+}
+
+func NewService(config config.ServicesConfig, logger *slog.Logger) Service {
+	return service{logger: logger, client: HTTPclient.NewHTTPclient("payment_service", config.Client("payment_service")),
+		config: config}
+}
@@ -0,0 +1,208 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/internal/users"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/hash"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/mail"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
+)
+
+// AccountService proves ownership of the email of an account with links sent
+// by mail: one to verify the address after registration and one to reset a
+// forgotten password. Link tokens are single use and only their hashes are
+// stored.
+type AccountService struct {
+	logger                    *slog.Logger
+	credentialRepository      repostiroties.CredentialsRepository
+	credentialTokenRepository repostiroties.CredentialTokenRepository
+	sessionRepository         repostiroties.SessionRepository
+	hashService               hash.HashService
+	passwordPolicy            password.Policy
+	throttle                  LoginThrottle
+	mailer                    mail.Mailer
+	usersService              users.Service
+	config                    config.MailConfig
+}
+
+func NewAccountService(logger *slog.Logger, credentialRepository repostiroties.CredentialsRepository,
+	credentialTokenRepository repostiroties.CredentialTokenRepository, sessionRepository repostiroties.SessionRepository,
+	hashService hash.HashService, passwordPolicy password.Policy, throttle LoginThrottle, mailer mail.Mailer,
+	usersService users.Service, config config.MailConfig) AccountService {
+	return AccountService{logger: logger, credentialRepository: credentialRepository,
+		credentialTokenRepository: credentialTokenRepository, sessionRepository: sessionRepository,
+		hashService: hashService, passwordPolicy: passwordPolicy, throttle: throttle, mailer: mailer,
+		usersService: usersService, config: config}
+}
+
+// SendVerification mails a new verification link, links sent before stop
+// working.
+func (a AccountService) SendVerification(ctx context.Context, credential models.Credentials) error {
+	link, err := a.issueToken(ctx, credential, models.VerifyEmailPurpose, a.config.VerifyTTL, a.config.VerifyURL)
+	if err != nil {
+		return err
+	}
+
+	return a.mailer.Send(mail.Message{
+		To:      credential.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hi %s,\n\nplease confirm your email by following the link below. "+
+			"It is valid for %s.\n\n%s\n", credential.Login, a.config.VerifyTTL, link),
+	})
+}
+
+// ResendVerification sends a new verification link to the owner of the session.
+func (a AccountService) ResendVerification(ctx context.Context, sessionId string) error {
+	session, err := a.sessionRepository.GetSessionById(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	credential, err := a.credentialRepository.GetCredentialById(ctx, session.CredentialId)
+	if err != nil {
+		return err
+	}
+	if credential.EmailVerified {
+		return nil
+	}
+
+	return a.SendVerification(ctx, credential)
+}
+
+// VerifyEmail marks the address as verified and gives the user access in the
+// users service.
+func (a AccountService) VerifyEmail(ctx context.Context, verificationToken string) error {
+	tokenHash := token.HashOpaqueToken(verificationToken)
+	stored, err := a.credentialTokenRepository.GetValidToken(ctx, tokenHash, models.VerifyEmailPurpose)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return schemas.CredentialTokenError
+		}
+		return err
+	}
+
+	credential, err := a.credentialRepository.GetCredentialById(ctx, stored.CredentialId)
+	if err != nil {
+		return err
+	}
+
+	// access is granted before the token is used up, so a failed call can be retried with the same link
+	if err = a.usersService.GrantAccess(ctx, credential.UserId); err != nil {
+		return err
+	}
+
+	if _, err = a.credentialTokenRepository.UseToken(ctx, tokenHash, models.VerifyEmailPurpose); err != nil {
+		if err == repostiroties.NotFoundError {
+			return schemas.CredentialTokenError
+		}
+		return err
+	}
+
+	return a.credentialRepository.SetEmailVerified(ctx, credential.Id)
+}
+
+// ForgotPassword mails a password reset link. Unknown addresses are ignored
+// silently, so the response does not tell which addresses are registered.
+func (a AccountService) ForgotPassword(ctx context.Context, email string) error {
+	credential, err := a.credentialRepository.GetCredentialByEmail(ctx, email)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return nil
+		}
+		return err
+	}
+
+	link, err := a.issueToken(ctx, credential, models.ResetPasswordPurpose, a.config.ResetTTL, a.config.ResetURL)
+	if err != nil {
+		return err
+	}
+
+	return a.mailer.Send(mail.Message{
+		To:      credential.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. "+
+			"If it was you, follow the link below within %s, otherwise ignore this email.\n\n%s\n",
+			credential.Login, a.config.ResetTTL, link),
+	})
+}
+
+// ResetPassword sets a new password and signs the account out everywhere.
+func (a AccountService) ResetPassword(ctx context.Context, resetToken string, newPassword string) error {
+	tokenHash := token.HashOpaqueToken(resetToken)
+	stored, err := a.credentialTokenRepository.GetValidToken(ctx, tokenHash, models.ResetPasswordPurpose)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return schemas.CredentialTokenError
+		}
+		return err
+	}
+
+	credential, err := a.credentialRepository.GetCredentialById(ctx, stored.CredentialId)
+	if err != nil {
+		return err
+	}
+
+	// the password is checked first, so a rejected one does not use up the link
+	if err = a.passwordPolicy.Validate(credential.Login, newPassword); err != nil {
+		return err
+	}
+
+	if _, err = a.credentialTokenRepository.UseToken(ctx, tokenHash, models.ResetPasswordPurpose); err != nil {
+		if err == repostiroties.NotFoundError {
+			return schemas.CredentialTokenError
+		}
+		return err
+	}
+
+	passwordHash, err := a.hashService.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err = a.credentialRepository.UpdatePasswordHash(ctx, credential.Id, passwordHash); err != nil {
+		return err
+	}
+
+	if err = a.sessionRepository.RevokeSessionsByUserId(ctx, credential.UserId); err != nil {
+		return err
+	}
+
+	if err = a.throttle.Succeed(ctx, credential.Login); err != nil {
+		a.logger.ErrorContext(ctx, "could not reset failed attempts", "login", credential.Login, "error", err)
+	}
+
+	return nil
+}
+
+func (a AccountService) issueToken(ctx context.Context, credential models.Credentials, purpose string, ttl time.Duration,
+	baseUrl string) (string, error) {
+	if err := a.credentialTokenRepository.InvalidateTokens(ctx, credential.Id, purpose); err != nil {
+		return "", err
+	}
+
+	opaqueToken, err := token.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = a.credentialTokenRepository.AddToken(ctx, models.CredentialToken{
+		TokenHash:    token.HashOpaqueToken(opaqueToken),
+		CredentialId: credential.Id,
+		Purpose:      purpose,
+		ExpiresAt:    time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return baseUrl + "?token=" + url.QueryEscape(opaqueToken), nil
+}
@@ -0,0 +1,136 @@
+package sessions
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Verify email
+// @Tags account
+// @Description Confirm the email of an account with the token from the verification link
+// @Accept json
+// @Param verification body models.VerifyEmail true "Verification token"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/email/verify [post]
+func verifyEmail(accountService AccountService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var verification models.VerifyEmail
+		if err := c.ShouldBindJSON(&verification); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		if err := accountService.VerifyEmail(c.Request.Context(), verification.Token); err != nil {
+			if err != schemas.CredentialTokenError {
+				logger.ErrorContext(c.Request.Context(), "could not verify email", "error", err)
+			}
+			problem.Respond(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Resend verification email
+// @Tags account
+// @Description Send a new verification link to the email of the current user
+// @Security ApiKeyAuth
+// @Success 202
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/email/verify/resend [post]
+func resendVerification(authService AuthService, accountService AccountService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		if err := accountService.ResendVerification(c.Request.Context(), claims.SessionId); err != nil {
+			logger.ErrorContext(c.Request.Context(), "could not resend verification email", "error", err)
+			problem.Respond(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// @Summary Forgot password
+// @Tags account
+// @Description Send a password reset link to the email, if an account uses it
+// @Accept json
+// @Param email body models.ForgotPassword true "Account email"
+// @Success 202
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/password/forgot [post]
+func forgotPassword(accountService AccountService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var forgot models.ForgotPassword
+		if err := c.ShouldBindJSON(&forgot); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		if err := accountService.ForgotPassword(c.Request.Context(), forgot.Email); err != nil {
+			logger.ErrorContext(c.Request.Context(), "could not send password reset email", "error", err)
+			problem.Respond(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// @Summary Reset password
+// @Tags account
+// @Description Set a new password with the token from the reset link and sign out all devices
+// @Accept json
+// @Param reset body models.ResetPassword true "Reset token and new password"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/password/reset [post]
+func resetPassword(accountService AccountService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reset models.ResetPassword
+		if err := c.ShouldBindJSON(&reset); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		if err := accountService.ResetPassword(c.Request.Context(), reset.Token, reset.Password); err != nil {
+			var policyErr password.PolicyError
+			if errors.As(err, &policyErr) {
+				problem.Respond(c, passwordProblem(policyErr))
+				return
+			}
+			if err != schemas.CredentialTokenError {
+				logger.ErrorContext(c.Request.Context(), "could not reset password", "error", err)
+			}
+			problem.Respond(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func RegisterAccountHandlers(rg *gin.RouterGroup, authService AuthService, accountService AccountService,
+	logger *slog.Logger) {
+	rg.POST("/email/verify", verifyEmail(accountService, logger))
+	rg.POST("/email/verify/resend", resendVerification(authService, accountService, logger))
+	rg.POST("/password/forgot", forgotPassword(accountService, logger))
+	rg.POST("/password/reset", resetPassword(accountService, logger))
+}
@@ -1,47 +1,64 @@
 package sessions
 
 import (
+	"errors"
 	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
 	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/problem"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
 	"github.com/gin-gonic/gin"
-	"log"
+	"github.com/google/uuid"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type handler struct {
-	logger  *log.Logger
+	logger  *slog.Logger
 	service AuthService
 }
 
 // @Summary Users sign in
 // @Tags auth
-// @Description Authenticate user by email and password
+// @Description Authenticate user by email and password. Accounts with two-factor authentication
+// @Description get a challenge token with status "mfa_required" instead of tokens
 // @Accept json
 // @Produce json
 // @Param userCredentials body models.UserCredentials true "User sign in credentials"
-// @Success 200 {object} tokenResponse
-// @Failure 400 {object} messageResponse
-// @Failure 401 {object} messageResponse
-// @Failure 500 {object} messageResponse
+// @Success 200 {object} tokensResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /auth/sign-in [post]
 func signIn(authService AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var userCredentials models.Auth
 		if err := c.ShouldBindJSON(&userCredentials); err != nil {
-			schemas.RespondWithError(c, http.StatusBadRequest, err.Error())
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
-		token, err := authService.SignIn(userCredentials)
+		tokens, err := authService.SignIn(c.Request.Context(), userCredentials, device(c))
 		if err != nil {
-			if err == schemas.InvalidCredentialsError {
-				schemas.RespondWithError(c, http.StatusUnauthorized, err.Error())
+			var challenge schemas.MFARequiredError
+			if errors.As(err, &challenge) {
+				schemas.RespondWithChallenge(c, http.StatusOK, challenge.ChallengeToken,
+					int64(challenge.ExpiresIn/time.Second))
 				return
 			}
-			schemas.RespondWithError(c, http.StatusInternalServerError, err.Error())
+			var locked schemas.LockedError
+			if errors.As(err, &locked) {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			}
+			problem.Respond(c, err)
 			return
 		}
-		schemas.RespondWithToken(c, http.StatusOK, token)
+		respondWithTokens(c, http.StatusOK, tokens)
 	}
 }
 
@@ -51,56 +68,285 @@ func signIn(authService AuthService) gin.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param user body models.User true "User registration information"
-// @Success 201 {object} tokenResponse
-// @Failure 400 {object} messageResponse
-// @Failure 500 {object} messageResponse
+// @Success 201 {object} tokensResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /auth/register [post]
 func register(authService AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var registerModel models.Register
 		if err := c.ShouldBindJSON(&registerModel); err != nil {
-			schemas.RespondWithError(c, http.StatusBadRequest, err.Error())
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
-		token, err := authService.Register(registerModel)
+		tokens, err := authService.Register(c.Request.Context(), registerModel, device(c))
 		if err != nil {
-			if err == schemas.UserAlreadyExistsError {
-				schemas.RespondWithError(c, http.StatusConflict, err.Error())
+			var policyErr password.PolicyError
+			if errors.As(err, &policyErr) {
+				problem.Respond(c, passwordProblem(policyErr))
 				return
 			}
-			schemas.RespondWithError(c, http.StatusInternalServerError, err.Error())
+			problem.Respond(c, err)
 			return
 		}
-		schemas.RespondWithToken(c, http.StatusCreated, token)
+		respondWithTokens(c, http.StatusCreated, tokens)
+	}
+}
+
+// @Summary Refresh tokens
+// @Tags auth
+// @Description Exchange a single-use refresh token for a new access and refresh token pair
+// @Accept json
+// @Produce json
+// @Param refresh body models.Refresh true "Refresh token"
+// @Success 200 {object} tokensResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/token/refresh [post]
+func refresh(authService AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var refreshModel models.Refresh
+		if err := c.ShouldBindJSON(&refreshModel); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+		tokens, err := authService.Refresh(c.Request.Context(), refreshModel.RefreshToken)
+		if err != nil {
+			problem.Respond(c, err)
+			return
+		}
+		respondWithTokens(c, http.StatusOK, tokens)
 	}
 }
 
 func validate(authService AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		reqToken := c.Request.Header.Get("Authorization")
-		splitToken := strings.Split(reqToken, "Bearer ")
-		reqToken = splitToken[1]
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		schemas.RespondWithIdentity(c, http.StatusOK, claims.UserId, claims.Role)
+	}
+}
 
-		token, err := authService.Authorize(reqToken)
+// @Summary Revoked tokens and sessions
+// @Tags auth
+// @Description List the revoked token and session ids that unexpired access tokens may carry, so that
+// @Description they can be rejected by services which verify tokens with the published keys
+// @Produce json
+// @Success 200 {object} revocationsResponse
+// @Failure 500 {object} problem.Problem
+// @Router /auth/revocations [get]
+func getRevocations(authService AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revocations, err := authService.GetRevocations(c.Request.Context())
 		if err != nil {
-			if err == schemas.UserAlreadyExistsError {
-				schemas.RespondWithError(c, http.StatusNotFound, err.Error())
-				return
+			problem.Respond(c, err)
+			return
+		}
+
+		schemas.RespondWithRevocations(c, http.StatusOK, revocationResponses(revocations.Tokens),
+			revocationResponses(revocations.Sessions))
+	}
+}
+
+// @Summary Sign out
+// @Tags auth
+// @Description Revoke the access token of the request and close its session
+// @Security ApiKeyAuth
+// @Success 204
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/sign-out [post]
+func signOut(authService AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		if err := authService.SignOut(c.Request.Context(), claims); err != nil {
+			problem.Respond(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary List active sessions
+// @Tags auth
+// @Description List devices the current user is signed in from
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dataResponse
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/sessions [get]
+func getSessions(authService AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		sessions, err := authService.GetActiveSessions(c.Request.Context(), claims.UserId)
+		if err != nil {
+			problem.Respond(c, err)
+			return
+		}
+
+		response := make([]schemas.SessionResponse, 0, len(sessions))
+		for _, session := range sessions {
+			response = append(response, schemas.SessionResponse{
+				Id:         session.Id,
+				UserAgent:  session.UserAgent,
+				IP:         session.IP,
+				CreatedAt:  session.CreatedAt,
+				LastSeenAt: session.LastSeenAt,
+				ExpiresAt:  session.ExpiresAt,
+				Current:    session.Id == claims.SessionId,
+			})
+		}
+
+		schemas.RespondWithData(c, http.StatusOK, response)
+	}
+}
+
+// @Summary Revoke session
+// @Tags auth
+// @Description Sign out one of the current user's devices
+// @Security ApiKeyAuth
+// @Param id path string true "Session id"
+// @Success 204
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/sessions/{id} [delete]
+func revokeSession(authService AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		sessionId := c.Param("id")
+		if _, err := uuid.Parse(sessionId); err != nil {
+			problem.Respond(c, problem.InvalidParam("id", err))
+			return
+		}
+
+		if err := authService.RevokeSession(c.Request.Context(), claims.UserId, sessionId); err != nil {
+			switch err {
+			case repostiroties.NotFoundError:
+				problem.Respond(c, problem.NotFound("session not found"))
+			case schemas.RightsError:
+				problem.Respond(c, problem.Forbidden(err.Error()))
+			default:
+				problem.Respond(c, err)
 			}
-			schemas.RespondWithError(c, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		schemas.RespondWithUserId(c, http.StatusOK, token)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Revoke all sessions
+// @Tags auth
+// @Description Sign out all of the current user's devices
+// @Security ApiKeyAuth
+// @Success 204
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/sessions [delete]
+func revokeAllSessions(authService AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		if err := authService.RevokeAllSessions(c.Request.Context(), claims.UserId); err != nil {
+			problem.Respond(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// authorize validates the bearer token of the request and responds with an
+// error when it is missing or not valid.
+func authorize(c *gin.Context, authService AuthService) (token.Claims, bool) {
+	reqToken := c.Request.Header.Get("Authorization")
+	if !strings.HasPrefix(reqToken, "Bearer ") {
+		problem.Respond(c, problem.Unauthorized("missing bearer token"))
+		return token.Claims{}, false
+	}
+
+	claims, err := authService.Authorize(c.Request.Context(), strings.TrimPrefix(reqToken, "Bearer "))
+	if err != nil {
+		if err == schemas.RightsError {
+			err = problem.Unauthorized(err.Error())
+		}
+		problem.Respond(c, err)
+		return token.Claims{}, false
 	}
+
+	return claims, true
+}
+
+// passwordProblem reports every broken rule of the password policy.
+func passwordProblem(err password.PolicyError) *problem.Problem {
+	fields := make([]problem.FieldError, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		fields = append(fields, problem.FieldError{Field: "password", Code: "policy", Message: violation})
+	}
+	return problem.Validation("password does not meet the policy", fields...)
+}
+
+func device(c *gin.Context) models.Device {
+	return models.Device{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+func revocationResponses(revocations []models.Revocation) []schemas.RevocationResponse {
+	response := make([]schemas.RevocationResponse, 0, len(revocations))
+	for _, revocation := range revocations {
+		response = append(response, schemas.RevocationResponse{Id: revocation.Id, ExpiresAt: revocation.ExpiresAt})
+	}
+	return response
+}
+
+func respondWithTokens(c *gin.Context, statusCode int, tokens models.Tokens) {
+	schemas.RespondWithTokens(c, statusCode, tokens.AccessToken, tokens.RefreshToken,
+		int64(tokens.ExpiresIn/time.Second))
+}
+
+// RegisterJWKSHandler publishes the public keys tokens can be verified with.
+func RegisterJWKSHandler(router gin.IRoutes, keySet token.KeySet) {
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keySet.JWKS())
+	})
 }
 
-func RegisterHandlers(rg *gin.RouterGroup, service AuthService, logger *log.Logger) {
+func RegisterHandlers(rg *gin.RouterGroup, service AuthService, logger *slog.Logger) {
 	h := handler{
 		logger:  logger,
 		service: service,
 	}
 	rg.POST("/sign-in", signIn(h.service))
 	rg.POST("/register", register(h.service))
+	rg.POST("/sign-out", signOut(h.service))
 	rg.GET("/token/validate", validate(h.service))
+	rg.POST("/token/refresh", refresh(h.service))
+	rg.GET("/revocations", getRevocations(h.service))
+	rg.GET("/sessions", getSessions(h.service))
+	rg.DELETE("/sessions", revokeAllSessions(h.service))
+	rg.DELETE("/sessions/:id", revokeSession(h.service))
 }
@@ -0,0 +1,465 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
+	"github.com/google/uuid"
+)
+
+// The fakes keep their rows in memory and implement the methods the tests
+// reach, the embedded interfaces panic on any other.
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type fakeCredentials struct {
+	repostiroties.CredentialsRepository
+
+	mu   sync.Mutex
+	byId map[string]models.Credentials
+	// addErr, when set, fails AddCredential like a violated constraint would
+	addErr error
+}
+
+func newFakeCredentials(credentials ...models.Credentials) *fakeCredentials {
+	f := &fakeCredentials{byId: map[string]models.Credentials{}}
+	for _, credential := range credentials {
+		f.byId[credential.Id] = credential
+	}
+	return f
+}
+
+func (f *fakeCredentials) GetCredentialById(_ context.Context, credentialId string) (models.Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	credential, ok := f.byId[credentialId]
+	if !ok {
+		return models.Credentials{}, repostiroties.NotFoundError
+	}
+	return credential, nil
+}
+
+func (f *fakeCredentials) GetCredentialByLogin(_ context.Context, login string) (models.Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, credential := range f.byId {
+		if credential.Login == login {
+			return credential, nil
+		}
+	}
+	return models.Credentials{}, repostiroties.NotFoundError
+}
+
+func (f *fakeCredentials) GetCredentialByEmail(_ context.Context, email string) (models.Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, credential := range f.byId {
+		if strings.EqualFold(credential.Email, email) {
+			return credential, nil
+		}
+	}
+	return models.Credentials{}, repostiroties.NotFoundError
+}
+
+func (f *fakeCredentials) AddCredential(_ context.Context, credential models.Credentials) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.addErr != nil {
+		return "", f.addErr
+	}
+	f.byId[credential.Id] = credential
+	return credential.Id, nil
+}
+
+type fakeSessions struct {
+	repostiroties.SessionRepository
+
+	mu   sync.Mutex
+	byId map[string]models.Sessions
+}
+
+func newFakeSessions(sessions ...models.Sessions) *fakeSessions {
+	f := &fakeSessions{byId: map[string]models.Sessions{}}
+	for _, session := range sessions {
+		f.byId[session.Id] = session
+	}
+	return f
+}
+
+func (f *fakeSessions) AddSession(_ context.Context, session models.Sessions) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.byId[session.Id] = session
+	return session.Id, nil
+}
+
+func (f *fakeSessions) GetSessionById(_ context.Context, sessionId string) (models.Sessions, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session, ok := f.byId[sessionId]
+	if !ok {
+		return models.Sessions{}, repostiroties.NotFoundError
+	}
+	return session, nil
+}
+
+func (f *fakeSessions) ExtendSession(_ context.Context, sessionId string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session := f.byId[sessionId]
+	session.ExpiresAt = expiresAt
+	f.byId[sessionId] = session
+	return nil
+}
+
+func (f *fakeSessions) RevokeSession(_ context.Context, sessionId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session := f.byId[sessionId]
+	session.IsAuthenticated = false
+	f.byId[sessionId] = session
+	return nil
+}
+
+func (f *fakeSessions) revoked(sessionId string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.byId[sessionId].IsAuthenticated
+}
+
+type fakeRefreshTokens struct {
+	mu     sync.Mutex
+	tokens map[string]models.RefreshTokens
+	// beforeRotate runs between reading a token and rotating it, where a
+	// concurrent refresh with the same token could rotate it first
+	beforeRotate func()
+}
+
+func newFakeRefreshTokens() *fakeRefreshTokens {
+	return &fakeRefreshTokens{tokens: map[string]models.RefreshTokens{}}
+}
+
+func (f *fakeRefreshTokens) AddRefreshToken(_ context.Context, sessionId string, tokenHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[tokenHash] = models.RefreshTokens{TokenHash: tokenHash, SessionId: sessionId, CreatedAt: time.Now()}
+	return nil
+}
+
+func (f *fakeRefreshTokens) GetRefreshToken(_ context.Context, tokenHash string) (models.RefreshTokens, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.tokens[tokenHash]
+	if !ok {
+		return models.RefreshTokens{}, repostiroties.NotFoundError
+	}
+	return stored, nil
+}
+
+func (f *fakeRefreshTokens) RotateRefreshToken(_ context.Context, tokenHash string) (bool, error) {
+	if f.beforeRotate != nil {
+		f.beforeRotate()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.tokens[tokenHash]
+	if !ok || stored.IsRotated {
+		return false, nil
+	}
+	stored.IsRotated = true
+	f.tokens[tokenHash] = stored
+	return true, nil
+}
+
+func (f *fakeRefreshTokens) rotated(refreshToken string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tokens[token.HashOpaqueToken(refreshToken)].IsRotated
+}
+
+func (f *fakeRefreshTokens) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tokens)
+}
+
+type fakeTokenService struct{}
+
+func (fakeTokenService) GenerateToken(userId string, sessionId string, role string) (string, error) {
+	return userId + "." + sessionId + "." + role, nil
+}
+
+func (fakeTokenService) ParseToken(string) (token.Claims, error) {
+	panic("not used")
+}
+
+// fakeHash "hashes" by prefixing, which is enough to tell hashes from plain
+// values.
+type fakeHash struct{}
+
+func (fakeHash) HashPassword(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (fakeHash) ValidatePassword(password string, hashedPassword string) error {
+	if hashedPassword != "hashed:"+password {
+		return errors.New("password does not match")
+	}
+	return nil
+}
+
+// fakeOTP accepts the codes it was given, each for its time step.
+type fakeOTP struct {
+	steps map[string]int64
+}
+
+func (fakeOTP) GenerateSecret() (string, error) {
+	return "SECRET", nil
+}
+
+func (fakeOTP) URI(secret string, account string) string {
+	return "otpauth://totp/" + account + "?secret=" + secret
+}
+
+func (f fakeOTP) Validate(code string, _ string) (int64, bool) {
+	step, ok := f.steps[code]
+	return step, ok
+}
+
+type fakePayments struct {
+	prime map[string]bool
+	err   error
+}
+
+func (f fakePayments) HasPrime(_ context.Context, userId string) (bool, error) {
+	return f.prime[userId], f.err
+}
+
+type fakeMFA struct {
+	mu            sync.Mutex
+	mfa           map[string]models.MFA
+	recoveryCodes map[string]models.RecoveryCode
+}
+
+func newFakeMFA() *fakeMFA {
+	return &fakeMFA{mfa: map[string]models.MFA{}, recoveryCodes: map[string]models.RecoveryCode{}}
+}
+
+func (f *fakeMFA) GetMFA(_ context.Context, credentialId string) (models.MFA, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mfa, ok := f.mfa[credentialId]
+	if !ok {
+		return models.MFA{}, repostiroties.NotFoundError
+	}
+	return mfa, nil
+}
+
+func (f *fakeMFA) SaveSecret(_ context.Context, credentialId string, secret string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mfa[credentialId].Confirmed {
+		return false, nil
+	}
+	f.mfa[credentialId] = models.MFA{CredentialId: credentialId, Secret: secret, CreatedAt: time.Now()}
+	return true, nil
+}
+
+func (f *fakeMFA) Confirm(_ context.Context, credentialId string, step int64, recoveryCodeHashes []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mfa := f.mfa[credentialId]
+	mfa.Confirmed, mfa.LastUsedStep = true, step
+	f.mfa[credentialId] = mfa
+	for id, code := range f.recoveryCodes {
+		if code.CredentialId == credentialId {
+			delete(f.recoveryCodes, id)
+		}
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		id := uuid.New().String()
+		f.recoveryCodes[id] = models.RecoveryCode{Id: id, CredentialId: credentialId, CodeHash: codeHash}
+	}
+	return nil
+}
+
+func (f *fakeMFA) UseStep(_ context.Context, credentialId string, step int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mfa := f.mfa[credentialId]
+	if mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	f.mfa[credentialId] = mfa
+	return true, nil
+}
+
+func (f *fakeMFA) GetUnusedRecoveryCodes(_ context.Context, credentialId string) ([]models.RecoveryCode, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var codes []models.RecoveryCode
+	for _, code := range f.recoveryCodes {
+		if code.CredentialId == credentialId && code.UsedAt == nil {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+func (f *fakeMFA) UseRecoveryCode(_ context.Context, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code, ok := f.recoveryCodes[id]
+	if !ok || code.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	f.recoveryCodes[id] = code
+	return true, nil
+}
+
+func (f *fakeMFA) DeleteMFA(_ context.Context, credentialId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.mfa, credentialId)
+	return nil
+}
+
+type fakeChallenges struct {
+	mu         sync.Mutex
+	challenges map[string]models.MFAChallenge
+}
+
+func newFakeChallenges() *fakeChallenges {
+	return &fakeChallenges{challenges: map[string]models.MFAChallenge{}}
+}
+
+func (f *fakeChallenges) AddChallenge(_ context.Context, challenge models.MFAChallenge) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.challenges[challenge.TokenHash] = challenge
+	return nil
+}
+
+func (f *fakeChallenges) GetValidChallenge(_ context.Context, tokenHash string, maxAttempts int) (models.MFAChallenge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	challenge, ok := f.challenges[tokenHash]
+	if !ok || challenge.UsedAt != nil || !challenge.ExpiresAt.After(time.Now()) || challenge.Attempts >= maxAttempts {
+		return models.MFAChallenge{}, repostiroties.NotFoundError
+	}
+	return challenge, nil
+}
+
+func (f *fakeChallenges) AddFailedAttempt(_ context.Context, tokenHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	challenge := f.challenges[tokenHash]
+	challenge.Attempts++
+	f.challenges[tokenHash] = challenge
+	return nil
+}
+
+func (f *fakeChallenges) UseChallenge(_ context.Context, tokenHash string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	challenge, ok := f.challenges[tokenHash]
+	if !ok || challenge.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	challenge.UsedAt = &now
+	f.challenges[tokenHash] = challenge
+	return true, nil
+}
+
+// expire moves the expiry of every challenge into the past.
+func (f *fakeChallenges) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for tokenHash, challenge := range f.challenges {
+		challenge.ExpiresAt = time.Now().Add(-time.Second)
+		f.challenges[tokenHash] = challenge
+	}
+}
+
+type loginAttempt struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type fakeLoginAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]loginAttempt
+	lockouts []models.Lockout
+}
+
+func newFakeLoginAttempts() *fakeLoginAttempts {
+	return &fakeLoginAttempts{attempts: map[string]loginAttempt{}}
+}
+
+func (f *fakeLoginAttempts) GetLockedUntil(_ context.Context, kind, value string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts[kind+":"+value].lockedUntil, nil
+}
+
+func (f *fakeLoginAttempts) RegisterFailure(_ context.Context, kind, value string, since time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	attempt := f.attempts[kind+":"+value]
+	if attempt.lastFailure.Before(since) {
+		attempt.failures = 0
+	}
+	attempt.failures++
+	attempt.lastFailure = time.Now()
+	f.attempts[kind+":"+value] = attempt
+	return attempt.failures, nil
+}
+
+func (f *fakeLoginAttempts) Lock(_ context.Context, lockout models.Lockout) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	attempt := f.attempts[lockout.Kind+":"+lockout.Value]
+	attempt.lockedUntil = lockout.LockedUntil
+	f.attempts[lockout.Kind+":"+lockout.Value] = attempt
+	f.lockouts = append(f.lockouts, lockout)
+	return nil
+}
+
+func (f *fakeLoginAttempts) Reset(_ context.Context, kind, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.attempts, kind+":"+value)
+	return nil
+}
+
+func (f *fakeLoginAttempts) failures(kind, value string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts[kind+":"+value].failures
+}
+
+// lastDelay is how long the latest lockout of the kind locked for.
+func (f *fakeLoginAttempts) lastDelay(kind string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.lockouts) - 1; i >= 0; i-- {
+		if f.lockouts[i].Kind == kind {
+			return time.Until(f.lockouts[i].LockedUntil).Round(time.Second)
+		}
+	}
+	return 0
+}
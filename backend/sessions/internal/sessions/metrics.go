@@ -0,0 +1,24 @@
+package sessions
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	invalidCredentialsReason = "invalid_credentials"
+	lockedReason             = "locked"
+	invalidMFACodeReason     = "invalid_mfa_code"
+)
+
+var (
+	registrationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "registrations_total",
+		Help: "Number of registered accounts.",
+	})
+
+	signInFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sign_in_failures_total",
+		Help: "Number of rejected sign in attempts by reason.",
+	}, []string{"reason"})
+)
@@ -0,0 +1,270 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/payments"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/hash"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/otp"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
+)
+
+const recoveryCodeSize = 10
+
+// MFAService manages the optional TOTP second factor of Prime accounts:
+// enrollment, one-time recovery codes and the challenges sign in issues
+// while waiting for a code.
+type MFAService struct {
+	logger                 *slog.Logger
+	credentialRepository   repostiroties.CredentialsRepository
+	sessionRepository      repostiroties.SessionRepository
+	mfaRepository          repostiroties.MFARepository
+	mfaChallengeRepository repostiroties.MFAChallengeRepository
+	paymentsService        payments.Service
+	otpService             otp.OTPService
+	hashService            hash.HashService
+	config                 config.MFAConfig
+}
+
+func NewMFAService(logger *slog.Logger, credentialRepository repostiroties.CredentialsRepository,
+	sessionRepository repostiroties.SessionRepository, mfaRepository repostiroties.MFARepository,
+	mfaChallengeRepository repostiroties.MFAChallengeRepository, paymentsService payments.Service,
+	otpService otp.OTPService, hashService hash.HashService, config config.MFAConfig) MFAService {
+	return MFAService{logger: logger, credentialRepository: credentialRepository, sessionRepository: sessionRepository,
+		mfaRepository: mfaRepository, mfaChallengeRepository: mfaChallengeRepository, paymentsService: paymentsService,
+		otpService: otpService, hashService: hashService, config: config}
+}
+
+// Enroll generates a new secret for the owner of the session, who has to
+// have an active Prime subscription. The second factor is not enforced until
+// Confirm is called with a code for it.
+func (m MFAService) Enroll(ctx context.Context, sessionId string) (models.Enrollment, error) {
+	credential, err := m.credentialOfSession(ctx, sessionId)
+	if err != nil {
+		return models.Enrollment{}, err
+	}
+	prime, err := m.paymentsService.HasPrime(ctx, credential.UserId)
+	if err != nil {
+		return models.Enrollment{}, err
+	}
+	if !prime {
+		return models.Enrollment{}, schemas.MFANotAvailableError
+	}
+
+	secret, err := m.otpService.GenerateSecret()
+	if err != nil {
+		return models.Enrollment{}, err
+	}
+
+	saved, err := m.mfaRepository.SaveSecret(ctx, credential.Id, secret)
+	if err != nil {
+		return models.Enrollment{}, err
+	}
+	if !saved {
+		return models.Enrollment{}, schemas.MFAAlreadyEnabledError
+	}
+
+	account := credential.Email
+	if account == "" {
+		account = credential.Login
+	}
+	return models.Enrollment{Secret: secret, URI: m.otpService.URI(secret, account)}, nil
+}
+
+// Confirm enables the second factor with a first code and returns recovery
+// codes. They are stored hashed, so this is the only time they are shown.
+func (m MFAService) Confirm(ctx context.Context, sessionId string, code string) ([]string, error) {
+	credential, err := m.credentialOfSession(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := m.mfaRepository.GetMFA(ctx, credential.Id)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return nil, schemas.MFANotEnrolledError
+		}
+		return nil, err
+	}
+	if mfa.Confirmed {
+		return nil, schemas.MFAAlreadyEnabledError
+	}
+
+	step, ok := m.otpService.Validate(normalizeCode(code), mfa.Secret)
+	if !ok {
+		return nil, schemas.InvalidMFACodeError
+	}
+
+	codes := make([]string, 0, m.config.RecoveryCodes)
+	hashes := make([]string, 0, m.config.RecoveryCodes)
+	for i := 0; i < m.config.RecoveryCodes; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codeHash, err := m.hashService.HashPassword(normalizeCode(code))
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, codeHash)
+	}
+
+	if err = m.mfaRepository.Confirm(ctx, credential.Id, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns the second factor off, it takes a valid code to do so.
+func (m MFAService) Disable(ctx context.Context, sessionId string, code string) error {
+	credential, err := m.credentialOfSession(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	mfa, err := m.mfaRepository.GetMFA(ctx, credential.Id)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return schemas.MFANotEnrolledError
+		}
+		return err
+	}
+
+	if mfa.Confirmed {
+		ok, err := m.verifyCode(ctx, mfa, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return schemas.InvalidMFACodeError
+		}
+	}
+
+	return m.mfaRepository.DeleteMFA(ctx, credential.Id)
+}
+
+// Enabled tells whether sign in of the credential needs a second factor.
+func (m MFAService) Enabled(ctx context.Context, credentialId string) (bool, error) {
+	mfa, err := m.mfaRepository.GetMFA(ctx, credentialId)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return false, nil
+		}
+		return false, err
+	}
+	return mfa.Confirmed, nil
+}
+
+// NewChallenge issues the token sign in hands out instead of a session.
+func (m MFAService) NewChallenge(ctx context.Context, credentialId string) (models.Challenge, error) {
+	challengeToken, err := token.NewOpaqueToken()
+	if err != nil {
+		return models.Challenge{}, err
+	}
+
+	err = m.mfaChallengeRepository.AddChallenge(ctx, models.MFAChallenge{
+		TokenHash:    token.HashOpaqueToken(challengeToken),
+		CredentialId: credentialId,
+		ExpiresAt:    time.Now().Add(m.config.ChallengeTTL),
+	})
+	if err != nil {
+		return models.Challenge{}, err
+	}
+
+	return models.Challenge{Token: challengeToken, ExpiresIn: m.config.ChallengeTTL}, nil
+}
+
+// VerifyChallenge uses up a challenge if the code is valid and returns the
+// credential it was issued for. A challenge only takes a few wrong codes.
+func (m MFAService) VerifyChallenge(ctx context.Context, challengeToken string, code string) (models.Credentials, error) {
+	tokenHash := token.HashOpaqueToken(challengeToken)
+	challenge, err := m.mfaChallengeRepository.GetValidChallenge(ctx, tokenHash, m.config.MaxAttempts)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return models.Credentials{}, schemas.MFAChallengeError
+		}
+		return models.Credentials{}, err
+	}
+
+	mfa, err := m.mfaRepository.GetMFA(ctx, challenge.CredentialId)
+	if err != nil {
+		return models.Credentials{}, err
+	}
+
+	ok, err := m.verifyCode(ctx, mfa, code)
+	if err != nil {
+		return models.Credentials{}, err
+	}
+	if !ok {
+		if err = m.mfaChallengeRepository.AddFailedAttempt(ctx, tokenHash); err != nil {
+			return models.Credentials{}, err
+		}
+		return models.Credentials{}, schemas.InvalidMFACodeError
+	}
+
+	used, err := m.mfaChallengeRepository.UseChallenge(ctx, tokenHash)
+	if err != nil {
+		return models.Credentials{}, err
+	}
+	if !used {
+		return models.Credentials{}, schemas.MFAChallengeError
+	}
+
+	return m.credentialRepository.GetCredentialById(ctx, challenge.CredentialId)
+}
+
+// verifyCode accepts either a current TOTP code, each at most once, or an
+// unused recovery code.
+func (m MFAService) verifyCode(ctx context.Context, mfa models.MFA, code string) (bool, error) {
+	code = normalizeCode(code)
+
+	if step, ok := m.otpService.Validate(code, mfa.Secret); ok {
+		return m.mfaRepository.UseStep(ctx, mfa.CredentialId, step)
+	}
+
+	recoveryCodes, err := m.mfaRepository.GetUnusedRecoveryCodes(ctx, mfa.CredentialId)
+	if err != nil {
+		return false, err
+	}
+	for _, recoveryCode := range recoveryCodes {
+		if m.hashService.ValidatePassword(code, recoveryCode.CodeHash) == nil {
+			return m.mfaRepository.UseRecoveryCode(ctx, recoveryCode.Id)
+		}
+	}
+
+	return false, nil
+}
+
+func (m MFAService) credentialOfSession(ctx context.Context, sessionId string) (models.Credentials, error) {
+	session, err := m.sessionRepository.GetSessionById(ctx, sessionId)
+	if err != nil {
+		return models.Credentials{}, err
+	}
+	return m.credentialRepository.GetCredentialById(ctx, session.CredentialId)
+}
+
+// newRecoveryCode returns a code like "abcde-fghij".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeSize*5/8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return code[:recoveryCodeSize/2] + "-" + code[recoveryCodeSize/2:], nil
+}
+
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,150 @@
+package sessions
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Complete sign in
+// @Tags mfa
+// @Description Exchange the challenge token of a sign in and a TOTP or recovery code for tokens
+// @Accept json
+// @Produce json
+// @Param verification body models.MFAVerify true "Challenge token and code"
+// @Success 200 {object} tokensResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/mfa/verify [post]
+func verifyMFA(authService AuthService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var verification models.MFAVerify
+		if err := c.ShouldBindJSON(&verification); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		tokens, err := authService.CompleteSignIn(c.Request.Context(), verification.ChallengeToken, verification.Code, device(c))
+		if err != nil {
+			respondWithMFAError(c, logger, err)
+			return
+		}
+		respondWithTokens(c, http.StatusOK, tokens)
+	}
+}
+
+// @Summary Enroll two-factor authentication
+// @Tags mfa
+// @Description Generate a TOTP secret for the current Prime user, it is enabled once confirmed
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dataResponse
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/mfa/enroll [post]
+func enrollMFA(authService AuthService, mfaService MFAService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		enrollment, err := mfaService.Enroll(c.Request.Context(), claims.SessionId)
+		if err != nil {
+			respondWithMFAError(c, logger, err)
+			return
+		}
+		schemas.RespondWithData(c, http.StatusOK, enrollment)
+	}
+}
+
+// @Summary Confirm two-factor authentication
+// @Tags mfa
+// @Description Enable two-factor authentication with a first code, responds with recovery codes
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param code body models.MFACode true "TOTP code"
+// @Success 200 {object} dataResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/mfa/confirm [post]
+func confirmMFA(authService AuthService, mfaService MFAService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		var code models.MFACode
+		if err := c.ShouldBindJSON(&code); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		recoveryCodes, err := mfaService.Confirm(c.Request.Context(), claims.SessionId, code.Code)
+		if err != nil {
+			respondWithMFAError(c, logger, err)
+			return
+		}
+		schemas.RespondWithData(c, http.StatusOK, recoveryCodes)
+	}
+}
+
+// @Summary Disable two-factor authentication
+// @Tags mfa
+// @Description Turn two-factor authentication off with a TOTP or recovery code
+// @Accept json
+// @Security ApiKeyAuth
+// @Param code body models.MFACode true "TOTP or recovery code"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /auth/mfa [delete]
+func disableMFA(authService AuthService, mfaService MFAService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authorize(c, authService)
+		if !ok {
+			return
+		}
+
+		var code models.MFACode
+		if err := c.ShouldBindJSON(&code); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		if err := mfaService.Disable(c.Request.Context(), claims.SessionId, code.Code); err != nil {
+			respondWithMFAError(c, logger, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func respondWithMFAError(c *gin.Context, logger *slog.Logger, err error) {
+	switch err {
+	case schemas.InvalidMFACodeError, schemas.MFAChallengeError, schemas.MFANotEnrolledError,
+		schemas.MFANotAvailableError, schemas.MFAAlreadyEnabledError:
+	default:
+		logger.ErrorContext(c.Request.Context(), "two-factor authentication request failed", "error", err)
+	}
+	problem.Respond(c, err)
+}
+
+func RegisterMFAHandlers(rg *gin.RouterGroup, authService AuthService, mfaService MFAService, logger *slog.Logger) {
+	rg.POST("/mfa/verify", verifyMFA(authService, logger))
+	rg.POST("/mfa/enroll", enrollMFA(authService, mfaService, logger))
+	rg.POST("/mfa/confirm", confirmMFA(authService, mfaService, logger))
+	rg.DELETE("/mfa", disableMFA(authService, mfaService, logger))
+}
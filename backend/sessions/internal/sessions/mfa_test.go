@@ -0,0 +1,248 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
+)
+
+const testPassword = "correct horse battery staple"
+
+// testCodes are the TOTP codes fakeOTP accepts, with their time steps.
+var testCodes = map[string]int64{"100000": 100, "101000": 101, "102000": 102}
+
+type mfaFixture struct {
+	service    MFAService
+	mfa        *fakeMFA
+	challenges *fakeChallenges
+	sessions   *fakeSessions
+	credential models.Credentials
+}
+
+func newMFAFixture(payments fakePayments) mfaFixture {
+	credential := models.Credentials{Id: testCredentialId, UserId: testUserId, Login: "listener", Role: models.UserRole,
+		PasswordHash: "hashed:" + testPassword}
+	f := mfaFixture{
+		mfa:        newFakeMFA(),
+		challenges: newFakeChallenges(),
+		sessions:   newFakeSessions(activeSession()),
+		credential: credential,
+	}
+	f.service = NewMFAService(discardLogger, newFakeCredentials(credential), f.sessions, f.mfa, f.challenges, payments,
+		fakeOTP{steps: testCodes}, fakeHash{},
+		config.MFAConfig{Issuer: "Music Dating", ChallengeTTL: time.Minute, MaxAttempts: 3, RecoveryCodes: 4})
+	return f
+}
+
+func primeFixture() mfaFixture {
+	return newMFAFixture(fakePayments{prime: map[string]bool{testUserId: true}})
+}
+
+// enable enrolls and confirms the second factor with the code of step 100 and
+// returns the recovery codes.
+func (f mfaFixture) enable(t *testing.T) []string {
+	t.Helper()
+	if _, err := f.service.Enroll(context.Background(), testSessionId); err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	codes, err := f.service.Confirm(context.Background(), testSessionId, "100000")
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	return codes
+}
+
+func (f mfaFixture) challenge(t *testing.T) string {
+	t.Helper()
+	challenge, err := f.service.NewChallenge(context.Background(), testCredentialId)
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	return challenge.Token
+}
+
+func TestEnrollRequiresPrime(t *testing.T) {
+	paymentsErr := errors.New("payment service unavailable")
+	tests := []struct {
+		name     string
+		payments fakePayments
+		wantErr  error
+	}{
+		{"without subscription", fakePayments{}, schemas.MFANotAvailableError},
+		{"payments failing", fakePayments{err: paymentsErr}, paymentsErr},
+		{"with Prime", fakePayments{prime: map[string]bool{testUserId: true}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMFAFixture(tt.payments)
+
+			enrollment, err := f.service.Enroll(context.Background(), testSessionId)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Enroll() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (enrollment.Secret == "" || !strings.Contains(enrollment.URI, enrollment.Secret)) {
+				t.Errorf("Enroll() = %+v, want a secret and its URI", enrollment)
+			}
+		})
+	}
+}
+
+func TestConfirmIssuesRecoveryCodes(t *testing.T) {
+	f := primeFixture()
+	if _, err := f.service.Enroll(context.Background(), testSessionId); err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+
+	if _, err := f.service.Confirm(context.Background(), testSessionId, "999999"); err != schemas.InvalidMFACodeError {
+		t.Fatalf("Confirm() with a wrong code error = %v, want %v", err, schemas.InvalidMFACodeError)
+	}
+	if enabled, _ := f.service.Enabled(context.Background(), testCredentialId); enabled {
+		t.Fatal("second factor is enabled before it was confirmed")
+	}
+
+	codes, err := f.service.Confirm(context.Background(), testSessionId, "100000")
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	unique := map[string]bool{}
+	for _, code := range codes {
+		unique[code] = true
+	}
+	if len(codes) != 4 || len(unique) != 4 {
+		t.Errorf("Confirm() returned recovery codes %v, want 4 distinct ones", codes)
+	}
+	if enabled, _ := f.service.Enabled(context.Background(), testCredentialId); !enabled {
+		t.Error("second factor is not enabled after confirmation")
+	}
+
+	if _, err := f.service.Enroll(context.Background(), testSessionId); err != schemas.MFAAlreadyEnabledError {
+		t.Errorf("Enroll() when enabled error = %v, want %v", err, schemas.MFAAlreadyEnabledError)
+	}
+	if _, err := f.service.Confirm(context.Background(), testSessionId, "101000"); err != schemas.MFAAlreadyEnabledError {
+		t.Errorf("Confirm() when enabled error = %v, want %v", err, schemas.MFAAlreadyEnabledError)
+	}
+}
+
+func TestVerifyChallengeAcceptsEachStepOnce(t *testing.T) {
+	f := primeFixture()
+	f.enable(t)
+
+	challenge := f.challenge(t)
+	if _, err := f.service.VerifyChallenge(context.Background(), challenge, "100000"); err != schemas.InvalidMFACodeError {
+		t.Errorf("VerifyChallenge() with the confirmation code error = %v, want %v", err, schemas.InvalidMFACodeError)
+	}
+
+	credential, err := f.service.VerifyChallenge(context.Background(), challenge, "101000")
+	if err != nil {
+		t.Fatalf("VerifyChallenge() error = %v", err)
+	}
+	if credential.Id != testCredentialId {
+		t.Errorf("VerifyChallenge() credential = %s, want %s", credential.Id, testCredentialId)
+	}
+
+	if _, err := f.service.VerifyChallenge(context.Background(), challenge, "102000"); err != schemas.MFAChallengeError {
+		t.Errorf("VerifyChallenge() of a used challenge error = %v, want %v", err, schemas.MFAChallengeError)
+	}
+	if _, err := f.service.VerifyChallenge(context.Background(), f.challenge(t), "101000"); err != schemas.InvalidMFACodeError {
+		t.Errorf("VerifyChallenge() with a used code error = %v, want %v", err, schemas.InvalidMFACodeError)
+	}
+}
+
+func TestVerifyChallengeLimitsAttempts(t *testing.T) {
+	f := primeFixture()
+	f.enable(t)
+	challenge := f.challenge(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := f.service.VerifyChallenge(context.Background(), challenge, "999999"); err != schemas.InvalidMFACodeError {
+			t.Fatalf("VerifyChallenge() attempt %d error = %v, want %v", i+1, err, schemas.InvalidMFACodeError)
+		}
+	}
+
+	if _, err := f.service.VerifyChallenge(context.Background(), challenge, "101000"); err != schemas.MFAChallengeError {
+		t.Errorf("VerifyChallenge() after the last attempt error = %v, want %v", err, schemas.MFAChallengeError)
+	}
+}
+
+func TestVerifyChallengeRejectsExpiredChallenge(t *testing.T) {
+	f := primeFixture()
+	f.enable(t)
+	challenge := f.challenge(t)
+
+	f.challenges.expire()
+
+	if _, err := f.service.VerifyChallenge(context.Background(), challenge, "101000"); err != schemas.MFAChallengeError {
+		t.Errorf("VerifyChallenge() of an expired challenge error = %v, want %v", err, schemas.MFAChallengeError)
+	}
+	if _, err := f.service.VerifyChallenge(context.Background(), "unknown", "101000"); err != schemas.MFAChallengeError {
+		t.Errorf("VerifyChallenge() of an unknown challenge error = %v, want %v", err, schemas.MFAChallengeError)
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	f := primeFixture()
+	codes := f.enable(t)
+
+	// codes are accepted however they are typed
+	typed := " " + strings.ToUpper(strings.Replace(codes[0], "-", " - ", 1)) + " "
+	if _, err := f.service.VerifyChallenge(context.Background(), f.challenge(t), typed); err != nil {
+		t.Fatalf("VerifyChallenge() with recovery code %q error = %v", typed, err)
+	}
+
+	if _, err := f.service.VerifyChallenge(context.Background(), f.challenge(t), codes[0]); err != schemas.InvalidMFACodeError {
+		t.Errorf("VerifyChallenge() with a used recovery code error = %v, want %v", err, schemas.InvalidMFACodeError)
+	}
+	if _, err := f.service.VerifyChallenge(context.Background(), f.challenge(t), codes[1]); err != nil {
+		t.Errorf("VerifyChallenge() with another recovery code error = %v", err)
+	}
+}
+
+func TestDisableRequiresCode(t *testing.T) {
+	f := primeFixture()
+	codes := f.enable(t)
+
+	if err := f.service.Disable(context.Background(), testSessionId, "999999"); err != schemas.InvalidMFACodeError {
+		t.Fatalf("Disable() with a wrong code error = %v, want %v", err, schemas.InvalidMFACodeError)
+	}
+	if err := f.service.Disable(context.Background(), testSessionId, codes[0]); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+	if enabled, _ := f.service.Enabled(context.Background(), testCredentialId); enabled {
+		t.Error("second factor is still enabled")
+	}
+}
+
+func TestSignInWithSecondFactor(t *testing.T) {
+	f := primeFixture()
+	f.enable(t)
+	refresh := newFakeRefreshTokens()
+	throttle := NewLoginThrottle(discardLogger, newFakeLoginAttempts(),
+		config.LockoutConfig{LoginFreeAttempts: 5, IPFreeAttempts: 20, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour})
+	service := NewService(discardLogger, newFakeCredentials(f.credential), f.sessions, refresh, nil, fakeTokenService{},
+		fakeHash{}, password.Policy{}, throttle, AccountService{}, f.service, 15*time.Minute, 24*time.Hour)
+	device := models.Device{UserAgent: "test", IP: "192.0.2.1"}
+
+	_, err := service.SignIn(context.Background(), models.Auth{Login: "listener", Password: testPassword}, device)
+	var required schemas.MFARequiredError
+	if !errors.As(err, &required) {
+		t.Fatalf("SignIn() error = %v, want %T", err, required)
+	}
+	if refresh.count() != 0 {
+		t.Fatal("SignIn() issued tokens before the second factor")
+	}
+
+	tokens, err := service.CompleteSignIn(context.Background(), required.ChallengeToken, "101000", device)
+	if err != nil {
+		t.Fatalf("CompleteSignIn() error = %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || refresh.count() != 1 {
+		t.Errorf("CompleteSignIn() = %+v, want a new session", tokens)
+	}
+}
@@ -0,0 +1,74 @@
+package repostiroties
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	credentialTokensTable = "credential_tokens"
+)
+
+type credentialTokenRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func (r credentialTokenRepository) AddToken(ctx context.Context, credentialToken models.CredentialToken) error {
+	query := fmt.Sprintf("INSERT INTO %s (token_hash, credential_id, purpose, expires_at) values ($1, $2, $3, $4)",
+		credentialTokensTable)
+	_, err := r.db.ExecContext(ctx, query, credentialToken.TokenHash, credentialToken.CredentialId, credentialToken.Purpose,
+		credentialToken.ExpiresAt)
+	return err
+}
+
+// GetValidToken returns a token with the given purpose that has neither been
+// used nor expired yet.
+func (r credentialTokenRepository) GetValidToken(ctx context.Context, tokenHash string, purpose string) (models.CredentialToken, error) {
+	var credentialToken models.CredentialToken
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL
+		AND expires_at > now()`, credentialTokensTable)
+	err := r.db.GetContext(ctx, &credentialToken, query, tokenHash, purpose)
+	if err == sql.ErrNoRows {
+		return credentialToken, NotFoundError
+	}
+	return credentialToken, err
+}
+
+// UseToken marks a valid token as used and returns its credential. Only one
+// of concurrent callers succeeds, the others get NotFoundError.
+func (r credentialTokenRepository) UseToken(ctx context.Context, tokenHash string, purpose string) (string, error) {
+	var credentialId string
+	query := fmt.Sprintf(`UPDATE %s SET used_at = now() WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL
+		AND expires_at > now() RETURNING credential_id`, credentialTokensTable)
+	err := r.db.GetContext(ctx, &credentialId, query, tokenHash, purpose)
+	if err == sql.ErrNoRows {
+		return "", NotFoundError
+	}
+	return credentialId, err
+}
+
+// InvalidateTokens marks all unused tokens of a credential with the given
+// purpose as used, so only the most recently sent one works.
+func (r credentialTokenRepository) InvalidateTokens(ctx context.Context, credentialId string, purpose string) error {
+	query := fmt.Sprintf(`UPDATE %s SET used_at = now() WHERE credential_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		credentialTokensTable)
+	_, err := r.db.ExecContext(ctx, query, credentialId, purpose)
+	return err
+}
+
+type CredentialTokenRepository interface {
+	AddToken(ctx context.Context, credentialToken models.CredentialToken) error
+	GetValidToken(ctx context.Context, tokenHash string, purpose string) (models.CredentialToken, error)
+	UseToken(ctx context.Context, tokenHash string, purpose string) (string, error)
+	InvalidateTokens(ctx context.Context, credentialId string, purpose string) error
+}
+
+func NewCredentialTokenRepository(db *sqlx.DB, logger *slog.Logger) CredentialTokenRepository {
+	return credentialTokenRepository{db, logger}
+}
@@ -1,9 +1,10 @@
 package repostiroties
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Feokrat/music-dating-app/sessions/internal/models"
 	"github.com/jmoiron/sqlx"
@@ -11,53 +12,82 @@ import (
 
 type credentialsRepository struct {
 	db     *sqlx.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 const (
 	credentialsTable = "credentials"
-	adminRole        = "admin"
-	userRole         = "user"
-	primaryUserRole  = "primary_user"
 )
 
-func (c credentialsRepository) AddCredential(credential models.Credentials) (string, error) {
+// the unique constraints of the credentials table
+var credentialsConstraints = map[string]error{
+	"credentials_login_key": LoginExistsError,
+	"credentials_email_idx": EmailExistsError,
+}
+
+func (c credentialsRepository) AddCredential(ctx context.Context, credential models.Credentials) (string, error) {
 	var credentialId string
-	query := fmt.Sprintf("INSERT INTO %s (id, login, password_hash, session_id, role)"+
-		" values ($1, $2, $3, $4, $5) RETURNING id", credentialsTable)
-	row := c.db.QueryRow(query, credential.Id, credential.Login, credential.PasswordHash, credential.SessionId, userRole)
+	query := fmt.Sprintf("INSERT INTO %s (id, login, password_hash, user_id, role, email)"+
+		" values ($1, $2, $3, $4, $5, $6) RETURNING id", credentialsTable)
+	row := c.db.QueryRowContext(ctx, query, credential.Id, credential.Login, credential.PasswordHash, credential.UserId, credential.Role,
+		credential.Email)
 	if err := row.Scan(&credentialId); err != nil {
-		return "", err
+		return "", constraintError(err, credentialsConstraints)
 	}
 	return credentialId, nil
 }
 
-func (c credentialsRepository) GetCredentialBySessionId(sessionId string) (models.Credentials, error) {
+func (c credentialsRepository) GetCredentialById(ctx context.Context, credentialId string) (models.Credentials, error) {
 	var credential models.Credentials
-	query := fmt.Sprintf(`SELECT * FROM %s WHERE session_id = $1`, credentialsTable)
-	err := c.db.Get(&credential, query, sessionId)
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, credentialsTable)
+	err := c.db.GetContext(ctx, &credential, query, credentialId)
 	if err == sql.ErrNoRows {
 		return credential, NotFoundError
 	}
 	return credential, err
 }
 
-func (c credentialsRepository) GetCredentialByLogin(login string) (models.Credentials, error) {
+func (c credentialsRepository) GetCredentialByLogin(ctx context.Context, login string) (models.Credentials, error) {
 	var credential models.Credentials
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE login = $1`, credentialsTable)
-	err := c.db.Get(&credential, query, login)
+	err := c.db.GetContext(ctx, &credential, query, login)
 	if err == sql.ErrNoRows {
 		return credential, NotFoundError
 	}
 	return credential, err
 }
 
+func (c credentialsRepository) GetCredentialByEmail(ctx context.Context, email string) (models.Credentials, error) {
+	var credential models.Credentials
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE lower(email) = lower($1)`, credentialsTable)
+	err := c.db.GetContext(ctx, &credential, query, email)
+	if err == sql.ErrNoRows {
+		return credential, NotFoundError
+	}
+	return credential, err
+}
+
+func (c credentialsRepository) SetEmailVerified(ctx context.Context, credentialId string) error {
+	query := fmt.Sprintf(`UPDATE %s SET email_verified = true WHERE id = $1`, credentialsTable)
+	_, err := c.db.ExecContext(ctx, query, credentialId)
+	return err
+}
+
+func (c credentialsRepository) UpdatePasswordHash(ctx context.Context, credentialId string, passwordHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET password_hash = $1 WHERE id = $2`, credentialsTable)
+	_, err := c.db.ExecContext(ctx, query, passwordHash, credentialId)
+	return err
+}
+
 type CredentialsRepository interface {
-	GetCredentialBySessionId(sessionId string) (models.Credentials, error)
-	GetCredentialByLogin(login string) (models.Credentials, error)
-	AddCredential(credential models.Credentials) (string, error)
+	GetCredentialById(ctx context.Context, credentialId string) (models.Credentials, error)
+	GetCredentialByLogin(ctx context.Context, login string) (models.Credentials, error)
+	GetCredentialByEmail(ctx context.Context, email string) (models.Credentials, error)
+	AddCredential(ctx context.Context, credential models.Credentials) (string, error)
+	SetEmailVerified(ctx context.Context, credentialId string) error
+	UpdatePasswordHash(ctx context.Context, credentialId string, passwordHash string) error
 }
 
-func NewCredentialsRepository(db *sqlx.DB, logger *log.Logger) CredentialsRepository {
+func NewCredentialsRepository(db *sqlx.DB, logger *slog.Logger) CredentialsRepository {
 	return credentialsRepository{db, logger}
 }
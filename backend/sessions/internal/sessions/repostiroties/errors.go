@@ -1,7 +1,28 @@
 package repostiroties
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
 
 var (
-	NotFoundError = errors.New("no rows match specified search parameters in database")
+	NotFoundError    = errors.New("no rows match specified search parameters in database")
+	LoginExistsError = errors.New("credentials with this login already exist")
+	EmailExistsError = errors.New("credentials with this email already exist")
 )
+
+// uniqueViolation is the SQLSTATE of a violated unique constraint.
+const uniqueViolation = "23505"
+
+// constraintError maps a violation of one of the unique constraints to the
+// error given for it, other errors are returned as they are.
+func constraintError(err error, errs map[string]error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		if mapped, ok := errs[pqErr.Constraint]; ok {
+			return mapped
+		}
+	}
+	return err
+}
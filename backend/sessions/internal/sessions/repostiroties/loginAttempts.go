@@ -0,0 +1,87 @@
+package repostiroties
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	loginAttemptsTable = "login_attempts"
+	lockoutsTable      = "lockouts"
+)
+
+type loginAttemptRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// GetLockedUntil returns the end of the current lockout, zero time if there
+// is none.
+func (r loginAttemptRepository) GetLockedUntil(ctx context.Context, kind, value string) (time.Time, error) {
+	var lockedUntil sql.NullTime
+	query := fmt.Sprintf(`SELECT locked_until FROM %s WHERE kind = $1 AND value = $2`, loginAttemptsTable)
+	err := r.db.GetContext(ctx, &lockedUntil, query, kind, value)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, err
+	}
+	return lockedUntil.Time, nil
+}
+
+// RegisterFailure counts a failed attempt and returns the number of failures
+// since the given time; older failures are forgotten.
+func (r loginAttemptRepository) RegisterFailure(ctx context.Context, kind, value string, since time.Time) (int, error) {
+	var failures int
+	query := fmt.Sprintf(`INSERT INTO %[1]s (kind, value, failures, last_failure_at) values ($1, $2, 1, now())
+		ON CONFLICT (kind, value) DO UPDATE SET
+			failures = CASE WHEN %[1]s.last_failure_at < $3 THEN 1 ELSE %[1]s.failures + 1 END,
+			last_failure_at = now()
+		RETURNING failures`, loginAttemptsTable)
+	err := r.db.GetContext(ctx, &failures, query, kind, value, since)
+	return failures, err
+}
+
+// Lock blocks attempts until lockout.LockedUntil and keeps an audit record
+// of it.
+func (r loginAttemptRepository) Lock(ctx context.Context, lockout models.Lockout) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`UPDATE %s SET locked_until = $1 WHERE kind = $2 AND value = $3`, loginAttemptsTable)
+	if _, err = tx.ExecContext(ctx, query, lockout.LockedUntil, lockout.Kind, lockout.Value); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`INSERT INTO %s (id, kind, value, failures, locked_until) values ($1, $2, $3, $4, $5)`,
+		lockoutsTable)
+	if _, err = tx.ExecContext(ctx, query, lockout.Id, lockout.Kind, lockout.Value, lockout.Failures, lockout.LockedUntil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r loginAttemptRepository) Reset(ctx context.Context, kind, value string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE kind = $1 AND value = $2`, loginAttemptsTable)
+	_, err := r.db.ExecContext(ctx, query, kind, value)
+	return err
+}
+
+type LoginAttemptRepository interface {
+	GetLockedUntil(ctx context.Context, kind, value string) (time.Time, error)
+	RegisterFailure(ctx context.Context, kind, value string, since time.Time) (int, error)
+	Lock(ctx context.Context, lockout models.Lockout) error
+	Reset(ctx context.Context, kind, value string) error
+}
+
+func NewLoginAttemptRepository(db *sqlx.DB, logger *slog.Logger) LoginAttemptRepository {
+	return loginAttemptRepository{db, logger}
+}
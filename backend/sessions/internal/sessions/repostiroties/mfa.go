@@ -0,0 +1,139 @@
+package repostiroties
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	mfaTable           = "mfa"
+	recoveryCodesTable = "recovery_codes"
+)
+
+type mfaRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func (r mfaRepository) GetMFA(ctx context.Context, credentialId string) (models.MFA, error) {
+	var mfa models.MFA
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE credential_id = $1`, mfaTable)
+	err := r.db.GetContext(ctx, &mfa, query, credentialId)
+	if err == sql.ErrNoRows {
+		return mfa, NotFoundError
+	}
+	return mfa, err
+}
+
+// SaveSecret starts a new enrollment, replacing one that was never confirmed.
+// It returns false when the second factor is already confirmed.
+func (r mfaRepository) SaveSecret(ctx context.Context, credentialId string, secret string) (bool, error) {
+	query := fmt.Sprintf(`INSERT INTO %[1]s (credential_id, secret) values ($1, $2)
+		ON CONFLICT (credential_id) DO UPDATE SET secret = $2, last_used_step = 0, created_at = now()
+		WHERE NOT %[1]s.confirmed`, mfaTable)
+	res, err := r.db.ExecContext(ctx, query, credentialId, secret)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// Confirm enables the second factor and replaces the recovery codes.
+func (r mfaRepository) Confirm(ctx context.Context, credentialId string, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`UPDATE %s SET confirmed = true, last_used_step = $1 WHERE credential_id = $2`, mfaTable)
+	if _, err = tx.ExecContext(ctx, query, step, credentialId); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`DELETE FROM %s WHERE credential_id = $1`, recoveryCodesTable)
+	if _, err = tx.ExecContext(ctx, query, credentialId); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`INSERT INTO %s (id, credential_id, code_hash) values ($1, $2, $3)`, recoveryCodesTable)
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err = tx.ExecContext(ctx, query, uuid.New().String(), credentialId, codeHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records the time step of an accepted code. It returns false when a
+// code of the same or a later step has been used already.
+func (r mfaRepository) UseStep(ctx context.Context, credentialId string, step int64) (bool, error) {
+	query := fmt.Sprintf(`UPDATE %s SET last_used_step = $1 WHERE credential_id = $2 AND last_used_step < $1`,
+		mfaTable)
+	res, err := r.db.ExecContext(ctx, query, step, credentialId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+func (r mfaRepository) GetUnusedRecoveryCodes(ctx context.Context, credentialId string) ([]models.RecoveryCode, error) {
+	var codes []models.RecoveryCode
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE credential_id = $1 AND used_at IS NULL`, recoveryCodesTable)
+	err := r.db.SelectContext(ctx, &codes, query, credentialId)
+	return codes, err
+}
+
+func (r mfaRepository) UseRecoveryCode(ctx context.Context, id string) (bool, error) {
+	query := fmt.Sprintf(`UPDATE %s SET used_at = now() WHERE id = $1 AND used_at IS NULL`, recoveryCodesTable)
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// DeleteMFA disables the second factor, recovery codes are dropped with it.
+func (r mfaRepository) DeleteMFA(ctx context.Context, credentialId string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE credential_id = $1`, recoveryCodesTable)
+	if _, err = tx.ExecContext(ctx, query, credentialId); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`DELETE FROM %s WHERE credential_id = $1`, mfaTable)
+	if _, err = tx.ExecContext(ctx, query, credentialId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type MFARepository interface {
+	GetMFA(ctx context.Context, credentialId string) (models.MFA, error)
+	SaveSecret(ctx context.Context, credentialId string, secret string) (bool, error)
+	Confirm(ctx context.Context, credentialId string, step int64, recoveryCodeHashes []string) error
+	UseStep(ctx context.Context, credentialId string, step int64) (bool, error)
+	GetUnusedRecoveryCodes(ctx context.Context, credentialId string) ([]models.RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, id string) (bool, error)
+	DeleteMFA(ctx context.Context, credentialId string) error
+}
+
+func NewMFARepository(db *sqlx.DB, logger *slog.Logger) MFARepository {
+	return mfaRepository{db, logger}
+}
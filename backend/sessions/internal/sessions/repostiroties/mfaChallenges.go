@@ -0,0 +1,68 @@
+package repostiroties
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	mfaChallengesTable = "mfa_challenges"
+)
+
+type mfaChallengeRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func (r mfaChallengeRepository) AddChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	query := fmt.Sprintf("INSERT INTO %s (token_hash, credential_id, expires_at) values ($1, $2, $3)",
+		mfaChallengesTable)
+	_, err := r.db.ExecContext(ctx, query, challenge.TokenHash, challenge.CredentialId, challenge.ExpiresAt)
+	return err
+}
+
+// GetValidChallenge returns a challenge that has neither been used nor
+// expired and still has attempts left.
+func (r mfaChallengeRepository) GetValidChallenge(ctx context.Context, tokenHash string, maxAttempts int) (models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		AND attempts < $2`, mfaChallengesTable)
+	err := r.db.GetContext(ctx, &challenge, query, tokenHash, maxAttempts)
+	if err == sql.ErrNoRows {
+		return challenge, NotFoundError
+	}
+	return challenge, err
+}
+
+func (r mfaChallengeRepository) AddFailedAttempt(ctx context.Context, tokenHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET attempts = attempts + 1 WHERE token_hash = $1`, mfaChallengesTable)
+	_, err := r.db.ExecContext(ctx, query, tokenHash)
+	return err
+}
+
+// UseChallenge marks a challenge as used, only one of concurrent callers gets true.
+func (r mfaChallengeRepository) UseChallenge(ctx context.Context, tokenHash string) (bool, error) {
+	query := fmt.Sprintf(`UPDATE %s SET used_at = now() WHERE token_hash = $1 AND used_at IS NULL`, mfaChallengesTable)
+	res, err := r.db.ExecContext(ctx, query, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+type MFAChallengeRepository interface {
+	AddChallenge(ctx context.Context, challenge models.MFAChallenge) error
+	GetValidChallenge(ctx context.Context, tokenHash string, maxAttempts int) (models.MFAChallenge, error)
+	AddFailedAttempt(ctx context.Context, tokenHash string) error
+	UseChallenge(ctx context.Context, tokenHash string) (bool, error)
+}
+
+func NewMFAChallengeRepository(db *sqlx.DB, logger *slog.Logger) MFAChallengeRepository {
+	return mfaChallengeRepository{db, logger}
+}
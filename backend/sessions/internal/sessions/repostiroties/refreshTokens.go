@@ -0,0 +1,62 @@
+package repostiroties
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	refreshTokensTable = "refresh_tokens"
+)
+
+type refreshTokenRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func (r refreshTokenRepository) AddRefreshToken(ctx context.Context, sessionId string, tokenHash string) error {
+	query := fmt.Sprintf("INSERT INTO %s (token_hash, session_id) values ($1, $2)", refreshTokensTable)
+	_, err := r.db.ExecContext(ctx, query, tokenHash, sessionId)
+	return err
+}
+
+func (r refreshTokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshTokens, error) {
+	var refreshToken models.RefreshTokens
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE token_hash = $1`, refreshTokensTable)
+	err := r.db.GetContext(ctx, &refreshToken, query, tokenHash)
+	if err == sql.ErrNoRows {
+		return refreshToken, NotFoundError
+	}
+	return refreshToken, err
+}
+
+// RotateRefreshToken marks the refresh token as used. It reports false when
+// the token had already been rotated, so two concurrent refreshes with the
+// same token can not both succeed.
+func (r refreshTokenRepository) RotateRefreshToken(ctx context.Context, tokenHash string) (bool, error) {
+	query := fmt.Sprintf(`UPDATE %s SET is_rotated = true WHERE token_hash = $1 AND NOT is_rotated`, refreshTokensTable)
+	res, err := r.db.ExecContext(ctx, query, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+type RefreshTokenRepository interface {
+	AddRefreshToken(ctx context.Context, sessionId string, tokenHash string) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshTokens, error)
+	RotateRefreshToken(ctx context.Context, tokenHash string) (bool, error)
+}
+
+func NewRefreshTokenRepository(db *sqlx.DB, logger *slog.Logger) RefreshTokenRepository {
+	return refreshTokenRepository{db, logger}
+}
@@ -0,0 +1,64 @@
+package repostiroties
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	revokedTokensTable = "revoked_tokens"
+)
+
+type revokedTokenRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func (r revokedTokenRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := fmt.Sprintf("INSERT INTO %s (jti, expires_at) values ($1, $2) ON CONFLICT (jti) DO NOTHING",
+		revokedTokensTable)
+	_, err := r.db.ExecContext(ctx, query, jti, expiresAt)
+	return err
+}
+
+func (r revokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE jti = $1)`, revokedTokensTable)
+	err := r.db.GetContext(ctx, &exists, query, jti)
+	return exists, err
+}
+
+// GetRevokedTokens lists the revoked tokens that have not expired yet.
+func (r revokedTokenRepository) GetRevokedTokens(ctx context.Context) ([]models.Revocation, error) {
+	var revocations []models.Revocation
+	query := fmt.Sprintf(`SELECT jti AS id, expires_at FROM %s WHERE expires_at > $1`, revokedTokensTable)
+	err := r.db.SelectContext(ctx, &revocations, query, time.Now())
+	return revocations, err
+}
+
+// DeleteExpired removes entries of tokens that would be rejected by their
+// expiration time anyway.
+func (r revokedTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, revokedTokensTable)
+	res, err := r.db.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type RevokedTokenRepository interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	GetRevokedTokens(ctx context.Context) ([]models.Revocation, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+func NewRevokedTokenRepository(db *sqlx.DB, logger *slog.Logger) RevokedTokenRepository {
+	return revokedTokenRepository{db, logger}
+}
@@ -1,9 +1,11 @@
 package repostiroties
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/Feokrat/music-dating-app/sessions/internal/models"
 	"github.com/jmoiron/sqlx"
@@ -11,18 +13,21 @@ import (
 
 const (
 	sessionTable = "sessions"
+	// lastSeenPrecision limits how often an active session row is rewritten
+	lastSeenPrecision = time.Minute
 )
 
 type sessionRepository struct {
 	db     *sqlx.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
-func (s sessionRepository) AddSession(session models.Sessions) (string, error) {
+func (s sessionRepository) AddSession(ctx context.Context, session models.Sessions) (string, error) {
 	var sessionId string
-	query := fmt.Sprintf("INSERT INTO %s (id, refresh_token, access_token, expires_at, user_id, is_authenticated)"+
-		" values ($1, $2, $3, $4, $5, $6) RETURNING id", sessionTable)
-	row := s.db.QueryRow(query, session.Id, session.RefreshToken, session.AccessToken, session.ExpiresAt, session.UserID, session.IsAuthenticated)
+	query := fmt.Sprintf("INSERT INTO %s (id, credential_id, user_id, user_agent, ip, expires_at, last_seen_at, is_authenticated)"+
+		" values ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id", sessionTable)
+	row := s.db.QueryRowContext(ctx, query, session.Id, session.CredentialId, session.UserID, session.UserAgent, session.IP,
+		session.ExpiresAt, session.LastSeenAt, session.IsAuthenticated)
 	if err := row.Scan(&sessionId); err != nil {
 		return "", err
 	}
@@ -30,32 +35,76 @@ func (s sessionRepository) AddSession(session models.Sessions) (string, error) {
 
 }
 
-func (s sessionRepository) GetSessionByUserId(userId string) (models.Sessions, error) {
-	var session models.Sessions
-	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1`, sessionTable)
-	err := s.db.Get(&session, query, userId)
-	if err == sql.ErrNoRows {
-		return session, NotFoundError
-	}
-	return session, err
+func (s sessionRepository) GetActiveSessionsByUserId(ctx context.Context, userId string) ([]models.Sessions, error) {
+	var sessions []models.Sessions
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1 AND is_authenticated AND expires_at > $2
+		ORDER BY last_seen_at DESC`, sessionTable)
+	err := s.db.SelectContext(ctx, &sessions, query, userId, time.Now())
+	return sessions, err
 }
 
-func (s sessionRepository) GetSessionById(sessionId string) (models.Sessions, error) {
+func (s sessionRepository) GetSessionById(ctx context.Context, sessionId string) (models.Sessions, error) {
 	var session models.Sessions
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, sessionTable)
-	err := s.db.Get(&session, query, sessionId)
+	err := s.db.GetContext(ctx, &session, query, sessionId)
 	if err == sql.ErrNoRows {
 		return session, NotFoundError
 	}
 	return session, err
 }
 
+func (s sessionRepository) TouchSession(ctx context.Context, sessionId string) error {
+	now := time.Now()
+	query := fmt.Sprintf(`UPDATE %s SET last_seen_at = $1 WHERE id = $2 AND last_seen_at < $3`, sessionTable)
+	_, err := s.db.ExecContext(ctx, query, now, sessionId, now.Add(-lastSeenPrecision))
+	return err
+}
+
+func (s sessionRepository) ExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET expires_at = $1, last_seen_at = $2 WHERE id = $3`, sessionTable)
+	_, err := s.db.ExecContext(ctx, query, expiresAt, time.Now(), sessionId)
+	return err
+}
+
+func (s sessionRepository) RevokeSession(ctx context.Context, sessionId string) error {
+	query := fmt.Sprintf(`UPDATE %s SET is_authenticated = false, revoked_at = COALESCE(revoked_at, $1) WHERE id = $2`,
+		sessionTable)
+	_, err := s.db.ExecContext(ctx, query, time.Now(), sessionId)
+	return err
+}
+
+func (s sessionRepository) RevokeSessionsByUserId(ctx context.Context, userId string) error {
+	query := fmt.Sprintf(`UPDATE %s SET is_authenticated = false, revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+		sessionTable)
+	_, err := s.db.ExecContext(ctx, query, time.Now(), userId)
+	return err
+}
+
+// GetRevokedSessions lists the sessions revoked within the last tokenLifetime,
+// each until the last access token issued to it expires.
+func (s sessionRepository) GetRevokedSessions(ctx context.Context, tokenLifetime time.Duration) ([]models.Revocation, error) {
+	var revocations []models.Revocation
+	query := fmt.Sprintf(`SELECT id, revoked_at AS expires_at FROM %s WHERE revoked_at > $1`, sessionTable)
+	if err := s.db.SelectContext(ctx, &revocations, query, time.Now().Add(-tokenLifetime)); err != nil {
+		return nil, err
+	}
+	for i := range revocations {
+		revocations[i].ExpiresAt = revocations[i].ExpiresAt.Add(tokenLifetime)
+	}
+	return revocations, nil
+}
+
 type SessionRepository interface {
-	GetSessionByUserId(userId string) (models.Sessions, error)
-	GetSessionById(sessionId string) (models.Sessions, error)
-	AddSession(session models.Sessions) (string, error)
+	GetActiveSessionsByUserId(ctx context.Context, userId string) ([]models.Sessions, error)
+	GetSessionById(ctx context.Context, sessionId string) (models.Sessions, error)
+	AddSession(ctx context.Context, session models.Sessions) (string, error)
+	TouchSession(ctx context.Context, sessionId string) error
+	ExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionId string) error
+	RevokeSessionsByUserId(ctx context.Context, userId string) error
+	GetRevokedSessions(ctx context.Context, tokenLifetime time.Duration) ([]models.Revocation, error)
 }
 
-func NewSessionRepository(db *sqlx.DB, logger *log.Logger) SessionRepository {
+func NewSessionRepository(db *sqlx.DB, logger *slog.Logger) SessionRepository {
 	return sessionRepository{db, logger}
 }
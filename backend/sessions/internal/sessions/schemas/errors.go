@@ -1,10 +1,59 @@
 package schemas
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/Feokrat/music-dating-app/sessions/pkg/problem"
+)
+
+// RightsError means 401 on an access token and 403 on someone else's session,
+// so handlers map it themselves.
+var RightsError = errors.New("session doesn't belong to user")
+
+// The other errors of the service are problems, handlers respond with them
+// as they are.
 var (
-	RightsError             = errors.New("session doesn't belong to user")
-	UserAlreadyExistsError  = errors.New("user with specified login already exists")
-	InvalidCredentialsError = errors.New("invalid login or password")
-	TokenError              = errors.New("")
+	UserAlreadyExistsError   = problem.New(http.StatusConflict, "user_already_exists", "user with specified login already exists")
+	InvalidCredentialsError  = problem.New(http.StatusUnauthorized, "invalid_credentials", "invalid login or password")
+	TokenError               = problem.New(http.StatusUnauthorized, "invalid_token", "invalid token")
+	InvalidRefreshTokenError = problem.New(http.StatusUnauthorized, "invalid_refresh_token", "invalid refresh token")
+	RefreshTokenReusedError  = problem.New(http.StatusUnauthorized, "refresh_token_reused", "refresh token has already been used, session revoked")
+	SessionExpiredError      = problem.New(http.StatusUnauthorized, "session_expired", "session has expired or was revoked")
+	TokenRevokedError        = problem.New(http.StatusUnauthorized, "token_revoked", "token has been revoked")
+	EmailAlreadyUsedError    = problem.New(http.StatusConflict, "email_already_used", "email is already used by another account")
+	CredentialTokenError     = problem.New(http.StatusBadRequest, "invalid_link", "invalid or expired link, request a new one")
+	MFANotAvailableError     = problem.New(http.StatusForbidden, "mfa_not_available", "two-factor authentication is only available for Prime accounts")
+	MFAAlreadyEnabledError   = problem.New(http.StatusConflict, "mfa_already_enabled", "two-factor authentication is already enabled")
+	MFANotEnrolledError      = problem.New(http.StatusBadRequest, "mfa_not_enrolled", "two-factor authentication is not enabled")
+	InvalidMFACodeError      = problem.New(http.StatusUnauthorized, "invalid_mfa_code", "invalid authentication code")
+	MFAChallengeError        = problem.New(http.StatusUnauthorized, "invalid_mfa_challenge", "invalid or expired sign in challenge, sign in again")
 )
+
+// LockedError is returned while sign in is blocked after too many failed
+// attempts.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e LockedError) Error() string {
+	return fmt.Sprintf("too many failed sign in attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e LockedError) Problem() *problem.Problem {
+	return problem.New(http.StatusTooManyRequests, "sign_in_locked", e.Error())
+}
+
+// MFARequiredError is returned by sign in when the password was right but the
+// account has two-factor authentication enabled. The challenge token is
+// exchanged for a session together with a valid code.
+type MFARequiredError struct {
+	ChallengeToken string
+	ExpiresIn      time.Duration
+}
+
+func (e MFARequiredError) Error() string {
+	return "two-factor authentication required"
+}
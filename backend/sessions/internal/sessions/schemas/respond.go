@@ -1,6 +1,10 @@
 package schemas
 
-import "github.com/gin-gonic/gin"
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
 
 type dataResponse struct {
 	Data interface{} `json:"data"`
@@ -10,16 +14,45 @@ type tokenResponse struct {
 	Token string `json:"token"`
 }
 
-type idResponse struct {
-	ID interface{} `json:"id"`
+type tokensResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
 }
 
-type messageResponse struct {
-	Message string `json:"message"`
+type SessionResponse struct {
+	Id         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
 }
 
-func RespondWithError(c *gin.Context, statusCode int, message string) {
-	c.AbortWithStatusJSON(statusCode, messageResponse{message})
+type RevocationResponse struct {
+	Id        string    `json:"id"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type revocationsResponse struct {
+	Tokens   []RevocationResponse `json:"tokens"`
+	Sessions []RevocationResponse `json:"sessions"`
+}
+
+type challengeResponse struct {
+	Status         string `json:"status"`
+	ChallengeToken string `json:"challengeToken"`
+	ExpiresIn      int64  `json:"expiresIn"`
+}
+
+type idResponse struct {
+	ID interface{} `json:"id"`
+}
+
+type identityResponse struct {
+	ID   interface{} `json:"id"`
+	Role string      `json:"role"`
 }
 
 func RespondWithData(c *gin.Context, statusCode int, data interface{}) {
@@ -30,6 +63,27 @@ func RespondWithUserId(c *gin.Context, statusCode int, id string) {
 	c.JSON(statusCode, idResponse{id})
 }
 
+func RespondWithIdentity(c *gin.Context, statusCode int, id string, role string) {
+	c.JSON(statusCode, identityResponse{id, role})
+}
+
 func RespondWithToken(c *gin.Context, statusCode int, token string) {
 	c.JSON(statusCode, tokenResponse{token})
 }
+
+// RespondWithTokens writes an access/refresh token pair, expiresIn is the
+// access token lifetime in seconds.
+func RespondWithTokens(c *gin.Context, statusCode int, accessToken, refreshToken string, expiresIn int64) {
+	c.JSON(statusCode, tokensResponse{accessToken, refreshToken, expiresIn})
+}
+
+// RespondWithRevocations writes the ids of revoked tokens and sessions.
+func RespondWithRevocations(c *gin.Context, statusCode int, tokens, sessions []RevocationResponse) {
+	c.JSON(statusCode, revocationsResponse{tokens, sessions})
+}
+
+// RespondWithChallenge tells the client that sign in needs a second factor,
+// expiresIn is the challenge lifetime in seconds.
+func RespondWithChallenge(c *gin.Context, statusCode int, challengeToken string, expiresIn int64) {
+	c.JSON(statusCode, challengeResponse{"mfa_required", challengeToken, expiresIn})
+}
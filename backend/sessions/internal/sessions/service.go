@@ -1,117 +1,382 @@
 package sessions
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
 	"github.com/Feokrat/music-dating-app/sessions/internal/models"
 	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
-	"log"
 
 	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
 
 	"github.com/Feokrat/music-dating-app/sessions/pkg/hash"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
 	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
 	"github.com/google/uuid"
 )
 
 type AuthService struct {
-	logger               *log.Logger
-	credentialRepository repostiroties.CredentialsRepository
-	sessionRepository    repostiroties.SessionRepository
-	tokenService         token.TokenService
-	hashService          hash.HashService
+	logger                 *slog.Logger
+	credentialRepository   repostiroties.CredentialsRepository
+	sessionRepository      repostiroties.SessionRepository
+	refreshTokenRepository repostiroties.RefreshTokenRepository
+	revokedTokenRepository repostiroties.RevokedTokenRepository
+	tokenService           token.TokenService
+	hashService            hash.HashService
+	passwordPolicy         password.Policy
+	throttle               LoginThrottle
+	accountService         AccountService
+	mfaService             MFAService
+	accessDuration         time.Duration
+	refreshDuration        time.Duration
 }
 
 type AuthServiceInterface interface {
-	SignIn(userInfo models.Auth) (string, error)
-	Register(user models.Register) (string, error)
-	Authorize(token string) (string, error)
+	SignIn(ctx context.Context, userInfo models.Auth, device models.Device) (models.Tokens, error)
+	CompleteSignIn(ctx context.Context, challengeToken string, code string, device models.Device) (models.Tokens, error)
+	Register(ctx context.Context, user models.Register, device models.Device) (models.Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (models.Tokens, error)
+	Authorize(ctx context.Context, token string) (token.Claims, error)
+	SignOut(ctx context.Context, claims token.Claims) error
+	GetRevocations(ctx context.Context) (models.Revocations, error)
+	GetActiveSessions(ctx context.Context, userId string) ([]models.Sessions, error)
+	RevokeSession(ctx context.Context, userId string, sessionId string) error
+	RevokeAllSessions(ctx context.Context, userId string) error
 }
 
-func NewService(logger *log.Logger, credentialRepository repostiroties.CredentialsRepository,
-	sessionRepository repostiroties.SessionRepository, tokenService token.TokenService,
-	hashService hash.HashService) AuthService {
+var _ AuthServiceInterface = (*AuthService)(nil)
+
+func NewService(logger *slog.Logger, credentialRepository repostiroties.CredentialsRepository,
+	sessionRepository repostiroties.SessionRepository, refreshTokenRepository repostiroties.RefreshTokenRepository,
+	revokedTokenRepository repostiroties.RevokedTokenRepository, tokenService token.TokenService,
+	hashService hash.HashService, passwordPolicy password.Policy, throttle LoginThrottle, accountService AccountService,
+	mfaService MFAService, accessDuration, refreshDuration time.Duration) AuthService {
 	return AuthService{logger: logger, credentialRepository: credentialRepository, sessionRepository: sessionRepository,
-		tokenService: tokenService, hashService: hashService}
+		refreshTokenRepository: refreshTokenRepository, revokedTokenRepository: revokedTokenRepository,
+		tokenService: tokenService, hashService: hashService, passwordPolicy: passwordPolicy, throttle: throttle,
+		accountService: accountService, mfaService: mfaService, accessDuration: accessDuration, refreshDuration: refreshDuration}
 }
 
-func (a AuthService) SignIn(userInfo models.Auth) (string, error) {
+func (a AuthService) SignIn(ctx context.Context, userInfo models.Auth, device models.Device) (models.Tokens, error) {
+	if err := a.throttle.Check(ctx, userInfo.Login, device.IP); err != nil {
+		var locked schemas.LockedError
+		if errors.As(err, &locked) {
+			signInFailuresTotal.WithLabelValues(lockedReason).Inc()
+		}
+		return models.Tokens{}, err
+	}
 
-	credentials, err := a.credentialRepository.GetCredentialByLogin(userInfo.Login)
+	credentials, err := a.credentialRepository.GetCredentialByLogin(ctx, userInfo.Login)
 	if err != nil {
 		if err == repostiroties.NotFoundError {
-			return "", schemas.InvalidCredentialsError
+			return models.Tokens{}, a.failSignIn(ctx, userInfo.Login, device.IP)
 		}
-		return "", err
+		return models.Tokens{}, err
 	}
 
 	if err = a.hashService.ValidatePassword(userInfo.Password, credentials.PasswordHash); err != nil {
-		return "", schemas.InvalidCredentialsError
+		return models.Tokens{}, a.failSignIn(ctx, userInfo.Login, device.IP)
 	}
 
-	session, err := a.sessionRepository.GetSessionById(credentials.SessionId)
-	if err == repostiroties.NotFoundError {
-		return "", schemas.InvalidCredentialsError
+	if err = a.throttle.Succeed(ctx, userInfo.Login); err != nil {
+		a.logger.ErrorContext(ctx, "could not reset failed attempts", "login", userInfo.Login, "error", err)
+	}
+
+	mfaEnabled, err := a.mfaService.Enabled(ctx, credentials.Id)
+	if err != nil {
+		return models.Tokens{}, err
+	}
+	if mfaEnabled {
+		challenge, err := a.mfaService.NewChallenge(ctx, credentials.Id)
+		if err != nil {
+			return models.Tokens{}, err
+		}
+		return models.Tokens{}, schemas.MFARequiredError{ChallengeToken: challenge.Token, ExpiresIn: challenge.ExpiresIn}
 	}
 
-	return session.AccessToken, nil
+	return a.openSession(ctx, credentials, device)
 }
 
-func (a AuthService) Register(registerModel models.Register) (string, error) {
-	_, err := a.credentialRepository.GetCredentialByLogin(registerModel.Login)
+// CompleteSignIn opens the session a sign in with two-factor authentication
+// was waiting for.
+func (a AuthService) CompleteSignIn(ctx context.Context, challengeToken string, code string, device models.Device) (models.Tokens, error) {
+	credentials, err := a.mfaService.VerifyChallenge(ctx, challengeToken, code)
+	if err != nil {
+		if err == schemas.InvalidMFACodeError {
+			signInFailuresTotal.WithLabelValues(invalidMFACodeReason).Inc()
+		}
+		return models.Tokens{}, err
+	}
+
+	return a.openSession(ctx, credentials, device)
+}
+
+func (a AuthService) failSignIn(ctx context.Context, login, ip string) error {
+	signInFailuresTotal.WithLabelValues(invalidCredentialsReason).Inc()
+	if err := a.throttle.Fail(ctx, login, ip); err != nil {
+		a.logger.ErrorContext(ctx, "could not register failed attempt", "login", login, "error", err)
+	}
+	return schemas.InvalidCredentialsError
+}
+
+func (a AuthService) Register(ctx context.Context, registerModel models.Register, device models.Device) (models.Tokens, error) {
+	if err := a.passwordPolicy.Validate(registerModel.Login, registerModel.Password); err != nil {
+		return models.Tokens{}, err
+	}
+
+	_, err := a.credentialRepository.GetCredentialByLogin(ctx, registerModel.Login)
 	if err != repostiroties.NotFoundError {
 		if err == nil {
-			return "", schemas.UserAlreadyExistsError
+			return models.Tokens{}, schemas.UserAlreadyExistsError
 		}
-		return "", err
+		return models.Tokens{}, err
 	}
+
+	_, err = a.credentialRepository.GetCredentialByEmail(ctx, registerModel.Email)
+	if err != repostiroties.NotFoundError {
+		if err == nil {
+			return models.Tokens{}, schemas.EmailAlreadyUsedError
+		}
+		return models.Tokens{}, err
+	}
+
 	var credential models.Credentials
 	credential.Id = uuid.New().String()
-	credential.SessionId = uuid.New().String()
+	credential.UserId = registerModel.UserId.String()
 	credential.Login = registerModel.Login
+	credential.Email = registerModel.Email
+	credential.Role = models.UserRole
 	credential.PasswordHash, err = a.hashService.HashPassword(registerModel.Password)
 	if err != nil {
-		return "", err
+		return models.Tokens{}, err
+	}
+
+	// the lookups above race with concurrent registrations, the unique
+	// constraints decide
+	_, err = a.credentialRepository.AddCredential(ctx, credential)
+	switch err {
+	case nil:
+	case repostiroties.LoginExistsError:
+		return models.Tokens{}, schemas.UserAlreadyExistsError
+	case repostiroties.EmailExistsError:
+		return models.Tokens{}, schemas.EmailAlreadyUsedError
+	default:
+		return models.Tokens{}, err
+	}
+	registrationsTotal.Inc()
+
+	// the account works without a verified email, the user can ask for the link again
+	if err = a.accountService.SendVerification(ctx, credential); err != nil {
+		a.logger.ErrorContext(ctx, "could not send verification email", "email", credential.Email, "error", err)
+	}
+
+	return a.openSession(ctx, credential, device)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used once, presenting an already rotated one revokes its session.
+func (a AuthService) Refresh(ctx context.Context, refreshToken string) (models.Tokens, error) {
+	tokenHash := token.HashOpaqueToken(refreshToken)
+	stored, err := a.refreshTokenRepository.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return models.Tokens{}, schemas.InvalidRefreshTokenError
+		}
+		return models.Tokens{}, err
 	}
 
-	_, err = a.credentialRepository.AddCredential(credential)
+	if stored.IsRotated {
+		return models.Tokens{}, a.revokeReusedSession(ctx, stored.SessionId)
+	}
+
+	session, err := a.sessionRepository.GetSessionById(ctx, stored.SessionId)
 	if err != nil {
-		return "", err
+		return models.Tokens{}, err
+	}
+
+	if !session.IsAuthenticated || session.ExpiresAt.Before(time.Now()) {
+		return models.Tokens{}, schemas.SessionExpiredError
 	}
 
-	// temp usage of user_id
-	var userId = registerModel.UserId
-	token, _ := a.tokenService.GenerateToken(userId.String())
+	rotated, err := a.refreshTokenRepository.RotateRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return models.Tokens{}, err
+	}
+	if !rotated {
+		return models.Tokens{}, a.revokeReusedSession(ctx, stored.SessionId)
+	}
+
+	if err = a.sessionRepository.ExtendSession(ctx, session.Id, time.Now().Add(a.refreshDuration)); err != nil {
+		return models.Tokens{}, err
+	}
+
+	credential, err := a.credentialRepository.GetCredentialById(ctx, session.CredentialId)
+	if err != nil {
+		return models.Tokens{}, err
+	}
+
+	return a.issueTokens(ctx, session, credential.Role)
+}
+
+func (a AuthService) Authorize(ctx context.Context, signedToken string) (token.Claims, error) {
+	claims, err := a.tokenService.ParseToken(signedToken)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "could not parse token", "error", err)
+		return token.Claims{}, schemas.TokenError
+	}
+
+	revoked, err := a.revokedTokenRepository.IsRevoked(ctx, claims.Id)
+	if err != nil {
+		return token.Claims{}, err
+	}
+	if revoked {
+		return token.Claims{}, schemas.TokenRevokedError
+	}
+
+	session, err := a.sessionRepository.GetSessionById(ctx, claims.SessionId)
+	if err != nil {
+		if err == repostiroties.NotFoundError {
+			return token.Claims{}, schemas.SessionExpiredError
+		}
+		return token.Claims{}, err
+	}
+
+	if session.UserID != claims.UserId {
+		return token.Claims{}, schemas.RightsError
+	}
+
+	if !session.IsAuthenticated || session.ExpiresAt.Before(time.Now()) {
+		return token.Claims{}, schemas.SessionExpiredError
+	}
+
+	credential, err := a.credentialRepository.GetCredentialById(ctx, session.CredentialId)
+	if err != nil {
+		return token.Claims{}, err
+	}
+	// the stored role wins over the one in the token, so role changes apply immediately
+	claims.Role = credential.Role
+
+	if err = a.sessionRepository.TouchSession(ctx, session.Id); err != nil {
+		a.logger.ErrorContext(ctx, "could not update last seen time of session", "session_id", session.Id, "error", err)
+	}
+
+	return claims, nil
+}
+
+// SignOut revokes the access token the request was made with and closes its
+// session, so neither the token nor the session's refresh token can be used again.
+func (a AuthService) SignOut(ctx context.Context, claims token.Claims) error {
+	if err := a.revokedTokenRepository.RevokeToken(ctx, claims.Id, claims.ExpiresAt); err != nil {
+		return err
+	}
+
+	return a.sessionRepository.RevokeSession(ctx, claims.SessionId)
+}
+
+// CleanupRevokedTokens periodically drops revocation entries of tokens that
+// have expired on their own. It blocks until ctx is done.
+func (a AuthService) CleanupRevokedTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := a.revokedTokenRepository.DeleteExpired(ctx)
+			if err != nil {
+				a.logger.ErrorContext(ctx, "could not clean up revoked tokens", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				a.logger.InfoContext(ctx, "cleaned up expired revoked tokens", "deleted", deleted)
+			}
+		}
+	}
+}
+
+// GetRevocations lists the revoked tokens and sessions that access tokens
+// which have not expired yet may still belong to.
+func (a AuthService) GetRevocations(ctx context.Context) (models.Revocations, error) {
+	tokens, err := a.revokedTokenRepository.GetRevokedTokens(ctx)
+	if err != nil {
+		return models.Revocations{}, err
+	}
+
+	sessions, err := a.sessionRepository.GetRevokedSessions(ctx, a.accessDuration)
+	if err != nil {
+		return models.Revocations{}, err
+	}
+
+	return models.Revocations{Tokens: tokens, Sessions: sessions}, nil
+}
+
+func (a AuthService) GetActiveSessions(ctx context.Context, userId string) ([]models.Sessions, error) {
+	return a.sessionRepository.GetActiveSessionsByUserId(ctx, userId)
+}
+
+func (a AuthService) RevokeSession(ctx context.Context, userId string, sessionId string) error {
+	session, err := a.sessionRepository.GetSessionById(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	if session.UserID != userId {
+		return schemas.RightsError
+	}
+
+	return a.sessionRepository.RevokeSession(ctx, sessionId)
+}
+
+func (a AuthService) RevokeAllSessions(ctx context.Context, userId string) error {
+	return a.sessionRepository.RevokeSessionsByUserId(ctx, userId)
+}
+
+func (a AuthService) openSession(ctx context.Context, credential models.Credentials, device models.Device) (models.Tokens, error) {
+	now := time.Now()
 
 	var session models.Sessions
-	session.Id = credential.SessionId
+	session.Id = uuid.New().String()
+	session.CredentialId = credential.Id
+	session.UserID = credential.UserId
+	session.UserAgent = device.UserAgent
+	session.IP = device.IP
 	session.IsAuthenticated = true
-	// TODO: real expiration here is needed
-	session.ExpiresAt = "10-12-2023"
-	session.AccessToken = token
-	session.RefreshToken = token
-	session.UserID = userId.String()
+	session.ExpiresAt = now.Add(a.refreshDuration)
+	session.LastSeenAt = now
 
-	_, err = a.sessionRepository.AddSession(session)
-	if err != nil {
-		return "", err
+	if _, err := a.sessionRepository.AddSession(ctx, session); err != nil {
+		return models.Tokens{}, err
 	}
 
-	return token, nil
+	return a.issueTokens(ctx, session, credential.Role)
 }
 
-func (a AuthService) Authorize(token string) (string, error) {
-	userId, err := a.tokenService.ParseToken(token)
+func (a AuthService) issueTokens(ctx context.Context, session models.Sessions, role string) (models.Tokens, error) {
+	accessToken, err := a.tokenService.GenerateToken(session.UserID, session.Id, role)
 	if err != nil {
-		return "", err
+		return models.Tokens{}, err
 	}
 
-	session, err := a.sessionRepository.GetSessionByUserId(userId)
+	refreshToken, err := token.NewOpaqueToken()
 	if err != nil {
-		return "", err
+		return models.Tokens{}, err
 	}
 
-	if _, err = a.credentialRepository.GetCredentialBySessionId(session.Id); err != nil {
-		return "", err
+	if err = a.refreshTokenRepository.AddRefreshToken(ctx, session.Id, token.HashOpaqueToken(refreshToken)); err != nil {
+		return models.Tokens{}, err
 	}
 
-	return userId, nil
+	return models.Tokens{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresIn: a.accessDuration}, nil
+}
+
+func (a AuthService) revokeReusedSession(ctx context.Context, sessionId string) error {
+	a.logger.InfoContext(ctx, "refresh token of session was reused, revoking the session", "session_id", sessionId)
+	if err := a.sessionRepository.RevokeSession(ctx, sessionId); err != nil {
+		return err
+	}
+	return schemas.RefreshTokenReusedError
 }
@@ -0,0 +1,204 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
+	"github.com/google/uuid"
+)
+
+const (
+	testCredentialId = "5d0fa4c2-4b8e-4c55-9a43-3d1fb5a7a1c1"
+	testUserId       = "8f6a1c59-2b1f-4f0c-8a57-6c0e6a2b9d13"
+	testSessionId    = "b2f1f0d5-6f4e-4c8a-9a65-2f7c9b0d4e21"
+)
+
+type refreshFixture struct {
+	service  AuthService
+	sessions *fakeSessions
+	refresh  *fakeRefreshTokens
+}
+
+func newRefreshFixture(t *testing.T, session models.Sessions) (refreshFixture, string) {
+	t.Helper()
+	credentials := newFakeCredentials(models.Credentials{Id: testCredentialId, UserId: testUserId, Role: models.UserRole})
+	sessions := newFakeSessions(session)
+	refresh := newFakeRefreshTokens()
+
+	refreshToken, err := token.NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = refresh.AddRefreshToken(context.Background(), session.Id, token.HashOpaqueToken(refreshToken)); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(discardLogger, credentials, sessions, refresh, nil, fakeTokenService{}, nil, password.Policy{},
+		LoginThrottle{}, AccountService{}, MFAService{}, 15*time.Minute, 24*time.Hour)
+	return refreshFixture{service: service, sessions: sessions, refresh: refresh}, refreshToken
+}
+
+func activeSession() models.Sessions {
+	return models.Sessions{
+		Id:              testSessionId,
+		CredentialId:    testCredentialId,
+		UserID:          testUserId,
+		IsAuthenticated: true,
+		ExpiresAt:       time.Now().Add(time.Hour),
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	f, refreshToken := newRefreshFixture(t, activeSession())
+
+	tokens, err := f.service.Refresh(context.Background(), refreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if tokens.RefreshToken == "" || tokens.RefreshToken == refreshToken {
+		t.Errorf("Refresh() did not issue a new refresh token")
+	}
+	if !f.refresh.rotated(refreshToken) {
+		t.Errorf("presented refresh token was not rotated")
+	}
+	if f.refresh.rotated(tokens.RefreshToken) {
+		t.Errorf("new refresh token is already rotated")
+	}
+	if session, _ := f.sessions.GetSessionById(context.Background(), testSessionId); time.Until(session.ExpiresAt) < 23*time.Hour {
+		t.Errorf("session expiry was not extended, expires at %v", session.ExpiresAt)
+	}
+
+	if _, err := f.service.Refresh(context.Background(), tokens.RefreshToken); err != nil {
+		t.Errorf("Refresh() with the rotated pair error = %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	f, refreshToken := newRefreshFixture(t, activeSession())
+
+	tokens, err := f.service.Refresh(context.Background(), refreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if _, err = f.service.Refresh(context.Background(), refreshToken); !errors.Is(err, schemas.RefreshTokenReusedError) {
+		t.Fatalf("Refresh() with a used token error = %v, want %v", err, schemas.RefreshTokenReusedError)
+	}
+	if !f.sessions.revoked(testSessionId) {
+		t.Errorf("session was not revoked after reuse")
+	}
+
+	// the token issued before the reuse belongs to the revoked session as well
+	if _, err = f.service.Refresh(context.Background(), tokens.RefreshToken); !errors.Is(err, schemas.SessionExpiredError) {
+		t.Errorf("Refresh() in revoked session error = %v, want %v", err, schemas.SessionExpiredError)
+	}
+}
+
+func TestRefreshLosingRotationRaceRevokesSession(t *testing.T) {
+	f, refreshToken := newRefreshFixture(t, activeSession())
+
+	// another request rotates the token after this one has read it
+	f.refresh.beforeRotate = func() {
+		f.refresh.beforeRotate = nil
+		if rotated, _ := f.refresh.RotateRefreshToken(context.Background(), token.HashOpaqueToken(refreshToken)); !rotated {
+			t.Errorf("concurrent rotation failed")
+		}
+	}
+
+	if _, err := f.service.Refresh(context.Background(), refreshToken); !errors.Is(err, schemas.RefreshTokenReusedError) {
+		t.Fatalf("Refresh() losing the race error = %v, want %v", err, schemas.RefreshTokenReusedError)
+	}
+	if !f.sessions.revoked(testSessionId) {
+		t.Errorf("session was not revoked after losing the race")
+	}
+	if got := f.refresh.count(); got != 1 {
+		t.Errorf("%d refresh tokens stored, the loser must not issue one", got)
+	}
+}
+
+func TestRefreshConcurrentUseIssuesOnePair(t *testing.T) {
+	f, refreshToken := newRefreshFixture(t, activeSession())
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.service.Refresh(context.Background(), refreshToken)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, schemas.RefreshTokenReusedError) && !errors.Is(err, schemas.SessionExpiredError):
+			t.Errorf("Refresh() error = %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent refreshes succeeded with one token, want 1", succeeded)
+	}
+}
+
+func TestRefreshRejectsUnknownToken(t *testing.T) {
+	f, _ := newRefreshFixture(t, activeSession())
+
+	if _, err := f.service.Refresh(context.Background(), "unknown"); !errors.Is(err, schemas.InvalidRefreshTokenError) {
+		t.Errorf("Refresh() error = %v, want %v", err, schemas.InvalidRefreshTokenError)
+	}
+}
+
+func TestRefreshRejectsExpiredSession(t *testing.T) {
+	session := activeSession()
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	f, refreshToken := newRefreshFixture(t, session)
+
+	if _, err := f.service.Refresh(context.Background(), refreshToken); !errors.Is(err, schemas.SessionExpiredError) {
+		t.Errorf("Refresh() error = %v, want %v", err, schemas.SessionExpiredError)
+	}
+	if f.refresh.rotated(refreshToken) {
+		t.Errorf("refresh token of an expired session was rotated")
+	}
+}
+
+func TestRegisterReportsConcurrentDuplicates(t *testing.T) {
+	tests := []struct {
+		addErr error
+		want   error
+	}{
+		{repostiroties.LoginExistsError, schemas.UserAlreadyExistsError},
+		{repostiroties.EmailExistsError, schemas.EmailAlreadyUsedError},
+	}
+	for _, tt := range tests {
+		credentials := newFakeCredentials()
+		credentials.addErr = tt.addErr
+		refresh := newFakeRefreshTokens()
+		service := NewService(discardLogger, credentials, newFakeSessions(), refresh, nil, fakeTokenService{}, fakeHash{},
+			password.Policy{}, LoginThrottle{}, AccountService{}, MFAService{}, 15*time.Minute, 24*time.Hour)
+
+		register := models.Register{Login: "listener", Password: testPassword, Email: "listener@example.com",
+			UserId: uuid.MustParse(testUserId)}
+		if _, err := service.Register(context.Background(), register, models.Device{}); err != tt.want {
+			t.Errorf("Register() with %v error = %v, want %v", tt.addErr, err, tt.want)
+		}
+		if refresh.count() != 0 {
+			t.Errorf("Register() with %v issued tokens", tt.addErr)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package sessions
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/repostiroties"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/google/uuid"
+)
+
+// LoginThrottle counts failed sign in attempts per login and per IP address.
+// Once the free attempts are used up every further failure locks the login
+// or the address for twice as long as the previous one.
+type LoginThrottle struct {
+	logger     *slog.Logger
+	repository repostiroties.LoginAttemptRepository
+	config     config.LockoutConfig
+}
+
+func NewLoginThrottle(logger *slog.Logger, repository repostiroties.LoginAttemptRepository,
+	config config.LockoutConfig) LoginThrottle {
+	return LoginThrottle{logger: logger, repository: repository, config: config}
+}
+
+// Check returns schemas.LockedError if either the login or the address is
+// locked at the moment.
+func (t LoginThrottle) Check(ctx context.Context, login, ip string) error {
+	var lockedUntil time.Time
+	for kind, value := range t.keys(login, ip) {
+		until, err := t.repository.GetLockedUntil(ctx, kind, value)
+		if err != nil {
+			return err
+		}
+		if until.After(lockedUntil) {
+			lockedUntil = until
+		}
+	}
+
+	if retryAfter := time.Until(lockedUntil); retryAfter > 0 {
+		return schemas.LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail registers a failed attempt and locks whatever ran out of free
+// attempts.
+func (t LoginThrottle) Fail(ctx context.Context, login, ip string) error {
+	now := time.Now()
+	for kind, value := range t.keys(login, ip) {
+		failures, err := t.repository.RegisterFailure(ctx, kind, value, now.Add(-t.config.Window))
+		if err != nil {
+			return err
+		}
+
+		delay := t.delay(kind, failures)
+		if delay <= 0 {
+			continue
+		}
+
+		lockout := models.Lockout{Id: uuid.New().String(), Kind: kind, Value: value, Failures: failures,
+			LockedUntil: now.Add(delay)}
+		if err = t.repository.Lock(ctx, lockout); err != nil {
+			return err
+		}
+		t.logger.WarnContext(ctx, "sign in locked after failed attempts", "kind", kind, "value", value, "delay", delay, "failures", failures)
+	}
+	return nil
+}
+
+// Succeed forgets the failures of the login. Failures of the address are
+// kept, otherwise signing in to one's own account would reset the counter.
+func (t LoginThrottle) Succeed(ctx context.Context, login string) error {
+	return t.repository.Reset(ctx, models.LoginAttemptKind, login)
+}
+
+func (t LoginThrottle) keys(login, ip string) map[string]string {
+	keys := map[string]string{models.LoginAttemptKind: login}
+	if ip != "" {
+		keys[models.IPAttemptKind] = ip
+	}
+	return keys
+}
+
+func (t LoginThrottle) delay(kind string, failures int) time.Duration {
+	free := t.config.LoginFreeAttempts
+	if kind == models.IPAttemptKind {
+		free = t.config.IPFreeAttempts
+	}
+	if free <= 0 || failures <= free {
+		return 0
+	}
+
+	delay := t.config.BaseDelay
+	for i := free + 1; i < failures && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if t.config.MaxDelay > 0 && delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,104 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/internal/models"
+	"github.com/Feokrat/music-dating-app/sessions/internal/sessions/schemas"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/password"
+)
+
+const testIP = "192.0.2.1"
+
+var testLockout = config.LockoutConfig{LoginFreeAttempts: 3, IPFreeAttempts: 10, BaseDelay: time.Minute,
+	MaxDelay: 4 * time.Minute, Window: time.Hour}
+
+func TestThrottleDoublesLockouts(t *testing.T) {
+	attempts := newFakeLoginAttempts()
+	throttle := NewLoginThrottle(discardLogger, attempts, testLockout)
+
+	for i := 0; i < 3; i++ {
+		if err := throttle.Fail(context.Background(), "listener", testIP); err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+	}
+	if err := throttle.Check(context.Background(), "listener", testIP); err != nil {
+		t.Fatalf("Check() within the free attempts error = %v", err)
+	}
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		if err := throttle.Fail(context.Background(), "listener", testIP); err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+		if got := attempts.lastDelay(models.LoginAttemptKind); got != want {
+			t.Errorf("login locked for %v after %d failures, want %v",
+				got, attempts.failures(models.LoginAttemptKind, "listener"), want)
+		}
+	}
+	if got := attempts.lastDelay(models.IPAttemptKind); got != 0 {
+		t.Errorf("address locked for %v within its free attempts", got)
+	}
+
+	var locked schemas.LockedError
+	if err := throttle.Check(context.Background(), "listener", "198.51.100.1"); !errors.As(err, &locked) {
+		t.Fatalf("Check() of a locked login error = %v, want %T", err, locked)
+	}
+	if locked.RetryAfter <= 3*time.Minute || locked.RetryAfter > 4*time.Minute {
+		t.Errorf("Check() retry after %v, want up to %v", locked.RetryAfter, 4*time.Minute)
+	}
+	if err := throttle.Check(context.Background(), "other", testIP); err != nil {
+		t.Errorf("Check() of another login error = %v", err)
+	}
+}
+
+func TestThrottleSucceedResetsLoginOnly(t *testing.T) {
+	attempts := newFakeLoginAttempts()
+	throttle := NewLoginThrottle(discardLogger, attempts, testLockout)
+
+	for i := 0; i < 2; i++ {
+		if err := throttle.Fail(context.Background(), "listener", testIP); err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+	}
+	if err := throttle.Succeed(context.Background(), "listener"); err != nil {
+		t.Fatalf("Succeed() error = %v", err)
+	}
+
+	if got := attempts.failures(models.LoginAttemptKind, "listener"); got != 0 {
+		t.Errorf("login has %d failures after success, want 0", got)
+	}
+	if got := attempts.failures(models.IPAttemptKind, testIP); got != 2 {
+		t.Errorf("address has %d failures after success, want 2", got)
+	}
+}
+
+func TestSignInLockedOutDespiteRightPassword(t *testing.T) {
+	credential := models.Credentials{Id: testCredentialId, UserId: testUserId, Login: "listener", Role: models.UserRole,
+		PasswordHash: "hashed:" + testPassword}
+	refresh := newFakeRefreshTokens()
+	throttle := NewLoginThrottle(discardLogger, newFakeLoginAttempts(), testLockout)
+	mfa := NewMFAService(discardLogger, nil, nil, newFakeMFA(), nil, nil, nil, nil, config.MFAConfig{})
+	service := NewService(discardLogger, newFakeCredentials(credential), newFakeSessions(), refresh, nil,
+		fakeTokenService{}, fakeHash{}, password.Policy{}, throttle, AccountService{}, mfa, 15*time.Minute, 24*time.Hour)
+	device := models.Device{UserAgent: "test", IP: testIP}
+
+	for i := 0; i < 4; i++ {
+		_, err := service.SignIn(context.Background(), models.Auth{Login: "listener", Password: "wrong"}, device)
+		if err != schemas.InvalidCredentialsError {
+			t.Fatalf("SignIn() with a wrong password error = %v, want %v", err, schemas.InvalidCredentialsError)
+		}
+	}
+
+	var locked schemas.LockedError
+	_, err := service.SignIn(context.Background(), models.Auth{Login: "listener", Password: testPassword}, device)
+	if !errors.As(err, &locked) {
+		t.Fatalf("SignIn() while locked error = %v, want %T", err, locked)
+	}
+	if refresh.count() != 0 {
+		t.Error("SignIn() issued tokens while locked")
+	}
+}
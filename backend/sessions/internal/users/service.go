@@ -0,0 +1,61 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/HTTPclient"
+)
+
+type service struct {
+	logger *slog.Logger
+	client *HTTPclient.HTTPclient
+	config config.ServicesConfig
+}
+
+type accessRequest struct {
+	HasAccess bool `json:"hasAccess"`
+}
+
+// GrantAccess sets the has_access flag of the user in the users service.
+func (s service) GrantAccess(ctx context.Context, userId string) error {
+	accessUrl := s.config.UserService + fmt.Sprintf("/api/v1/users/%s/access", userId)
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(accessRequest{HasAccess: true}); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", accessUrl, &body)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not update access of user", "user_id", userId, "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return s.client.ResponseError(resp)
+	}
+
+	return nil
+}
+
+type Service interface {
+	GrantAccess(ctx context.Context, userId string) error
+}
+
+func NewService(config config.ServicesConfig, logger *slog.Logger) Service {
+	return service{logger: logger, client: HTTPclient.NewHTTPclient("user_service", config.Client("user_service")),
+		config: config}
+}
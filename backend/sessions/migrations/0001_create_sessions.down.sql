@@ -0,0 +1,10 @@
+DROP TABLE mfa_challenges;
+DROP TABLE recovery_codes;
+DROP TABLE mfa;
+DROP TABLE credential_tokens;
+DROP TABLE lockouts;
+DROP TABLE login_attempts;
+DROP TABLE revoked_tokens;
+DROP TABLE refresh_tokens;
+DROP TABLE sessions;
+DROP TABLE credentials;
@@ -0,0 +1,146 @@
+-- databases created with the former dbScripts/session.sql are adopted: their
+-- credentials are linked to the users of their sessions and kept, the
+-- sessions themselves are dropped, so everyone has to sign in again
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'credentials' AND column_name = 'session_id') THEN
+        ALTER TABLE credentials ADD COLUMN user_id uuid;
+        UPDATE credentials SET user_id = sessions.user_id FROM sessions WHERE sessions.id = credentials.session_id;
+        DELETE FROM credentials WHERE user_id IS NULL;
+        ALTER TABLE credentials ALTER COLUMN user_id SET NOT NULL;
+        ALTER TABLE credentials DROP COLUMN session_id;
+
+        ALTER TABLE credentials DROP CONSTRAINT credentials_role_check;
+        UPDATE credentials SET role = 'primary_user' WHERE role = 'prime_user';
+        ALTER TABLE credentials ADD CONSTRAINT credentials_role_check CHECK (role in ('admin', 'user', 'primary_user'));
+
+        ALTER TABLE credentials ADD COLUMN email VARCHAR(255) NOT NULL DEFAULT '';
+        ALTER TABLE credentials ALTER COLUMN email DROP DEFAULT;
+        ALTER TABLE credentials ADD COLUMN email_verified boolean NOT NULL DEFAULT false;
+
+        DROP TABLE sessions;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS credentials (
+    id uuid PRIMARY KEY,
+    login VARCHAR(80) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    user_id uuid NOT NULL,
+    role VARCHAR(80) NOT NULL CHECK (role in ('admin', 'user', 'primary_user')),
+    email VARCHAR(255) NOT NULL,
+    email_verified boolean NOT NULL DEFAULT false
+);
+
+-- adopted credentials have no email yet
+CREATE UNIQUE INDEX credentials_email_idx ON credentials (lower(email)) WHERE email <> '';
+
+CREATE TABLE sessions (
+    id uuid PRIMARY KEY,
+    credential_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    is_authenticated boolean NOT NULL,
+    CONSTRAINT "CREDENTIAL_ID_FK" FOREIGN KEY (credential_id)
+    REFERENCES credentials (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+CREATE TABLE refresh_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    session_id uuid NOT NULL,
+    is_rotated boolean NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT "SESSION_ID_FK" FOREIGN KEY (session_id)
+    REFERENCES sessions (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE TABLE revoked_tokens (
+    jti uuid PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+CREATE TABLE login_attempts (
+    kind VARCHAR(16) NOT NULL CHECK (kind in ('login', 'ip')),
+    value VARCHAR(80) NOT NULL,
+    failures integer NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (kind, value)
+);
+
+CREATE TABLE lockouts (
+    id uuid PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    value VARCHAR(80) NOT NULL,
+    failures integer NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX lockouts_kind_value_idx ON lockouts (kind, value);
+
+CREATE TABLE credential_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    credential_id uuid NOT NULL,
+    purpose VARCHAR(32) NOT NULL CHECK (purpose in ('verify_email', 'reset_password')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT "CREDENTIAL_ID_FK" FOREIGN KEY (credential_id)
+    REFERENCES credentials (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE INDEX credential_tokens_credential_id_idx ON credential_tokens (credential_id);
+
+CREATE TABLE mfa (
+    credential_id uuid PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    confirmed boolean NOT NULL DEFAULT false,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT "CREDENTIAL_ID_FK" FOREIGN KEY (credential_id)
+    REFERENCES credentials (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+    id uuid PRIMARY KEY,
+    credential_id uuid NOT NULL,
+    code_hash VARCHAR(255) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT "CREDENTIAL_ID_FK" FOREIGN KEY (credential_id)
+    REFERENCES credentials (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
+
+CREATE INDEX recovery_codes_credential_id_idx ON recovery_codes (credential_id);
+
+CREATE TABLE mfa_challenges (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    credential_id uuid NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT "CREDENTIAL_ID_FK" FOREIGN KEY (credential_id)
+    REFERENCES credentials (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+-- published to the gateway, which verifies access tokens without asking the
+-- service and has to learn about revoked sessions until their tokens expire
+ALTER TABLE sessions ADD COLUMN revoked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX sessions_revoked_at_idx ON sessions (revoked_at) WHERE revoked_at IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE credentials DROP CONSTRAINT credentials_login_key;
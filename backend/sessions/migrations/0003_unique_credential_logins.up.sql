@@ -0,0 +1,3 @@
+-- registration checks the login first, the constraint settles concurrent
+-- registrations of the same one
+ALTER TABLE credentials ADD CONSTRAINT credentials_login_key UNIQUE (login);
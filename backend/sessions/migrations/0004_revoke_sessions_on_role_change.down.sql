@@ -0,0 +1,2 @@
+DROP TRIGGER credentials_role_change ON credentials;
+DROP FUNCTION revoke_sessions_on_role_change();
@@ -0,0 +1,16 @@
+-- roles are changed in the database, the gateway takes the role from locally
+-- verified access tokens, so the sessions of the credential are revoked and
+-- the revocation reaches the gateway before the tokens expire
+CREATE FUNCTION revoke_sessions_on_role_change() RETURNS trigger AS $$
+BEGIN
+    UPDATE sessions SET is_authenticated = false, revoked_at = now()
+    WHERE credential_id = NEW.id AND revoked_at IS NULL;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER credentials_role_change
+    AFTER UPDATE OF role ON credentials
+    FOR EACH ROW
+    WHEN (OLD.role IS DISTINCT FROM NEW.role)
+    EXECUTE FUNCTION revoke_sessions_on_role_change();
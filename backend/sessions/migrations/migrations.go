@@ -0,0 +1,8 @@
+// Package migrations embeds the schema migrations of the service, they are
+// applied by pkg/migrate in the order of their number.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,298 @@
+package HTTPclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/logging"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/problem"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Feokrat/music-dating-app/sessions/pkg/HTTPclient")
+
+// HTTPclient calls one upstream service. Every attempt is bounded by the
+// configured timeout, idempotent requests are retried with jittered
+// exponential backoff, and a circuit breaker stops calling the upstream for a
+// while after consecutive failures.
+type HTTPclient struct {
+	name    string
+	client  *http.Client
+	config  config.ClientConfig
+	breaker *breaker
+}
+
+func NewHTTPclient(name string, cfg config.ClientConfig) *HTTPclient {
+	return &HTTPclient{
+		name:    name,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		config:  cfg,
+		breaker: newBreaker(cfg.FailureThreshold, cfg.OpenTimeout),
+	}
+}
+
+// Do sends the request like http.Client.Do. Transport failures are returned
+// as *UpstreamError, responses are returned as they are, including 5xx ones
+// once retries are exhausted. The call is traced as a client span and the
+// trace context and request id are passed on to the upstream in the headers.
+func (c *HTTPclient) Do(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+c.name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+			semconv.PeerService(c.name),
+		))
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+	// every backend speaks JSON and validates bodies against its OpenAPI
+	// document, which needs the media type
+	if req.Body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := c.do(req)
+	upstreamRequestDuration.WithLabelValues(c.name, req.Method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		upstreamRequestsTotal.WithLabelValues(c.name, req.Method, "error").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	upstreamRequestsTotal.WithLabelValues(c.name, req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+func (c *HTTPclient) do(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if idempotent(req.Method) {
+		attempts += c.config.Retries
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			upstreamRetriesTotal.WithLabelValues(c.name).Inc()
+			trace.SpanFromContext(req.Context()).AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt)))
+			if err = c.wait(req.Context(), attempt); err != nil {
+				return nil, c.upstreamError(err)
+			}
+			if req, err = rewind(req); err != nil {
+				return nil, err
+			}
+		}
+
+		if !c.breaker.allow() {
+			return nil, &UpstreamError{Service: c.name, StatusCode: http.StatusServiceUnavailable, Err: ErrCircuitOpen}
+		}
+
+		resp, err = c.client.Do(req)
+		if err != nil {
+			c.failure()
+			continue
+		}
+		if !retryable(resp.StatusCode) {
+			c.success()
+			return resp, nil
+		}
+
+		c.failure()
+		if attempt < attempts-1 {
+			resp.Body.Close()
+		}
+	}
+
+	if err != nil {
+		return nil, c.upstreamError(err)
+	}
+	return resp, nil
+}
+
+func (c *HTTPclient) success() {
+	c.breaker.success()
+	upstreamCircuitOpen.WithLabelValues(c.name).Set(0)
+}
+
+func (c *HTTPclient) failure() {
+	if c.breaker.failure() {
+		upstreamCircuitOpen.WithLabelValues(c.name).Set(1)
+	}
+}
+
+// wait sleeps before a retry for a random time up to the exponential backoff
+// of the attempt ("full jitter").
+func (c *HTTPclient) wait(ctx context.Context, attempt int) error {
+	backoff := c.config.RetryBackoff << (attempt - 1)
+	if backoff <= 0 || (c.config.MaxBackoff > 0 && backoff > c.config.MaxBackoff) {
+		backoff = c.config.MaxBackoff
+	}
+	if backoff <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff)) + 1))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// StatusError reports a response of the upstream with a server error status.
+func (c *HTTPclient) StatusError(statusCode int) error {
+	return &UpstreamError{Service: c.name, StatusCode: ResponseStatus(statusCode, nil),
+		Err: fmt.Errorf("responded with status %d", statusCode)}
+}
+
+// ResponseError reads an error response of the upstream. A problem document
+// is returned as it is, so that it is passed on to the client,
+// server errors without one become *UpstreamError and other statuses a
+// problem with the upstream status.
+func (c *HTTPclient) ResponseError(resp *http.Response) error {
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), problem.ContentType) {
+		var document problem.Problem
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxProblemSize)).Decode(&document); err == nil {
+			if document.Status == 0 {
+				document.Status = resp.StatusCode
+			}
+			return &document
+		}
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return c.StatusError(resp.StatusCode)
+	}
+	return problem.New(resp.StatusCode, problemCode(resp.StatusCode),
+		fmt.Sprintf("%s responded with status %d", c.name, resp.StatusCode))
+}
+
+// maxProblemSize bounds the problem documents read from upstreams.
+const maxProblemSize = 64 << 10
+
+func problemCode(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return problem.CodeValidation
+	case http.StatusUnauthorized:
+		return problem.CodeUnauthorized
+	case http.StatusForbidden:
+		return problem.CodeForbidden
+	case http.StatusNotFound:
+		return problem.CodeNotFound
+	case http.StatusConflict:
+		return problem.CodeConflict
+	case http.StatusTooManyRequests:
+		return problem.CodeTooManyRequests
+	}
+	return problem.CodeInternal
+}
+
+func (c *HTTPclient) upstreamError(err error) error {
+	return &UpstreamError{Service: c.name, StatusCode: statusOf(err), Err: err}
+}
+
+func statusOf(err error) int {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable ||
+		statusCode == http.StatusGatewayTimeout
+}
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// UpstreamError is a call to an upstream service that got no usable
+// response, StatusCode is what the caller should respond with.
+type UpstreamError struct {
+	Service    string
+	StatusCode int
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("%s is unavailable: %s", e.Service, e.Err)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UpstreamError) Problem() *problem.Problem {
+	return problem.New(e.StatusCode, problem.CodeUpstreamUnavailable, e.Error())
+}
+
+// ResponseStatus picks the status to respond with after a failed
+// upstream call: 502, 503 or 504 for upstream failures, the upstream status
+// for client errors and 500 for anything else.
+func ResponseStatus(code int, err error) int {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode
+	}
+
+	switch {
+	case code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout:
+		return code
+	case code >= 500:
+		return http.StatusBadGateway
+	case code >= 400:
+		return code
+	}
+	return http.StatusInternalServerError
+}
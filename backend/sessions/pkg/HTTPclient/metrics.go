@@ -0,0 +1,29 @@
+package HTTPclient
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	upstreamRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_requests_total",
+		Help: "Number of calls to upstream services by response status, \"error\" when there was no response.",
+	}, []string{"service", "method", "status"})
+
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Time spent on calls to upstream services, retries included.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method"})
+
+	upstreamRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_retries_total",
+		Help: "Number of retried calls to upstream services.",
+	}, []string{"service"})
+
+	upstreamCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "upstream_circuit_open",
+		Help: "Whether the circuit breaker of an upstream service is open.",
+	}, []string{"service"})
+)
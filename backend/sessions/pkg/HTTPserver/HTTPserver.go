@@ -3,20 +3,26 @@ package HTTPserver
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/Feokrat/music-dating-app/sessions/internal/config"
+	"github.com/Feokrat/music-dating-app/sessions/pkg/health"
 )
 
 type HTTPserver struct {
-	httpServer *http.Server
+	httpServer    *http.Server
+	health        *health.Checker
+	shutdownDelay time.Duration
 }
 
-func NewHTTPserver(cfg *config.Config, handler http.Handler) *HTTPserver {
+func NewHTTPserver(cfg *config.Config, handler http.Handler, checker *health.Checker) *HTTPserver {
 	return &HTTPserver{
 		httpServer: &http.Server{
 			Addr:    cfg.HTTP.Host + ":" + cfg.HTTP.Port,
 			Handler: handler,
 		},
+		health:        checker,
+		shutdownDelay: cfg.HTTP.ShutdownDelay,
 	}
 }
 
@@ -24,6 +30,15 @@ func (s *HTTPserver) Run() error {
 	return s.httpServer.ListenAndServe()
 }
 
+// Stop fails the readiness probe first and waits for the shutdown delay, so
+// that load balancers stop routing to the instance before it stops listening.
 func (s *HTTPserver) Stop(ctx context.Context) error {
+	s.health.Shutdown()
+
+	select {
+	case <-time.After(s.shutdownDelay):
+	case <-ctx.Done():
+	}
+
 	return s.httpServer.Shutdown(ctx)
 }
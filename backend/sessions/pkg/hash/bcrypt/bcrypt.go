@@ -1,8 +1,6 @@
 package bcrypt
 
 import (
-	"log"
-
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,7 +15,7 @@ func NewBcryptHashService(hashCost int) *BcryptHashService {
 func (b BcryptHashService) HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.hashCost)
 	if err != nil {
-		log.Println(err)
+		return "", err
 	}
 	return string(hash), nil
 }
@@ -0,0 +1,45 @@
+package file
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/pkg/mail"
+)
+
+// FileMailer is meant for local development: instead of sending mail it
+// appends it to a file, or writes it to the log when no file is set.
+type FileMailer struct {
+	path   string
+	logger *slog.Logger
+	mu     sync.Mutex
+}
+
+func NewFileMailer(path string, logger *slog.Logger) *FileMailer {
+	return &FileMailer{path: path, logger: logger}
+}
+
+func (m *FileMailer) Send(message mail.Message) error {
+	text := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), message.To, message.Subject, message.Body)
+
+	if m.path == "" {
+		m.logger.Info("mail", "to", message.To, "subject", message.Subject, "body", message.Body)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(text)
+	return err
+}
@@ -0,0 +1,11 @@
+package otp
+
+type OTPService interface {
+	// GenerateSecret returns a new random shared secret.
+	GenerateSecret() (string, error)
+	// URI returns the otpauth:// URI authenticator apps enroll the secret from.
+	URI(secret string, account string) string
+	// Validate checks a code and returns the time step it belongs to, so that
+	// callers can refuse to accept the same code twice.
+	Validate(code string, secret string) (int64, bool)
+}
@@ -0,0 +1,106 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// TestGenerateRFC6238 checks the SHA-1 vectors of RFC 6238 appendix B, cut
+// to the 6 digits authenticator apps show.
+func TestGenerateRFC6238(t *testing.T) {
+	key, err := encoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, vector := range vectors {
+		if got := generate(key, vector.unix/period); got != vector.code {
+			t.Errorf("generate() at %d = %s, want %s", vector.unix, got, vector.code)
+		}
+	}
+}
+
+func TestValidateAcceptsSkew(t *testing.T) {
+	service := NewTOTPService("Music Dating")
+	secret, err := service.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := encoding.DecodeString(secret)
+	current := time.Now().Unix() / period
+
+	for step := current - skew; step <= current+skew; step++ {
+		got, ok := service.Validate(generate(key, step), secret)
+		// the step may have moved on since current was read
+		if !ok && step == current-skew {
+			continue
+		}
+		if !ok || got != step {
+			t.Errorf("Validate() of code for step %d = %d, %v, want %d, true", step, got, ok, step)
+		}
+	}
+
+	for _, step := range []int64{current - skew - 2, current + skew + 2} {
+		if _, ok := service.Validate(generate(key, step), secret); ok {
+			t.Errorf("Validate() accepted code for step %d, current step is %d", step, current)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	service := NewTOTPService("Music Dating")
+	key, _ := encoding.DecodeString(rfcSecret)
+	code := generate(key, time.Now().Unix()/period)
+
+	tests := []struct {
+		name, code, secret string
+	}{
+		{"short code", code[1:], rfcSecret},
+		{"long code", code + "0", rfcSecret},
+		{"invalid secret", code, "not base32!"},
+		{"other secret", code, encoding.EncodeToString([]byte("09876543210987654321"))},
+	}
+	for _, tt := range tests {
+		if _, ok := service.Validate(tt.code, tt.secret); ok {
+			t.Errorf("Validate() accepted %s", tt.name)
+		}
+	}
+
+	if _, ok := service.Validate(code, strings.ToLower(rfcSecret)); !ok {
+		t.Error("Validate() rejected lower case secret")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(NewTOTPService("Music Dating").URI(rfcSecret, "user@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Music Dating:user@example.com" {
+		t.Errorf("URI() = %s", uri)
+	}
+	query := uri.Query()
+	for key, want := range map[string]string{"secret": rfcSecret, "issuer": "Music Dating", "algorithm": "SHA1",
+		"digits": "6", "period": "30"} {
+		if got := query.Get(key); got != want {
+			t.Errorf("URI() %s = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package password
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Policy describes what a password chosen by a user has to look like.
+type Policy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Denylist      map[string]struct{}
+}
+
+// PolicyError lists every rule a password breaks.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e PolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, ", ")
+}
+
+// Validate checks the password of the given login against the policy.
+func (p Policy) Validate(login, password string) error {
+	var violations []string
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, "too short")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "no uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "no lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "no digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "no special character")
+	}
+
+	if _, ok := p.Denylist[strings.ToLower(password)]; ok {
+		violations = append(violations, "too common")
+	}
+	if login != "" && strings.EqualFold(login, password) {
+		violations = append(violations, "same as login")
+	}
+
+	if len(violations) > 0 {
+		return PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// LoadDenylist reads a file with one forbidden password per line. Empty lines
+// and lines starting with # are skipped.
+func LoadDenylist(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	denylist := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+
+	return denylist, scanner.Err()
+}
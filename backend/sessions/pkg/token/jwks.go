@@ -0,0 +1,21 @@
+package token
+
+// JWK is a public verification key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySet is implemented by token services whose tokens can be verified by
+// third parties with published public keys.
+type KeySet interface {
+	JWKS() JWKS
+}
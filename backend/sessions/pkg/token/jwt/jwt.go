@@ -4,7 +4,9 @@ import (
 	"errors"
 	"time"
 
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
 type JWTTokenService struct {
@@ -12,6 +14,12 @@ type JWTTokenService struct {
 	duration   time.Duration
 }
 
+type sessionClaims struct {
+	jwt.StandardClaims
+	SessionId string `json:"sid"`
+	Role      string `json:"role"`
+}
+
 func NewJWTokenService(signingKey string, duration time.Duration) *JWTTokenService {
 	return &JWTTokenService{
 		signingKey: signingKey,
@@ -19,16 +27,13 @@ func NewJWTokenService(signingKey string, duration time.Duration) *JWTTokenServi
 	}
 }
 
-func (t JWTTokenService) GenerateToken(userId string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(t.duration).Unix(),
-		Subject:   userId,
-	})
+func (t JWTTokenService) GenerateToken(userId string, sessionId string, role string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newSessionClaims(userId, sessionId, role, t.duration))
 	return token.SignedString([]byte(t.signingKey))
 }
 
-func (t JWTTokenService) ParseToken(signedToken string) (string, error) {
-	token, err := jwt.Parse(signedToken, func(token *jwt.Token) (i interface{}, err error) {
+func (t JWTTokenService) ParseToken(signedToken string) (token.Claims, error) {
+	parsed, err := jwt.ParseWithClaims(signedToken, &sessionClaims{}, func(token *jwt.Token) (i interface{}, err error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid token signing method")
 		}
@@ -36,11 +41,36 @@ func (t JWTTokenService) ParseToken(signedToken string) (string, error) {
 	})
 
 	if err != nil {
-		return "", err
+		return token.Claims{}, err
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
+	return extractClaims(parsed)
+}
+
+func newSessionClaims(userId string, sessionId string, role string, duration time.Duration) sessionClaims {
+	return sessionClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			ExpiresAt: time.Now().Add(duration).Unix(),
+			Subject:   userId,
+		},
+		SessionId: sessionId,
+		Role:      role,
+	}
+}
+
+func extractClaims(parsed *jwt.Token) (token.Claims, error) {
+	claims, ok := parsed.Claims.(*sessionClaims)
 	if !ok {
-		return "", errors.New("can't extract token claims")
+		return token.Claims{}, errors.New("can't extract token claims")
+	}
+	if claims.SessionId == "" || claims.Id == "" {
+		return token.Claims{}, errors.New("token is not bound to a session")
 	}
-	return claims["sub"].(string), nil
+	return token.Claims{
+		Id:        claims.Id,
+		UserId:    claims.Subject,
+		SessionId: claims.SessionId,
+		Role:      claims.Role,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
 }
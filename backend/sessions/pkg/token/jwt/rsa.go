@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Feokrat/music-dating-app/sessions/pkg/token"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const generatedKeyBits = 2048
+
+// RSAKey is one key of a RSATokenService, identified in tokens by its kid.
+type RSAKey struct {
+	Id         string
+	PrivateKey *rsa.PrivateKey
+}
+
+// RSATokenService signs tokens with RS256. Tokens are signed with a single
+// key, every other key is still accepted and published so tokens issued
+// before a key rotation stay valid until they expire.
+type RSATokenService struct {
+	signingKey RSAKey
+	keys       map[string]*rsa.PublicKey
+	order      []string
+	duration   time.Duration
+}
+
+func NewRSATokenService(keys []RSAKey, signingKeyId string, duration time.Duration) (*RSATokenService, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one rsa key is required")
+	}
+
+	t := &RSATokenService{keys: make(map[string]*rsa.PublicKey, len(keys)), duration: duration}
+	for _, key := range keys {
+		if key.Id == "" {
+			return nil, errors.New("rsa key id must not be empty")
+		}
+		if _, ok := t.keys[key.Id]; ok {
+			return nil, fmt.Errorf("duplicate rsa key id %s", key.Id)
+		}
+		t.keys[key.Id] = &key.PrivateKey.PublicKey
+		t.order = append(t.order, key.Id)
+		if key.Id == signingKeyId {
+			t.signingKey = key
+		}
+	}
+
+	if signingKeyId == "" {
+		t.signingKey = keys[0]
+	}
+	if t.signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("signing key %s is not among the configured keys", signingKeyId)
+	}
+
+	return t, nil
+}
+
+// GenerateRSAKey creates a key that lives only as long as the process, it is
+// meant for local development when no key files are configured.
+func GenerateRSAKey(id string) (RSAKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, generatedKeyBits)
+	if err != nil {
+		return RSAKey{}, err
+	}
+	return RSAKey{Id: id, PrivateKey: privateKey}, nil
+}
+
+// ParseRSAKey reads a PEM encoded PKCS#1 or PKCS#8 private key.
+func ParseRSAKey(id string, pem []byte) (RSAKey, error) {
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		return RSAKey{}, err
+	}
+	return RSAKey{Id: id, PrivateKey: privateKey}, nil
+}
+
+func (t RSATokenService) GenerateToken(userId string, sessionId string, role string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, newSessionClaims(userId, sessionId, role, t.duration))
+	token.Header["kid"] = t.signingKey.Id
+	return token.SignedString(t.signingKey.PrivateKey)
+}
+
+func (t RSATokenService) ParseToken(signedToken string) (token.Claims, error) {
+	parsed, err := jwt.ParseWithClaims(signedToken, &sessionClaims{}, func(token *jwt.Token) (i interface{}, err error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errors.New("invalid token signing method")
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	})
+
+	if err != nil {
+		return token.Claims{}, err
+	}
+	return extractClaims(parsed)
+}
+
+func (t RSATokenService) JWKS() token.JWKS {
+	jwks := token.JWKS{Keys: make([]token.JWK, 0, len(t.order))}
+	for _, kid := range t.order {
+		key := t.keys[kid]
+		jwks.Keys = append(jwks.Keys, token.JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	return jwks
+}
@@ -0,0 +1,26 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const opaqueTokenSize = 32
+
+// NewOpaqueToken returns a random URL-safe token that carries no claims and
+// can only be checked by looking up its hash.
+func NewOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashOpaqueToken returns the value stored in the database for an opaque token.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
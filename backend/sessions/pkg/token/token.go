@@ -1,6 +1,17 @@
 package token
 
+import "time"
+
+// Claims are the values carried by an access token.
+type Claims struct {
+	Id        string
+	UserId    string
+	SessionId string
+	Role      string
+	ExpiresAt time.Time
+}
+
 type TokenService interface {
-	GenerateToken(userId string) (string, error)
-	ParseToken(signedToken string) (string, error)
+	GenerateToken(userId string, sessionId string, role string) (string, error)
+	ParseToken(signedToken string) (Claims, error)
 }
@@ -0,0 +1,8 @@
+// Package api embeds the OpenAPI document of the service, it is served at
+// /openapi.json and requests are validated against it.
+package api
+
+import _ "embed"
+
+//go:embed openapi.yaml
+var Spec []byte
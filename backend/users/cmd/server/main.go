@@ -2,44 +2,126 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/Feokrat/music-dating-app/users/api"
 	"github.com/Feokrat/music-dating-app/users/internal/config"
+	"github.com/Feokrat/music-dating-app/users/internal/match"
+	"github.com/Feokrat/music-dating-app/users/internal/middleware"
 	"github.com/Feokrat/music-dating-app/users/internal/music"
+	"github.com/Feokrat/music-dating-app/users/internal/payments"
 	"github.com/Feokrat/music-dating-app/users/internal/user"
+	"github.com/Feokrat/music-dating-app/users/migrations"
 	"github.com/Feokrat/music-dating-app/users/pkg/HTTPserver"
 	"github.com/Feokrat/music-dating-app/users/pkg/database"
+	"github.com/Feokrat/music-dating-app/users/pkg/health"
+	"github.com/Feokrat/music-dating-app/users/pkg/logging"
+	"github.com/Feokrat/music-dating-app/users/pkg/migrate"
+	"github.com/Feokrat/music-dating-app/users/pkg/openapi"
+	"github.com/Feokrat/music-dating-app/users/pkg/problem"
+	"github.com/Feokrat/music-dating-app/users/pkg/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var configFile = "configs/config"
+var configFile = flag.String("config", "configs/config.yml",
+	"path to the config file, its settings can be overridden by environment variables")
+
+const serviceName = "users"
 
 func main() {
-	logger := log.New(os.Stdout, "logger: ", log.Lshortfile)
+	flag.Parse()
+	args := flag.Args()
+
+	// used until the configured logger can be built
+	bootLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	cfg, err := config.Init(*configFile, bootLogger)
+	if err != nil {
+		bootLogger.Error("failed to load application configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "config" {
+		if err := runConfig(args[1:]); err != nil {
+			bootLogger.Error("failed to run config command", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		bootLogger.Error("invalid application configuration", "error", err)
+		os.Exit(1)
+	}
+
+	logger, err := logging.NewLogger(cfg.Log, os.Stdout)
+	if err != nil {
+		bootLogger.Error("failed to set up logging", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
 
-	cfg, err := config.Init(configFile, logger)
+	shutdownTracing, err := tracing.NewTracerProvider(cfg.Tracing, serviceName)
 	if err != nil {
-		logger.Fatalf("failed to load application configuration: %s", err)
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
 	}
 
 	db, err := database.NewPostgresDB(cfg.Postgresql, logger)
 	if err != nil {
-		logger.Fatal(err)
+		logger.Error("failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 	defer database.ClosePostgresDB(db)
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, cfg.Postgresql.DBName))
 
-	handlers := buildHandler(db, logger)
-	server := HTTPserver.NewHTTPserver(cfg, handlers)
+	migrator, err := migrate.NewMigrator(db, migrations.FS, logger)
+	if err != nil {
+		logger.Error("failed to load migrations", "error", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(context.Background(), migrator, args[1:], logger); err != nil {
+			logger.Error("failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.Postgresql.AutoMigrate {
+		if err := migrator.Up(context.Background()); err != nil {
+			logger.Error("failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	checker := health.NewChecker(cfg.HTTP.ReadinessTimeout)
+	checker.Add("postgres", db.PingContext)
+
+	handlers, err := buildHandler(cfg, db, logger, checker)
+	if err != nil {
+		logger.Error("failed to build http handler", "error", err)
+		os.Exit(1)
+	}
+	server := HTTPserver.NewHTTPserver(cfg, handlers, checker)
 
 	go func() {
-		if err := server.Run(); err != nil {
-			logger.Printf("error occurred while running http server: %s\n", err.Error())
+		if err := server.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("error occurred while running http server", "error", err)
 		}
 	}()
 
@@ -48,36 +130,105 @@ func main() {
 	signal.Notify(c, syscall.SIGTERM)
 
 	sig := <-c
-	logger.Println("Got signal:", sig)
-	logger.Print("Shutting down server")
+	logger.Info("got signal", "signal", sig.String())
+	logger.Info("shutting down server")
 
 	ctx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdown()
 
 	server.Stop(ctx)
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
 }
 
-func buildHandler(db *sqlx.DB, logger *log.Logger) http.Handler {
-	router := gin.Default()
+func buildHandler(cfg *config.Config, db *sqlx.DB, logger *slog.Logger, checker *health.Checker) (http.Handler, error) {
+	doc, err := openapi.Load(api.Spec)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := openapi.Validator(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	router := gin.New()
 
 	router.Use(
-		gin.Recovery(),
-		gin.Logger(),
+		problem.Recovery(),
+		middleware.RequestID(),
+		middleware.Logger(logger),
+		middleware.Tracing(serviceName),
+		middleware.Metrics(),
+		middleware.Timeout(cfg.HTTP.RequestTimeout),
+		validator,
 	)
 
+	router.NoRoute(problem.NoRoute)
+
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
-	rg := router.Group("/api/v1")
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	health.RegisterHandlers(router, checker)
+	openapi.RegisterHandler(router, doc)
 
-	userRepository := user.NewRepository(db, logger)
-	userService := user.NewService(userRepository, logger)
-	user.RegisterHandlers(rg.Group("/users"), userService, logger)
+	rg := router.Group("/api/v1")
 
 	musicRepository := music.NewRepository(db, logger)
 	musicService := music.NewService(musicRepository, logger)
 	music.RegisterHandlers(rg.Group("/musics"), musicService, logger)
 
-	return router
+	userRepository := user.NewRepository(db, logger)
+	paymentsService := payments.NewService(cfg.Services, logger)
+	userService := user.NewService(userRepository, musicService, paymentsService, cfg.Swipes, logger)
+	user.RegisterHandlers(rg.Group("/users"), userService, logger)
+
+	matchRepository := match.NewRepository(db, logger)
+	matchService := match.NewService(matchRepository, logger)
+	match.RegisterHandlers(rg.Group("/matches"), matchService, logger)
+
+	return router, nil
+}
+
+// runConfig handles "config print", which shows the effective config with
+// secrets redacted.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("unknown config command, expected print")
+	}
+
+	return config.Print(os.Stdout)
+}
+
+// runMigrate handles "migrate up", "migrate down [steps]" and "migrate version".
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string, logger *slog.Logger) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("schema version", "version", version)
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q, expected up, down or version", command)
 }
@@ -1,67 +1,129 @@
 package config
 
 import (
-	"log"
-	"strings"
+	"log/slog"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type (
 	Config struct {
-		HTTP       HTTPConfig
-		Postgresql PGConfig
+		HTTP       HTTPConfig     `mapstructure:"http"`
+		Postgresql PGConfig       `mapstructure:"postgres"`
+		Swipes     SwipesConfig   `mapstructure:"swipes"`
+		Services   ServicesConfig `mapstructure:"services"`
+		Tracing    TracingConfig  `mapstructure:"tracing"`
+		Log        LogConfig      `mapstructure:"log"`
 	}
 
 	HTTPConfig struct {
-		Host string `mapstructure:"host"`
-		Port string `mapstructure:"port"`
+		Host             string        `mapstructure:"host"`
+		Port             string        `mapstructure:"port"`
+		RequestTimeout   time.Duration `mapstructure:"request_timeout"`
+		ReadinessTimeout time.Duration `mapstructure:"readiness_timeout"`
+		ShutdownDelay    time.Duration `mapstructure:"shutdown_delay"`
+	}
+
+	LogConfig struct {
+		Level  string `mapstructure:"level"`
+		Format string `mapstructure:"format"`
+	}
+
+	TracingConfig struct {
+		Exporter    string  `mapstructure:"exporter"`
+		File        string  `mapstructure:"file"`
+		Endpoint    string  `mapstructure:"endpoint"`
+		Insecure    bool    `mapstructure:"insecure"`
+		SampleRatio float64 `mapstructure:"sample_ratio"`
 	}
 
 	PGConfig struct {
-		Host     string `mapstructure:"host"`
-		Port     string `mapstructure:"port"`
-		Username string `mapstructure:"username"`
-		Password string `mapstructure:"password"`
-		DBName   string `mapstructure:"dbname"`
-		SSLMode  string `mapstructure:"sslmode"`
+		Host        string `mapstructure:"host"`
+		Port        string `mapstructure:"port"`
+		Username    string `mapstructure:"username"`
+		Password    string `mapstructure:"password"`
+		DBName      string `mapstructure:"dbname"`
+		SSLMode     string `mapstructure:"sslmode"`
+		AutoMigrate bool   `mapstructure:"auto_migrate"`
 	}
-)
 
-func Init(path string, logger *log.Logger) (*Config, error) {
-	if err := parseConfigFile(path); err != nil {
-		logger.Printf("failed to parse path to config file: %s", err)
-		return nil, err
+	SwipesConfig struct {
+		PassCooldown time.Duration `mapstructure:"pass_cooldown"`
 	}
 
-	var cfg Config
-	if err := unmarshal(&cfg, logger); err != nil {
-		logger.Printf("failed to unmarshal config: %s", err)
-		return nil, err
+	ServicesConfig struct {
+		PaymentService string                  `mapstructure:"payment_service"`
+		Clients        map[string]ClientConfig `mapstructure:"clients"`
 	}
 
-	return &cfg, nil
+	// ClientConfig tunes calls to one upstream service, unset values are
+	// taken from the "default" entry of ServicesConfig.Clients.
+	ClientConfig struct {
+		Timeout          time.Duration `mapstructure:"timeout"`
+		Retries          int           `mapstructure:"retries"`
+		RetryBackoff     time.Duration `mapstructure:"retry_backoff"`
+		MaxBackoff       time.Duration `mapstructure:"max_backoff"`
+		FailureThreshold int           `mapstructure:"failure_threshold"`
+		OpenTimeout      time.Duration `mapstructure:"open_timeout"`
+	}
+)
+
+// Client returns the client settings of the named upstream service.
+func (c ServicesConfig) Client(name string) ClientConfig {
+	cfg := c.Clients[name]
+	defaults := c.Clients["default"]
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = defaults.Retries
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = defaults.RetryBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaults.MaxBackoff
+	}
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = defaults.FailureThreshold
+	}
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = defaults.OpenTimeout
+	}
+
+	return cfg
 }
 
-func unmarshal(cfg *Config, logger *log.Logger) error {
-	if err := viper.UnmarshalKey("http", &cfg.HTTP); err != nil {
-		logger.Printf("failed to unmarshal http key in config: %s", err)
-		return err
+// envPrefix prefixes the environment variables overriding the settings.
+const envPrefix = "USERS"
+
+// secrets are the keys redacted by Print.
+var secrets = []string{"postgres.password"}
+
+func Init(path string, logger *slog.Logger) (*Config, error) {
+	if err := parseConfigFile(path); err != nil {
+		logger.Error("failed to read config file", "path", path, "error", err)
+		return nil, err
+	}
+
+	if err := bindEnv(); err != nil {
+		logger.Error("failed to read config from environment", "error", err)
+		return nil, err
 	}
 
-	if err := viper.UnmarshalKey("postgres", &cfg.Postgresql); err != nil {
-		logger.Printf("failed to unmarshal postgres key in config: %s", err)
-		return err
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		logger.Error("failed to unmarshal config", "error", err)
+		return nil, err
 	}
 
-	return nil
+	return &cfg, nil
 }
 
-func parseConfigFile(filepath string) error {
-	path := strings.Split(filepath, "/")
-
-	viper.AddConfigPath(path[0])
-	viper.SetConfigName(path[1])
+func parseConfigFile(path string) error {
+	viper.SetConfigFile(path)
 
 	return viper.ReadInConfig()
 }
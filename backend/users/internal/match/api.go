@@ -0,0 +1,170 @@
+package match
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Feokrat/music-dating-app/users/internal/schemas"
+	"github.com/Feokrat/music-dating-app/users/pkg/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type handler struct {
+	service Service
+	logger  *slog.Logger
+}
+
+func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *slog.Logger) {
+	h := handler{service, logger}
+
+	rg.GET("/:id", h.getMatches)
+	rg.GET("/:id/:match_id", h.getMatch)
+	rg.DELETE("/:id/:match_id", h.unmatch)
+	rg.POST("/:id/blocks", h.block)
+}
+
+func (h handler) getMatches(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	pageStr := ctx.Query("page")
+	if pageStr == "" {
+		pageStr = "1"
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
+
+		return
+	}
+
+	sizeStr := ctx.Query("size")
+	if sizeStr == "" {
+		sizeStr = "100"
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
+
+		return
+	}
+
+	matches, err := h.service.GetMatches(ctx.Request.Context(), userId, page, size)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get matches of user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, matches)
+}
+
+func (h handler) getMatch(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	matchIdStr := ctx.Param("match_id")
+	matchId, err := uuid.Parse(matchIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse match id", "match_id", matchIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("match_id", err))
+
+		return
+	}
+
+	match, err := h.service.GetMatch(ctx.Request.Context(), userId, matchId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get match", "user_id", userId, "match_id", matchId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, match)
+}
+
+func (h handler) unmatch(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	matchIdStr := ctx.Param("match_id")
+	matchId, err := uuid.Parse(matchIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse match id", "match_id", matchIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("match_id", err))
+
+		return
+	}
+
+	match, err := h.service.Unmatch(ctx.Request.Context(), userId, matchId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not unmatch", "user_id", userId, "match_id", matchId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, match)
+}
+
+func (h handler) block(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	var requestModel schemas.BlockRequest
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+	if requestModel.UserId == userId {
+		problem.Respond(ctx, problem.InvalidParam("userId", errors.New("users can not block themselves")))
+		return
+	}
+	if requestModel.Report && strings.TrimSpace(requestModel.Reason) == "" {
+		problem.Respond(ctx, problem.InvalidParam("reason", errors.New("a report needs a reason")))
+		return
+	}
+
+	block, err := h.service.Block(ctx.Request.Context(), userId, requestModel)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not block user", "user_id", userId, "blocked_id", requestModel.UserId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, block)
+}
@@ -0,0 +1,23 @@
+package match
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	unmatchesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_unmatches_total",
+		Help: "Number of matches users ended.",
+	})
+
+	blocksTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_blocks_total",
+		Help: "Number of users blocked, reported ones included.",
+	})
+
+	reportsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_reports_total",
+		Help: "Number of users reported to the moderators.",
+	})
+)
@@ -0,0 +1,165 @@
+package match
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Feokrat/music-dating-app/users/internal/models"
+	"github.com/Feokrat/music-dating-app/users/internal/schemas"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type repository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+type Repository interface {
+	GetAll(ctx context.Context, userId uuid.UUID, page, size int) ([]models.MatchPreview, error)
+	GetById(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (models.Match, error)
+	Unmatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (models.Match, error)
+	Block(ctx context.Context, block models.Block) (models.Block, error)
+}
+
+const (
+	userTable     = "users"
+	imageTable    = "images"
+	likesTable    = "user_likes"
+	dislikesTable = "user_dislikes"
+	matchesTable  = "matches"
+	blocksTable   = "user_blocks"
+)
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) Repository {
+	return repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetAll lists the matches of the user, the latest first, with a preview of
+// the other user of each.
+func (r repository) GetAll(ctx context.Context, userId uuid.UUID, page, size int) ([]models.MatchPreview, error) {
+	var matches []models.MatchPreview
+	query := fmt.Sprintf(`SELECT m.*, u.id AS user_id, u.name, u.surname, COALESCE(i.image, '') AS image
+		FROM %[1]s m
+			JOIN %[2]s u ON u.id = CASE WHEN m.user_id1 = $1 THEN m.user_id2 ELSE m.user_id1 END
+			LEFT JOIN LATERAL (SELECT image FROM %[3]s WHERE user_id = u.id LIMIT 1) i ON true
+		WHERE m.user_id1 = $1 OR m.user_id2 = $1
+		ORDER BY m.created_at DESC, m.id
+		LIMIT $2 OFFSET $3`, matchesTable, userTable, imageTable)
+
+	err := r.db.SelectContext(ctx, &matches, query, userId, size, (page-1)*size)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get matches of user", "user_id", userId, "error", err)
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// GetById returns the match when the user is one of its users.
+func (r repository) GetById(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (models.Match, error) {
+	var match models.Match
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 AND (user_id1 = $2 OR user_id2 = $2)`, matchesTable)
+	err := r.db.GetContext(ctx, &match, query, matchId, userId)
+	if err == sql.ErrNoRows {
+		return models.Match{}, schemas.NotFoundError{Message: fmt.Sprintf("Not found any match with id %v", matchId)}
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get match", "match_id", matchId, "error", err)
+		return models.Match{}, err
+	}
+
+	return match, nil
+}
+
+// Unmatch deletes the match along with the like of the user, who dislikes
+// the other user instead, so that the pair is not recommended to each other
+// again.
+func (r repository) Unmatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (models.Match, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Match{}, err
+	}
+	defer tx.Rollback()
+
+	var match models.Match
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND (user_id1 = $2 OR user_id2 = $2) RETURNING *`, matchesTable)
+	err = tx.GetContext(ctx, &match, query, matchId, userId)
+	if err == sql.ErrNoRows {
+		return models.Match{}, schemas.NotFoundError{Message: fmt.Sprintf("Not found any match with id %v", matchId)}
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to delete match", "match_id", matchId, "error", err)
+		return models.Match{}, err
+	}
+
+	other := match.Other(userId)
+	query = fmt.Sprintf(`DELETE FROM %s WHERE who = $1 AND from_who = $2`, likesTable)
+	if _, err := tx.ExecContext(ctx, query, other, userId); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to delete like of user", "user_id", userId, "error", err)
+		return models.Match{}, err
+	}
+
+	query = fmt.Sprintf("INSERT INTO %s (id, who, from_who) values ($1, $2, $3)"+
+		" ON CONFLICT (who, from_who) DO UPDATE SET expires_at = NULL, created_at = now()", dislikesTable)
+	if _, err := tx.ExecContext(ctx, query, uuid.New(), other, userId); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to create user dislike", "user_id", userId, "error", err)
+		return models.Match{}, err
+	}
+
+	return match, tx.Commit()
+}
+
+// Block blocks the user and ends their match. Blocking a user again only
+// adds the report, if any, a user that does not exist is not found.
+func (r repository) Block(ctx context.Context, block models.Block) (models.Block, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Block{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkUser(ctx, tx, block.Who); err != nil {
+		return models.Block{}, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %[1]s (id, who, from_who, reason, reported)
+		values ($1, $2, $3, $4, $5)
+		ON CONFLICT (who, from_who) DO UPDATE SET
+			reason = CASE WHEN EXCLUDED.reason = '' THEN %[1]s.reason ELSE EXCLUDED.reason END,
+			reported = %[1]s.reported OR EXCLUDED.reported
+		RETURNING *`, blocksTable)
+	err = tx.GetContext(ctx, &block, query, block.Id, block.Who, block.FromWho, block.Reason, block.Reported)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to create user block", "user_id", block.FromWho, "error", err)
+		return models.Block{}, err
+	}
+
+	query = fmt.Sprintf(`DELETE FROM %s
+		WHERE user_id1 = LEAST($1::uuid, $2::uuid) AND user_id2 = GREATEST($1::uuid, $2::uuid)`, matchesTable)
+	if _, err := tx.ExecContext(ctx, query, block.Who, block.FromWho); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to delete match", "user_id", block.FromWho, "error", err)
+		return models.Block{}, err
+	}
+
+	return block, tx.Commit()
+}
+
+// checkUser fails with NotFoundError when the user does not exist.
+func checkUser(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error {
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1)`, userTable)
+	if err := tx.GetContext(ctx, &exists, query, id); err != nil {
+		return err
+	}
+	if !exists {
+		return schemas.NotFoundError{Message: fmt.Sprintf("Not found any user with id %v", id)}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package match
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Feokrat/music-dating-app/users/internal/models"
+	"github.com/Feokrat/music-dating-app/users/internal/schemas"
+	"github.com/google/uuid"
+)
+
+type service struct {
+	matchRepository Repository
+	logger          *slog.Logger
+}
+
+type Service interface {
+	GetMatches(ctx context.Context, userId uuid.UUID, page, size int) (schemas.MatchesResponse, error)
+	GetMatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, error)
+	Unmatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, error)
+	Block(ctx context.Context, userId uuid.UUID, request schemas.BlockRequest) (schemas.BlockResponse, error)
+}
+
+func NewService(repo Repository, logger *slog.Logger) Service {
+	return service{repo, logger}
+}
+
+func (s service) GetMatches(ctx context.Context, userId uuid.UUID, page, size int) (schemas.MatchesResponse, error) {
+	matches, err := s.matchRepository.GetAll(ctx, userId, page, size)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error occurred during getting matches of user", "user_id", userId)
+		return schemas.MatchesResponse{}, err
+	}
+
+	response := schemas.MatchesResponse{Matches: make([]schemas.MatchResponse, 0, len(matches))}
+	for _, match := range matches {
+		response.Matches = append(response.Matches, schemas.MatchResponse{
+			Id:        match.Id,
+			CreatedAt: match.CreatedAt,
+			User: schemas.MatchUserResponse{
+				Id:      match.UserId,
+				Name:    match.Name,
+				Surname: match.Surname,
+				Image:   match.Image,
+			},
+		})
+	}
+
+	return response, nil
+}
+
+func (s service) GetMatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, error) {
+	match, err := s.matchRepository.GetById(ctx, userId, matchId)
+	if err != nil {
+		return schemas.MatchResponse{}, err
+	}
+
+	return schemas.MatchResponse{
+		Id:        match.Id,
+		CreatedAt: match.CreatedAt,
+		User:      schemas.MatchUserResponse{Id: match.Other(userId)},
+	}, nil
+}
+
+func (s service) Unmatch(ctx context.Context, userId uuid.UUID, matchId uuid.UUID) (schemas.MatchResponse, error) {
+	match, err := s.matchRepository.Unmatch(ctx, userId, matchId)
+	if err != nil {
+		return schemas.MatchResponse{}, err
+	}
+
+	unmatchesTotal.Inc()
+	return schemas.MatchResponse{
+		Id:        match.Id,
+		CreatedAt: match.CreatedAt,
+		User:      schemas.MatchUserResponse{Id: match.Other(userId)},
+	}, nil
+}
+
+func (s service) Block(ctx context.Context, userId uuid.UUID, request schemas.BlockRequest) (schemas.BlockResponse, error) {
+	block, err := s.matchRepository.Block(ctx, models.Block{
+		Id:       uuid.New(),
+		Who:      request.UserId,
+		FromWho:  userId,
+		Reason:   request.Reason,
+		Reported: request.Report,
+	})
+	if err != nil {
+		return schemas.BlockResponse{}, err
+	}
+
+	blocksTotal.Inc()
+	if request.Report {
+		reportsTotal.Inc()
+	}
+	return schemas.BlockResponse{
+		Id:        block.Id,
+		UserId:    block.Who,
+		Reason:    block.Reason,
+		Reported:  block.Reported,
+		CreatedAt: block.CreatedAt,
+	}, nil
+}
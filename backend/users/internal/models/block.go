@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Block hides Who and FromWho from each other for good. A reported block
+// is also a complaint about Who for the moderators.
+type Block struct {
+	Id        uuid.UUID `json:"id" db:"id"`
+	Who       uuid.UUID `json:"who" db:"who"`
+	FromWho   uuid.UUID `json:"fromWho" db:"from_who"`
+	Reason    string    `json:"reason" db:"reason"`
+	Reported  bool      `json:"reported" db:"reported"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Match is a pair of users who liked each other, UserId1 is the lesser id.
+type Match struct {
+	Id        uuid.UUID `json:"id" db:"id"`
+	UserId1   uuid.UUID `json:"userId1" db:"user_id1"`
+	UserId2   uuid.UUID `json:"userId2" db:"user_id2"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// MatchPreview is a match of the user along with a preview of the profile
+// of the other user.
+type MatchPreview struct {
+	Match
+	UserId  uuid.UUID `db:"user_id"`
+	Name    string    `db:"name"`
+	Surname string    `db:"surname"`
+	Image   string    `db:"image"`
+}
+
+// Other is the user of the match other than id.
+func (m Match) Other(id uuid.UUID) uuid.UUID {
+	if m.UserId1 == id {
+		return m.UserId2
+	}
+	return m.UserId1
+}
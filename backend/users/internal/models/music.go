@@ -6,5 +6,6 @@ type Music struct {
 	Id     uuid.UUID `json:"id" db:"id"`
 	Name   string    `json:"name" db:"name"`
 	Author string    `json:"author" db:"author"`
+	Genre  string    `json:"genre" db:"genre"`
 	Url    string    `json:"url" db:"url"`
 }
@@ -0,0 +1,30 @@
+package models
+
+// Taste sums up the music of a user, it bounds the overlap any other user
+// can have with it.
+type Taste struct {
+	Tracks  int `db:"tracks"`
+	Authors int `db:"authors"`
+	Genres  int `db:"genres"`
+}
+
+// TasteMatch is a candidate with the overlap of its music with the music
+// of the user recommendations are made for. Shared tracks count with the
+// lower favourite level of the two users, authors and genres once each.
+type TasteMatch struct {
+	User
+	SharedTracks  int    `db:"shared_tracks"`
+	SharedAuthors int    `db:"shared_authors"`
+	SharedGenres  int    `db:"shared_genres"`
+	TopTrack      string `db:"top_track"`
+	TopAuthor     string `db:"top_author"`
+	TopGenre      string `db:"top_genre"`
+}
+
+// Recommendation is a candidate with its compatibility score in percent
+// and why it was recommended.
+type Recommendation struct {
+	User        User
+	Score       int
+	Explanation string
+}
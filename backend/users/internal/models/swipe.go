@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LikeSwipe    = "like"
+	DislikeSwipe = "dislike"
+	// PassSwipe hides the user until the pass expires
+	PassSwipe = "pass"
+)
+
+// Swipe is a like, dislike or pass FromWho gave Who.
+type Swipe struct {
+	Id        uuid.UUID  `json:"id" db:"id"`
+	Kind      string     `json:"kind" db:"kind"`
+	Who       uuid.UUID  `json:"who" db:"who"`
+	FromWho   uuid.UUID  `json:"fromWho" db:"from_who"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	ExpiresAt *time.Time `json:"expiresAt" db:"expires_at"`
+}
@@ -1,9 +1,16 @@
 package models
 
+import "time"
+
 type UpdateUserInfo struct {
-	Name        *string `json:"name" db:"name"`
-	Surname     *string `json:"surname" db:"surname"`
-	Email       *string `json:"email" db:"email"`
-	PhoneNumber *string `json:"phoneNumber" db:"phone_number"`
-	HasAccess   *bool   `json:"hasAccess" db:"has_access"`
+	Name        *string    `json:"name" db:"name"`
+	Surname     *string    `json:"surname" db:"surname"`
+	Email       *string    `json:"email" db:"email"`
+	PhoneNumber *string    `json:"phoneNumber" db:"phone_number"`
+	HasAccess   *bool      `json:"hasAccess" db:"has_access"`
+	Description *string    `json:"description" db:"description"`
+	BirthDate   *time.Time `json:"birthDate" db:"birth_date"`
+	Gender      *string    `json:"gender" db:"gender"`
+	Interests   []string   `json:"interests" db:"interests"`
+	City        *string    `json:"city" db:"city"`
 }
@@ -1,12 +1,29 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Genders a user can tell in the profile, empty when not told.
+const (
+	FemaleGender = "female"
+	MaleGender   = "male"
+	OtherGender  = "other"
+)
 
 type User struct {
-	Id          uuid.UUID `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Surname     string    `json:"surname" db:"surname"`
-	Email       string    `json:"email" db:"email"`
-	PhoneNumber string    `json:"phoneNumber" db:"phone_number"`
-	HasAccess   bool      `json:"hasAccess" db:"has_access"`
+	Id          uuid.UUID      `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Surname     string         `json:"surname" db:"surname"`
+	Email       string         `json:"email" db:"email"`
+	PhoneNumber string         `json:"phoneNumber" db:"phone_number"`
+	HasAccess   bool           `json:"hasAccess" db:"has_access"`
+	Description string         `json:"description" db:"description"`
+	BirthDate   *time.Time     `json:"birthDate" db:"birth_date"`
+	Gender      string         `json:"gender" db:"gender"`
+	Interests   pq.StringArray `json:"interests" db:"interests"`
+	City        string         `json:"city" db:"city"`
 }
@@ -1,9 +1,14 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UserLikes struct {
-	Id      uuid.UUID `json:"id" db:"id"`
-	Who     uuid.UUID `json:"who" db:"who"`
-	FromWho uuid.UUID `json:"fromWho" db:"from_who"`
+	Id        uuid.UUID `json:"id" db:"id"`
+	Who       uuid.UUID `json:"who" db:"who"`
+	FromWho   uuid.UUID `json:"fromWho" db:"from_who"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
 }
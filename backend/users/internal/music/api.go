@@ -2,22 +2,23 @@ package music
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/Feokrat/music-dating-app/users/internal/models"
 	"github.com/Feokrat/music-dating-app/users/internal/schemas"
+	"github.com/Feokrat/music-dating-app/users/pkg/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type handler struct {
 	service Service
-	logger  *log.Logger
+	logger  *slog.Logger
 }
 
-func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *log.Logger) {
+func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *slog.Logger) {
 	h := handler{service, logger}
 
 	rg.POST("/", h.addMusic)
@@ -28,29 +29,23 @@ func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *log.Logger)
 
 func (h handler) addMusic(ctx *gin.Context) {
 	var requestModel schemas.MusicRequest
-	if err := ctx.BindJSON(&requestModel); err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong request model",
-			Errors:  err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	id, err := h.service.AddMusic(models.Music{
+	id, err := h.service.AddMusic(ctx.Request.Context(), models.Music{
 		Id:     uuid.New(),
 		Name:   requestModel.Name,
 		Author: requestModel.Author,
+		Genre:  requestModel.Genre,
 		Url:    requestModel.Url,
 	})
 
 	if err != nil {
-		h.logger.Printf("could not add music %v, error: %s",
-			requestModel, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not add music", "request_model", requestModel, "error", err)
+		problem.Respond(ctx, err)
 		return
 	}
 
@@ -66,10 +61,8 @@ func (h handler) getAllMusic(ctx *gin.Context) {
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil {
-		h.logger.Printf("could not convert page param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "page param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
 
 		return
 	}
@@ -80,20 +73,16 @@ func (h handler) getAllMusic(ctx *gin.Context) {
 	}
 	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
-		h.logger.Printf("could not convert size param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "size param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
 
 		return
 	}
 
-	musics, err := h.service.GetAllMusics(page, size)
+	musics, err := h.service.GetAllMusics(ctx.Request.Context(), page, size)
 	if err != nil {
-		h.logger.Printf("error while handling get all musics, error: %s", err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: fmt.Sprintf("internal error: %s", err.Error()),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "error while handling get all musics", "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -105,23 +94,16 @@ func (h handler) getMusicById(ctx *gin.Context) {
 	musicIdStr := ctx.Param("id")
 	musicId, err := uuid.Parse(musicIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse music id %v, error: %s",
-			musicIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong music id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse music id", "music_id", musicIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	music, err := h.service.GetMusicById(musicId)
+	music, err := h.service.GetMusicById(ctx.Request.Context(), musicId)
 	if err != nil {
-		h.logger.Printf("could not get music %v, error: %s",
-			musicId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get music", "music_id", musicId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -133,23 +115,16 @@ func (h handler) deleteMusicById(ctx *gin.Context) {
 	musicIdStr := ctx.Param("id")
 	musicId, err := uuid.Parse(musicIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse music id %v, error: %s",
-			musicIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong music id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse music id", "music_id", musicIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	err = h.service.DeleteMusicById(musicId)
+	err = h.service.DeleteMusicById(ctx.Request.Context(), musicId)
 	if err != nil {
-		h.logger.Printf("could not delete music %v, error: %s",
-			musicId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not delete music", "music_id", musicId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
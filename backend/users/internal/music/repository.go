@@ -1,9 +1,10 @@
 package music
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Feokrat/music-dating-app/users/internal/models"
 	"github.com/Feokrat/music-dating-app/users/internal/schemas"
@@ -13,71 +14,152 @@ import (
 
 type repository struct {
 	db     *sqlx.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 type Repository interface {
-	Create(music models.Music) (uuid.UUID, error)
-	GetById(id uuid.UUID) (models.Music, error)
-	DeleteById(id uuid.UUID) error
-	GetAll(page, size int) ([]models.Music, error)
+	Create(ctx context.Context, music models.Music) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (models.Music, error)
+	DeleteById(ctx context.Context, id uuid.UUID) error
+	GetAll(ctx context.Context, page, size int) ([]models.Music, error)
+	GetTaste(ctx context.Context, userId uuid.UUID) (models.Taste, error)
+	GetTasteMatches(ctx context.Context, userId uuid.UUID, weights models.Taste, page, size int) ([]models.TasteMatch, error)
 }
 
 const (
-	musicTable = "musics"
+	musicTable       = "musics"
+	userTable        = "users"
+	userToMusicTable = "users_to_music"
+	likesTable       = "user_likes"
+	dislikesTable    = "user_dislikes"
+	blocksTable      = "user_blocks"
 )
 
-func NewRepository(db *sqlx.DB, logger *log.Logger) Repository {
+func NewRepository(db *sqlx.DB, logger *slog.Logger) Repository {
 	return repository{
 		db:     db,
 		logger: logger,
 	}
 }
 
-func (r repository) Create(music models.Music) (uuid.UUID, error) {
-	query := fmt.Sprintf("INSERT INTO %s (id, name, author, url)"+
-		" values ($1, $2, $3, $4) RETURNING id", musicTable)
+func (r repository) Create(ctx context.Context, music models.Music) (uuid.UUID, error) {
+	query := fmt.Sprintf("INSERT INTO %s (id, name, author, genre, url)"+
+		" values ($1, $2, $3, $4, $5) RETURNING id", musicTable)
 
 	var id uuid.UUID
 
-	row := r.db.QueryRow(query, music.Id, music.Name, music.Author, music.Url)
+	row := r.db.QueryRowContext(ctx, query, music.Id, music.Name, music.Author, music.Genre, music.Url)
 
 	if err := row.Scan(&id); err != nil {
-		r.logger.Printf("error in db while trying to create music %v, error: %s",
-			music.Id, err.Error())
+		r.logger.ErrorContext(ctx, "error in db while trying to create music", "music_id", music.Id, "error", err)
 		return uuid.Nil, err
 	}
 
 	return id, nil
 }
 
-func (r repository) GetById(id uuid.UUID) (models.Music, error) {
+func (r repository) GetById(ctx context.Context, id uuid.UUID) (models.Music, error) {
 	var music models.Music
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, musicTable)
-	err := r.db.Get(&music, query, id)
+	err := r.db.GetContext(ctx, &music, query, id)
 	if err == sql.ErrNoRows {
-		return music, schemas.NotFoundError{Message: fmt.Sprintf("Not found any music with id %d", id)}
+		return music, schemas.NotFoundError{Message: fmt.Sprintf("Not found any music with id %v", id)}
 	}
 
 	return music, err
 }
 
-func (r repository) DeleteById(id uuid.UUID) error {
+func (r repository) DeleteById(ctx context.Context, id uuid.UUID) error {
 	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, musicTable)
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 
 	return err
 }
 
-func (r repository) GetAll(page, size int) ([]models.Music, error) {
+func (r repository) GetAll(ctx context.Context, page, size int) ([]models.Music, error) {
 	var musics []models.Music
-	query := fmt.Sprintf("SELECT * FROM %s LIMIT $1 OFFSET $2", musicTable)
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY name, id LIMIT $1 OFFSET $2", musicTable)
 
-	err := r.db.Select(&musics, query, page*size, page-1)
+	err := r.db.SelectContext(ctx, &musics, query, size, (page-1)*size)
 	if err != nil {
-		r.logger.Printf("error in db while trying to get all musics, error: %s", err.Error())
+		r.logger.ErrorContext(ctx, "error in db while trying to get all musics", "error", err)
 		return nil, err
 	}
 
 	return musics, nil
 }
+
+func (r repository) GetTaste(ctx context.Context, userId uuid.UUID) (models.Taste, error) {
+	var taste models.Taste
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(um.favourite_level), 0) AS tracks,
+		COUNT(DISTINCT m.author) AS authors,
+		COUNT(DISTINCT NULLIF(m.genre, '')) AS genres
+		FROM %s um JOIN %s m ON m.id = um.music_id
+		WHERE um.user_id = $1`, userToMusicTable, musicTable)
+
+	if err := r.db.GetContext(ctx, &taste, query, userId); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get music taste of user", "user_id", userId, "error", err)
+		return models.Taste{}, err
+	}
+
+	return taste, nil
+}
+
+// GetTasteMatches ranks the other users by the overlap of their music with
+// the music of the user, each part of the overlap multiplied by its weight.
+// Users the user already liked or disliked are left out, passed ones until
+// the pass expires, and so are users blocked by or blocking the user.
+func (r repository) GetTasteMatches(ctx context.Context, userId uuid.UUID, weights models.Taste, page, size int) ([]models.TasteMatch, error) {
+	var matches []models.TasteMatch
+	query := fmt.Sprintf(`WITH music AS (
+			SELECT um.user_id, um.favourite_level, m.id, m.name, m.author, m.genre
+			FROM %[1]s um JOIN %[2]s m ON m.id = um.music_id
+		), mine AS (
+			SELECT * FROM music WHERE user_id = $1
+		), theirs AS (
+			SELECT * FROM music WHERE user_id <> $1
+		), tracks AS (
+			SELECT t.user_id, SUM(LEAST(t.favourite_level, mine.favourite_level)) AS shared,
+				(ARRAY_AGG(mine.name ORDER BY t.favourite_level + mine.favourite_level DESC))[1] AS top
+			FROM theirs t JOIN mine ON mine.id = t.id
+			GROUP BY t.user_id
+		), authors AS (
+			SELECT t.user_id, COUNT(DISTINCT t.author) AS shared,
+				(ARRAY_AGG(t.author ORDER BY t.favourite_level DESC))[1] AS top
+			FROM theirs t
+			WHERE t.author IN (SELECT author FROM mine)
+			GROUP BY t.user_id
+		), genres AS (
+			SELECT t.user_id, COUNT(DISTINCT t.genre) AS shared,
+				(ARRAY_AGG(t.genre ORDER BY t.favourite_level DESC))[1] AS top
+			FROM theirs t
+			WHERE t.genre <> '' AND t.genre IN (SELECT genre FROM mine)
+			GROUP BY t.user_id
+		)
+		SELECT u.*,
+			COALESCE(tracks.shared, 0) AS shared_tracks, COALESCE(tracks.top, '') AS top_track,
+			COALESCE(authors.shared, 0) AS shared_authors, COALESCE(authors.top, '') AS top_author,
+			COALESCE(genres.shared, 0) AS shared_genres, COALESCE(genres.top, '') AS top_genre
+		FROM %[3]s u
+			LEFT JOIN tracks ON tracks.user_id = u.id
+			LEFT JOIN authors ON authors.user_id = u.id
+			LEFT JOIN genres ON genres.user_id = u.id
+		WHERE u.id <> $1
+			AND NOT EXISTS (SELECT 1 FROM %[4]s l WHERE l.from_who = $1 AND l.who = u.id)
+			AND NOT EXISTS (SELECT 1 FROM %[5]s d WHERE d.from_who = $1 AND d.who = u.id
+				AND (d.expires_at IS NULL OR d.expires_at > now()))
+			AND NOT EXISTS (SELECT 1 FROM %[6]s b WHERE (b.from_who = $1 AND b.who = u.id)
+				OR (b.from_who = u.id AND b.who = $1))
+		ORDER BY COALESCE(tracks.shared, 0) * $2 + COALESCE(authors.shared, 0) * $3
+			+ COALESCE(genres.shared, 0) * $4 DESC, u.id
+		LIMIT $5 OFFSET $6`, userToMusicTable, musicTable, userTable, likesTable, dislikesTable, blocksTable)
+
+	err := r.db.SelectContext(ctx, &matches, query, userId, weights.Tracks, weights.Authors, weights.Genres,
+		size, (page-1)*size)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get taste matches of user", "user_id", userId, "error", err)
+		return nil, err
+	}
+
+	return matches, nil
+}
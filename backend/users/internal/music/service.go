@@ -1,7 +1,9 @@
 package music
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 
 	"github.com/Feokrat/music-dating-app/users/internal/models"
 	"github.com/Feokrat/music-dating-app/users/internal/schemas"
@@ -10,41 +12,97 @@ import (
 
 type service struct {
 	musicRepository Repository
-	logger          *log.Logger
+	logger          *slog.Logger
 }
 
 type Service interface {
-	AddMusic(music models.Music) (uuid.UUID, error)
-	GetMusicById(id uuid.UUID) (models.Music, error)
-	DeleteMusicById(id uuid.UUID) error
-	GetAllMusics(page, size int) (schemas.MusicsResponse, error)
-	GetUserRecommendations(id uuid.UUID, page, size int) (schemas.UsersResponse, error)
+	AddMusic(ctx context.Context, music models.Music) (uuid.UUID, error)
+	GetMusicById(ctx context.Context, id uuid.UUID) (models.Music, error)
+	DeleteMusicById(ctx context.Context, id uuid.UUID) error
+	GetAllMusics(ctx context.Context, page, size int) (schemas.MusicsResponse, error)
+	GetUserRecommendations(ctx context.Context, id uuid.UUID, page, size int) ([]models.Recommendation, error)
 }
 
-func NewService(repo Repository, logger *log.Logger) Service {
+// weights of the parts of the overlap of two tastes in music. Shared tracks
+// also share their author and genre, so they score the highest in total.
+var weights = models.Taste{
+	Tracks:  2,
+	Authors: 3,
+	Genres:  1,
+}
+
+func NewService(repo Repository, logger *slog.Logger) Service {
 	return service{repo, logger}
 }
 
-func (s service) AddMusic(music models.Music) (uuid.UUID, error) {
-	id, err := s.musicRepository.Create(music)
+func (s service) AddMusic(ctx context.Context, music models.Music) (uuid.UUID, error) {
+	id, err := s.musicRepository.Create(ctx, music)
 	return id, err
 }
 
-func (s service) GetMusicById(id uuid.UUID) (models.Music, error) {
-	user, err := s.musicRepository.GetById(id)
+func (s service) GetMusicById(ctx context.Context, id uuid.UUID) (models.Music, error) {
+	user, err := s.musicRepository.GetById(ctx, id)
 	return user, err
 }
 
-func (s service) DeleteMusicById(id uuid.UUID) error {
-	err := s.musicRepository.DeleteById(id)
+func (s service) DeleteMusicById(ctx context.Context, id uuid.UUID) error {
+	err := s.musicRepository.DeleteById(ctx, id)
 	return err
 }
 
-func (s service) GetAllMusics(page, size int) (schemas.MusicsResponse, error) {
-	musics, err := s.musicRepository.GetAll(page, size)
+func (s service) GetAllMusics(ctx context.Context, page, size int) (schemas.MusicsResponse, error) {
+	musics, err := s.musicRepository.GetAll(ctx, page, size)
 	return schemas.MusicsResponse{Musics: musics}, err
 }
 
-func (s service) GetUserRecommendations(id uuid.UUID, page, size int) (schemas.UsersResponse, error) {
-	return schemas.UsersResponse{}, nil
+// GetUserRecommendations ranks the other users by how well their music
+// matches the music of the user. The score is the share of the taste of the
+// user the candidate matches, in percent.
+func (s service) GetUserRecommendations(ctx context.Context, id uuid.UUID, page, size int) ([]models.Recommendation, error) {
+	taste, err := s.musicRepository.GetTaste(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := s.musicRepository.GetTasteMatches(ctx, id, weights, page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	maxScore := score(taste)
+	recommendations := make([]models.Recommendation, 0, len(matches))
+	for _, match := range matches {
+		recommendation := models.Recommendation{
+			User:        match.User,
+			Explanation: explain(match),
+		}
+		if maxScore > 0 {
+			recommendation.Score = 100 * score(models.Taste{
+				Tracks:  match.SharedTracks,
+				Authors: match.SharedAuthors,
+				Genres:  match.SharedGenres,
+			}) / maxScore
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+
+	return recommendations, nil
+}
+
+func score(taste models.Taste) int {
+	return taste.Tracks*weights.Tracks + taste.Authors*weights.Authors + taste.Genres*weights.Genres
+}
+
+// explain names the strongest thing the users have in common.
+func explain(match models.TasteMatch) string {
+	switch {
+	case match.TopTrack != "":
+		return fmt.Sprintf("you both love %s", match.TopTrack)
+	case match.TopAuthor != "":
+		return fmt.Sprintf("you both listen to %s", match.TopAuthor)
+	case match.TopGenre != "":
+		return fmt.Sprintf("you both like %s", match.TopGenre)
+	}
+
+	return ""
 }
@@ -0,0 +1,110 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/Feokrat/music-dating-app/users/internal/config"
+	"github.com/Feokrat/music-dating-app/users/pkg/HTTPclient"
+	"github.com/google/uuid"
+)
+
+const (
+	// batchSize keeps the query string of a request short.
+	batchSize = 100
+	// primeSubscription is the subscription type of Prime in the payment service.
+	primeSubscription = 1
+)
+
+type service struct {
+	logger *slog.Logger
+	client *HTTPclient.HTTPclient
+	config config.ServicesConfig
+}
+
+type subscription struct {
+	UserId           uuid.UUID `json:"userId"`
+	SubscriptionType int       `json:"subscriptionType"`
+}
+
+type subscriptionsResponse struct {
+	Subscriptions []subscription `json:"subscriptions"`
+}
+
+// GetSubscriptionTypes returns the active subscription type of each of the
+// users, users without an active subscription are left out.
+func (s service) GetSubscriptionTypes(ctx context.Context, userIds []uuid.UUID) (map[uuid.UUID]int, error) {
+	types := make(map[uuid.UUID]int, len(userIds))
+	for start := 0; start < len(userIds); start += batchSize {
+		end := start + batchSize
+		if end > len(userIds) {
+			end = len(userIds)
+		}
+
+		subscriptions, err := s.getSubscriptions(ctx, userIds[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, subscription := range subscriptions {
+			types[subscription.UserId] = subscription.SubscriptionType
+		}
+	}
+
+	return types, nil
+}
+
+// HasPrime tells whether the user has an active Prime subscription.
+func (s service) HasPrime(ctx context.Context, userId uuid.UUID) (bool, error) {
+	types, err := s.GetSubscriptionTypes(ctx, []uuid.UUID{userId})
+	if err != nil {
+		return false, err
+	}
+	subscriptionType, ok := types[userId]
+	return ok && subscriptionType == primeSubscription, nil
+}
+
+func (s service) getSubscriptions(ctx context.Context, userIds []uuid.UUID) ([]subscription, error) {
+	query := url.Values{}
+	for _, userId := range userIds {
+		query.Add("user_id", userId.String())
+	}
+	subscriptionsUrl := s.config.PaymentService + "/payments/?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", subscriptionsUrl, nil)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not create request", "error", err)
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get subscriptions of users", "error", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.client.ResponseError(resp)
+	}
+
+	var response subscriptionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		s.logger.ErrorContext(ctx, "could not unmarshal response body", "error", err)
+		return nil, err
+	}
+
+	return response.Subscriptions, nil
+}
+
+type Service interface {
+	GetSubscriptionTypes(ctx context.Context, userIds []uuid.UUID) (map[uuid.UUID]int, error)
+	HasPrime(ctx context.Context, userId uuid.UUID) (bool, error)
+}
+
+func NewService(config config.ServicesConfig, logger *slog.Logger) Service {
+	return service{logger: logger, client: HTTPclient.NewHTTPclient("payment_service", config.Client("payment_service")),
+		config: config}
+}
@@ -1,10 +1,65 @@
 package schemas
 
 import (
+	"time"
+
 	"github.com/Feokrat/music-dating-app/users/internal/models"
+	"github.com/Feokrat/music-dating-app/users/pkg/problem"
 	"github.com/google/uuid"
 )
 
+// DateLayout is the format of the dates without time in the requests and
+// responses.
+const DateLayout = "2006-01-02"
+
+// SwipeResponse is a like, dislike or pass given to the user UserId. Passes
+// expire, the user is recommended again after ExpiresAt.
+type SwipeResponse struct {
+	Id        uuid.UUID  `json:"id"`
+	UserId    uuid.UUID  `json:"userId"`
+	Kind      string     `json:"kind"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type SwipesResponse struct {
+	Swipes []SwipeResponse `json:"swipes"`
+}
+
+// MatchUserResponse is the preview of the profile of the other user of a
+// match.
+type MatchUserResponse struct {
+	Id      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Surname string    `json:"surname"`
+	Image   string    `json:"image"`
+}
+
+type MatchResponse struct {
+	Id        uuid.UUID         `json:"id"`
+	CreatedAt time.Time         `json:"createdAt"`
+	User      MatchUserResponse `json:"user"`
+}
+
+type MatchesResponse struct {
+	Matches []MatchResponse `json:"matches"`
+}
+
+// BlockRequest blocks the user UserId, a report also needs the reason.
+type BlockRequest struct {
+	UserId uuid.UUID `json:"userId" binding:"required"`
+	Reason string    `json:"reason" binding:"max=1000"`
+	Report bool      `json:"report"`
+}
+
+type BlockResponse struct {
+	Id        uuid.UUID `json:"id"`
+	UserId    uuid.UUID `json:"userId"`
+	Reason    string    `json:"reason"`
+	Reported  bool      `json:"reported"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type UserImageResponse struct {
 	Image string `json:"image"`
 }
@@ -21,15 +76,27 @@ type UserRequest struct {
 	HasAccess   bool   `json:"hasAccess"`
 }
 
+// UpdateRequest changes the profile fields that are set, BirthDate is a date
+// like 2000-12-31 and an empty list of interests clears them.
 type UpdateRequest struct {
-	Name    *string `json:"name" db:"name"`
-	Surname *string `json:"surname" db:"surname"`
-	Image   string  `json:"image" db:"image"`
+	Name        *string  `json:"name" db:"name"`
+	Surname     *string  `json:"surname" db:"surname"`
+	Description *string  `json:"description" db:"description" binding:"omitempty,max=1000"`
+	BirthDate   *string  `json:"birthDate" db:"birth_date" binding:"omitempty,datetime=2006-01-02"`
+	Gender      *string  `json:"gender" db:"gender" binding:"omitempty,oneof=female male other"`
+	Interests   []string `json:"interests" db:"interests" binding:"omitempty,max=20,dive,min=1,max=50"`
+	City        *string  `json:"city" db:"city" binding:"omitempty,max=100"`
+	Image       string   `json:"image" db:"image"`
+}
+
+type AccessRequest struct {
+	HasAccess *bool `json:"hasAccess" binding:"required"`
 }
 
 type MusicRequest struct {
 	Name   string `json:"name"`
 	Author string `json:"author"`
+	Genre  string `json:"genre"`
 	Url    string `json:"url"`
 }
 
@@ -44,20 +111,25 @@ type ImageRequest struct {
 	Image  string    `json:"image" db:"image"`
 }
 
-type ValidationErrorResponse struct {
-	Message string `json:"message"`
-	Errors  string `json:"errors"`
-}
-
+// LikeResponse tells whether the users matched, IsNewMatch is only set for
+// the like that made the match.
 type LikeResponse struct {
-	IsMatch bool
+	IsMatch    bool
+	MatchId    *uuid.UUID `json:"matchId,omitempty"`
+	IsNewMatch bool       `json:"isNewMatch"`
 }
 
+// UserResponse is the profile of the user, BirthDate is a date like
+// 2000-12-31 and left out when the user did not tell it.
 type UserResponse struct {
 	Id               uuid.UUID `json:"id"`
 	Name             string    `json:"name"`
 	Surname          string    `json:"surname"`
 	Description      string    `json:"description"`
+	BirthDate        string    `json:"birthDate,omitempty"`
+	Gender           string    `json:"gender"`
+	Interests        []string  `json:"interests"`
+	City             string    `json:"city"`
 	Image            string    `json:"image"`
 	SubscriptionType int       `json:"subscriptionType"`
 	MusicIds         []string  `json:"musicIds"`
@@ -67,6 +139,18 @@ type UsersResponse struct {
 	Users []UserResponse `json:"users"`
 }
 
+// RecommendationResponse is a recommended user with its compatibility score
+// in percent and why it was recommended.
+type RecommendationResponse struct {
+	UserResponse
+	Score       int    `json:"score"`
+	Explanation string `json:"explanation"`
+}
+
+type RecommendationsResponse struct {
+	Users []RecommendationResponse `json:"users"`
+}
+
 type MusicResponse struct {
 	Music models.Music `json:"music"`
 }
@@ -75,18 +159,38 @@ type MusicsResponse struct {
 	Musics []models.Music `json:"musics"`
 }
 
-type ErrorResponse struct {
+type NotFoundError struct {
 	Message string `json:"message"`
 }
 
-func (e ErrorResponse) Error() string {
+func (e NotFoundError) Error() string {
 	return e.Message
 }
 
-type NotFoundError struct {
+func (e NotFoundError) Problem() *problem.Problem {
+	return problem.NotFound(e.Message)
+}
+
+type ConflictError struct {
 	Message string `json:"message"`
 }
 
-func (e NotFoundError) Error() string {
+func (e ConflictError) Error() string {
 	return e.Message
 }
+
+func (e ConflictError) Problem() *problem.Problem {
+	return problem.Conflict(e.Message)
+}
+
+type ForbiddenError struct {
+	Message string `json:"message"`
+}
+
+func (e ForbiddenError) Error() string {
+	return e.Message
+}
+
+func (e ForbiddenError) Problem() *problem.Problem {
+	return problem.Forbidden(e.Message)
+}
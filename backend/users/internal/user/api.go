@@ -1,23 +1,29 @@
 package user
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Feokrat/music-dating-app/users/internal/models"
 	"github.com/Feokrat/music-dating-app/users/internal/schemas"
+	"github.com/Feokrat/music-dating-app/users/pkg/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// minAge is the age a user must have reached by the birth date in the profile.
+const minAge = 18
+
 type handler struct {
 	service Service
-	logger  *log.Logger
+	logger  *slog.Logger
 }
 
-func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *log.Logger) {
+func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *slog.Logger) {
 	h := handler{service, logger}
 
 	rg.POST("/", h.addUser)
@@ -27,21 +33,22 @@ func RegisterHandlers(rg *gin.RouterGroup, service Service, logger *log.Logger)
 	rg.POST("/add-image", h.addImage)
 	rg.GET("/:id/image", h.getImage)
 	rg.PUT("/:id", h.updateUserById)
+	rg.PUT("/:id/access", h.updateUserAccess)
 	rg.GET("/list", h.getAllUsers)
 	rg.GET("/recommendation-list/:id", h.getUserRecommendations)
 	rg.POST("/like/:id", h.likeUser)
+	rg.POST("/dislike/:id", h.dislikeUser)
+	rg.POST("/pass/:id", h.passUser)
+	rg.GET("/:id/swipes", h.getSwipes)
+	rg.POST("/:id/swipes/undo", h.undoLastSwipe)
 }
 
 func (h handler) likeUser(ctx *gin.Context) {
 	userIdStr := ctx.Param("id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
@@ -49,80 +56,162 @@ func (h handler) likeUser(ctx *gin.Context) {
 	likedIdStr := ctx.Query("liked")
 	likedId, err := uuid.Parse(likedIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("liked", err))
 
 		return
 	}
 
-	isMatch, err := h.service.LikeUser(userId, likedId)
+	like, err := h.service.LikeUser(ctx.Request.Context(), userId, likedId)
 	if err != nil {
-		h.logger.Printf("could not like %v user, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not like user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
-	ctx.JSON(http.StatusOK, schemas.LikeResponse{IsMatch: isMatch})
+	ctx.JSON(http.StatusOK, like)
 }
 
-func (h handler) getImage(ctx *gin.Context) {
+func (h handler) dislikeUser(ctx *gin.Context) {
+	h.swipeUser(ctx, "disliked", h.service.DislikeUser)
+}
+
+func (h handler) passUser(ctx *gin.Context) {
+	h.swipeUser(ctx, "passed", h.service.PassUser)
+}
+
+// swipeUser handles the swipes other than likes, the swiped user is given in
+// the query parameter param.
+func (h handler) swipeUser(ctx *gin.Context, param string,
+	swipe func(ctx context.Context, id uuid.UUID, swipedId uuid.UUID) (schemas.SwipeResponse, error)) {
 	userIdStr := ctx.Param("id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	image, err := h.service.GetUserImageById(userId)
+	swipedIdStr := ctx.Query(param)
+	swipedId, err := uuid.Parse(swipedIdStr)
 	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", swipedIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam(param, err))
 
-		if (err == schemas.NotFoundError{}) {
-			ctx.JSON(http.StatusNotFound, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
-			return
-		}
+		return
+	}
+
+	response, err := swipe(ctx.Request.Context(), userId, swipedId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not swipe user", "user_id", userId, "param", param, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (h handler) getSwipes(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	pageStr := ctx.Query("page")
+	if pageStr == "" {
+		pageStr = "1"
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
+
+		return
+	}
+
+	sizeStr := ctx.Query("size")
+	if sizeStr == "" {
+		sizeStr = "100"
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
+
+		return
+	}
+
+	swipes, err := h.service.GetSwipes(ctx.Request.Context(), userId, page, size)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get swipes of user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, swipes)
+}
+
+func (h handler) undoLastSwipe(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	swipe, err := h.service.UndoLastSwipe(ctx.Request.Context(), userId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not undo last swipe of user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, swipe)
+}
+
+func (h handler) getImage(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
 
-		h.logger.Printf("could not get user %v image, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+	image, err := h.service.GetUserImageById(ctx.Request.Context(), userId)
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get user image", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
-	h.logger.Print(image)
+	h.logger.DebugContext(ctx.Request.Context(), "got user image", "image", image)
 	ctx.JSON(http.StatusOK, schemas.UserImageResponse{Image: image.Image})
 }
 
 func (h handler) addUser(ctx *gin.Context) {
 	var requestModel schemas.UserRequest
-	if err := ctx.BindJSON(&requestModel); err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong request model",
-			Errors:  err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	id, err := h.service.AddUser(models.User{
+	id, err := h.service.AddUser(ctx.Request.Context(), models.User{
 		Id:          uuid.New(),
 		Name:        requestModel.Name,
 		Surname:     requestModel.Surname,
@@ -132,11 +221,8 @@ func (h handler) addUser(ctx *gin.Context) {
 	})
 
 	if err != nil {
-		h.logger.Printf("could not add user %v, error: %s",
-			requestModel, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not add user", "request_model", requestModel, "error", err)
+		problem.Respond(ctx, err)
 		return
 	}
 
@@ -148,28 +234,21 @@ func (h handler) getUserById(ctx *gin.Context) {
 	userIdStr := ctx.Param("id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	user, err := h.service.GetUserById(userId)
+	user, err := h.service.GetUserById(ctx.Request.Context(), userId)
 	if err != nil {
-		h.logger.Printf("could not get user %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
 
-	h.logger.Print(user)
+	h.logger.DebugContext(ctx.Request.Context(), "got user", "user", user)
 	ctx.JSON(http.StatusOK, user)
 }
 
@@ -177,23 +256,16 @@ func (h handler) deleteUserById(ctx *gin.Context) {
 	userIdStr := ctx.Param("id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	err = h.service.DeleteUserById(userId)
+	err = h.service.DeleteUserById(ctx.Request.Context(), userId)
 	if err != nil {
-		h.logger.Printf("could not delete user %v, error: %s",
-			userId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not delete user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -203,17 +275,13 @@ func (h handler) deleteUserById(ctx *gin.Context) {
 
 func (h handler) addMusic(ctx *gin.Context) {
 	var requestModel schemas.UserToMusicRequest
-	if err := ctx.BindJSON(&requestModel); err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong request model",
-			Errors:  err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	err := h.service.AddMusicToUser(models.UserToMusic{
+	err := h.service.AddMusicToUser(ctx.Request.Context(), models.UserToMusic{
 		Id:             uuid.New(),
 		UserId:         requestModel.UserId,
 		MusicId:        requestModel.MusicId,
@@ -221,39 +289,29 @@ func (h handler) addMusic(ctx *gin.Context) {
 	})
 
 	if err != nil {
-		h.logger.Printf("could not add music %v to user %v, error: %s",
-			requestModel.MusicId, requestModel.UserId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not add music to user", "music_id", requestModel.MusicId, "user_id", requestModel.UserId, "error", err)
+		problem.Respond(ctx, err)
 		return
 	}
 }
 
 func (h handler) addImage(ctx *gin.Context) {
 	var requestModel schemas.ImageRequest
-	if err := ctx.BindJSON(&requestModel); err != nil {
-		h.logger.Printf("request body in wrong format, error: %s",
-			err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong request model",
-			Errors:  err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "request body in wrong format", "error", err)
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	err := h.service.AddImageToUser(models.Image{
+	err := h.service.AddImageToUser(ctx.Request.Context(), models.Image{
 		Id:     uuid.New(),
 		UserId: requestModel.UserId,
 		Image:  requestModel.Image,
 	})
 
 	if err != nil {
-		h.logger.Printf("could not add image to user %v, error: %s",
-			requestModel.UserId, err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "could not add image to user", "user_id", requestModel.UserId, "error", err)
+		problem.Respond(ctx, err)
 		return
 	}
 }
@@ -262,56 +320,85 @@ func (h handler) updateUserById(ctx *gin.Context) {
 	userIdStr := ctx.Param("id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
 
 		return
 	}
 
-	t, err := h.service.GetUserById(userId)
+	_, err = h.service.GetUserById(ctx.Request.Context(), userId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		problem.Respond(ctx, err)
 		return
 	}
-	fmt.Printf("%v", t)
-
-	//if user == (schemas.UserResponse{}) {
-	//	ctx.JSON(http.StatusNotFound, schemas.ErrorResponse{
-	//		Message: fmt.Sprintf("Not found user with id %d\n", userId),
-	//	})
-	//}
 
 	var requestModel schemas.UpdateRequest
-	if err := ctx.BindJSON(&requestModel); err != nil {
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "Wrong user model.",
-			Errors:  err.Error(),
-		})
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
 		return
 	}
 
-	err = h.service.UpdateUserInfo(userId, models.UpdateUserInfo{
-		Name:    requestModel.Name,
-		Surname: requestModel.Surname,
+	var birthDate *time.Time
+	if requestModel.BirthDate != nil {
+		date, err := time.Parse(schemas.DateLayout, *requestModel.BirthDate)
+		if err != nil {
+			problem.Respond(ctx, problem.InvalidParam("birthDate", err))
+			return
+		}
+		if date.After(time.Now().AddDate(-minAge, 0, 0)) {
+			problem.Respond(ctx, problem.InvalidParam("birthDate", fmt.Errorf("user must be at least %d years old", minAge)))
+			return
+		}
+		birthDate = &date
+	}
+
+	err = h.service.UpdateUserInfo(ctx.Request.Context(), userId, models.UpdateUserInfo{
+		Name:        requestModel.Name,
+		Surname:     requestModel.Surname,
+		Description: requestModel.Description,
+		BirthDate:   birthDate,
+		Gender:      requestModel.Gender,
+		Interests:   requestModel.Interests,
+		City:        requestModel.City,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: err.Error(),
-		})
+		problem.Respond(ctx, err)
 		return
 	}
 
-	err = h.service.AddImageToUser(models.Image{UserId: userId, Image: requestModel.Image, Id: uuid.New()})
+	if requestModel.Image != "" {
+		err = h.service.AddImageToUser(ctx.Request.Context(), models.Image{UserId: userId, Image: requestModel.Image, Id: uuid.New()})
+	}
 
 	ctx.JSON(http.StatusOK, nil)
 }
 
+func (h handler) updateUserAccess(ctx *gin.Context) {
+	userIdStr := ctx.Param("id")
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	var requestModel schemas.AccessRequest
+	if err := ctx.ShouldBindJSON(&requestModel); err != nil {
+		problem.Respond(ctx, problem.Binding(err))
+		return
+	}
+
+	err = h.service.UpdateUserInfo(ctx.Request.Context(), userId, models.UpdateUserInfo{HasAccess: requestModel.HasAccess})
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not update access of user", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
 func (h handler) getAllUsers(ctx *gin.Context) {
 	pageStr := ctx.Query("page")
 	if pageStr == "" {
@@ -320,10 +407,8 @@ func (h handler) getAllUsers(ctx *gin.Context) {
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil {
-		h.logger.Printf("could not convert page param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "page param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
 
 		return
 	}
@@ -334,20 +419,16 @@ func (h handler) getAllUsers(ctx *gin.Context) {
 	}
 	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
-		h.logger.Printf("could not convert size param to int")
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "size param is not int",
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
 
 		return
 	}
 
-	users, err := h.service.GetAllUsers(page, size)
+	users, err := h.service.GetAllUsers(ctx.Request.Context(), page, size)
 	if err != nil {
-		h.logger.Printf("error while handling get all users, error: %s", err.Error())
-		ctx.JSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: fmt.Sprintf("internal error: %s", err.Error()),
-		})
+		h.logger.ErrorContext(ctx.Request.Context(), "error while handling get all users", "error", err)
+		problem.Respond(ctx, err)
 
 		return
 	}
@@ -359,22 +440,43 @@ func (h handler) getUserRecommendations(ctx *gin.Context) {
 	userIdStr := ctx.Param("id")
 	userId, err := uuid.Parse(userIdStr)
 	if err != nil {
-		h.logger.Printf("could not parse user id %v, error: %s",
-			userIdStr, err.Error())
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: "wrong user id format",
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not parse user id", "user_id", userIdStr, "error", err)
+		problem.Respond(ctx, problem.InvalidParam("id", err))
+
+		return
+	}
+
+	pageStr := ctx.Query("page")
+	if pageStr == "" {
+		pageStr = "1"
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert page param to int")
+		problem.Respond(ctx, problem.InvalidParam("page", err))
 
 		return
 	}
-	users, err := h.service.GetUserRecommendations(userId, 1, 100)
 
+	sizeStr := ctx.Query("size")
+	if sizeStr == "" {
+		sizeStr = "100"
+	}
+	size, err := strconv.Atoi(sizeStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, schemas.ValidationErrorResponse{
-			Message: fmt.Sprintf("Couldn't get user recommendations for user %v", userId),
-			Errors:  err.Error(),
-		})
+		h.logger.WarnContext(ctx.Request.Context(), "could not convert size param to int")
+		problem.Respond(ctx, problem.InvalidParam("size", err))
+
+		return
+	}
+
+	users, err := h.service.GetUserRecommendations(ctx.Request.Context(), userId, page, size)
+
+	if err != nil {
+		h.logger.ErrorContext(ctx.Request.Context(), "could not get user recommendations", "user_id", userId, "error", err)
+		problem.Respond(ctx, err)
+		return
 	}
 
 	ctx.JSON(http.StatusOK, users)
@@ -0,0 +1,33 @@
+package user
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	likesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_likes_total",
+		Help: "Number of likes users gave.",
+	})
+
+	matchesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_matches_total",
+		Help: "Number of likes that were answered by a like.",
+	})
+
+	dislikesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_dislikes_total",
+		Help: "Number of dislikes users gave.",
+	})
+
+	passesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_passes_total",
+		Help: "Number of users passed for now.",
+	})
+
+	undoneSwipesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_undone_swipes_total",
+		Help: "Number of swipes users undid.",
+	})
+)
@@ -1,34 +1,40 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/Feokrat/music-dating-app/users/internal/models"
 	"github.com/Feokrat/music-dating-app/users/internal/schemas"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type repository struct {
 	db     *sqlx.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 type Repository interface {
-	Create(user models.User) (uuid.UUID, error)
-	GetById(id uuid.UUID) (models.User, error)
-	Update(id uuid.UUID, user models.UpdateUserInfo) error
-	DeleteById(id uuid.UUID) error
-	CreateMusicToUser(models.UserToMusic) error
-	CreateUserImage(image models.Image) error
-	UpdateUserImage(userId uuid.UUID, image string) error
-	GetUserImage(id uuid.UUID) (models.Image, error)
-	GetAll(page, size int) ([]models.User, error)
-	GetRecommendationsForUser(userId uuid.UUID, page int, size int) ([]models.User, error)
-	Like(id uuid.UUID, likedId uuid.UUID) (bool, error)
+	Create(ctx context.Context, user models.User) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (models.User, error)
+	Update(ctx context.Context, id uuid.UUID, user models.UpdateUserInfo) error
+	DeleteById(ctx context.Context, id uuid.UUID) error
+	CreateMusicToUser(ctx context.Context, userToMusic models.UserToMusic) error
+	CreateUserImage(ctx context.Context, image models.Image) error
+	UpdateUserImage(ctx context.Context, userId uuid.UUID, image string) error
+	GetUserImage(ctx context.Context, id uuid.UUID) (models.Image, error)
+	GetAll(ctx context.Context, page, size int) ([]models.User, error)
+	GetMusic(ctx context.Context, userIds []uuid.UUID) ([]models.UserToMusic, error)
+	Like(ctx context.Context, id uuid.UUID, likedId uuid.UUID) (*models.Match, bool, error)
+	Dislike(ctx context.Context, id uuid.UUID, dislikedId uuid.UUID, expiresAt *time.Time) (models.Swipe, error)
+	GetSwipes(ctx context.Context, id uuid.UUID, page, size int) ([]models.Swipe, error)
+	UndoLastSwipe(ctx context.Context, id uuid.UUID) (models.Swipe, error)
 }
 
 const (
@@ -36,55 +42,205 @@ const (
 	userToMusicTable = "users_to_music"
 	imageTable       = "images"
 	likesTable       = "user_likes"
+	dislikesTable    = "user_dislikes"
+	matchesTable     = "matches"
+	blocksTable      = "user_blocks"
 )
 
-func NewRepository(db *sqlx.DB, logger *log.Logger) Repository {
+// swipesQuery lists the likes, dislikes and passes the user $1 gave, the
+// latest first.
+var swipesQuery = fmt.Sprintf(`SELECT id, '%[3]s' AS kind, who, from_who, created_at, NULL::timestamptz AS expires_at
+	FROM %[1]s WHERE from_who = $1
+	UNION ALL
+	SELECT id, CASE WHEN expires_at IS NULL THEN '%[4]s' ELSE '%[5]s' END AS kind, who, from_who, created_at, expires_at
+	FROM %[2]s WHERE from_who = $1
+	ORDER BY created_at DESC, id DESC`, likesTable, dislikesTable, models.LikeSwipe, models.DislikeSwipe, models.PassSwipe)
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) Repository {
 	return repository{
 		db:     db,
 		logger: logger,
 	}
 }
 
-func (r repository) Like(id uuid.UUID, likedId uuid.UUID) (bool, error) {
-	// todo: add here excepting likes table
-	query := fmt.Sprintf("INSERT INTO %s (id, who, from_who)"+
-		" values ($1, $2, $3) RETURNING id", likesTable)
-	row := r.db.QueryRow(query, uuid.New(), likedId, id)
+// Like records the like of the user, liking a user again changes nothing.
+// When the liked user liked the user back the pair matches, created reports
+// whether this like made the match. A blocked user, like one that does not
+// exist, is not found.
+func (r repository) Like(ctx context.Context, id uuid.UUID, likedId uuid.UUID) (match *models.Match, created bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// mutual likes of a pair take turns, so that the later one sees the
+	// earlier one and exactly one of them makes the match
+	query := `SELECT pg_advisory_xact_lock(hashtextextended(LEAST($1::text, $2::text) || GREATEST($1::text, $2::text), 0))`
+	if _, err := tx.ExecContext(ctx, query, id, likedId); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to lock likes of users", "id", id, "liked_id", likedId, "error", err)
+		return nil, false, err
+	}
 
-	if err := row.Scan(&id); err != nil {
-		r.logger.Printf("error in db while trying to create user like for user %v, error: %s",
-			id, err.Error())
-		return false, err
+	// blocked users can not like each other, for them the other user is gone
+	var blocked bool
+	query = fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s
+		WHERE (who = $1 AND from_who = $2) OR (who = $2 AND from_who = $1))`, blocksTable)
+	if err := tx.GetContext(ctx, &blocked, query, id, likedId); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get blocks of users", "id", id, "liked_id", likedId, "error", err)
+		return nil, false, err
+	}
+	if blocked {
+		return nil, false, schemas.NotFoundError{Message: fmt.Sprintf("Not found any user with id %v", likedId)}
+	}
+	if err := checkUser(ctx, tx, likedId); err != nil {
+		return nil, false, err
 	}
 
-	var like models.UserLikes
-	query = fmt.Sprintf(`SELECT * FROM %s WHERE from_who = $1`, likesTable)
-	err := r.db.Get(&like, query, likedId)
+	query = fmt.Sprintf("INSERT INTO %s (id, who, from_who)"+
+		" values ($1, $2, $3) ON CONFLICT (who, from_who) DO NOTHING", likesTable)
+	if _, err := tx.ExecContext(ctx, query, uuid.New(), likedId, id); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to create user like for user", "id", id, "error", err)
+		return nil, false, err
+	}
+
+	var liked bool
+	query = fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE who = $1 AND from_who = $2)`, likesTable)
+	if err := tx.GetContext(ctx, &liked, query, id, likedId); err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get like of user", "id", likedId, "error", err)
+		return nil, false, err
+	}
+	if !liked {
+		return nil, false, tx.Commit()
+	}
+
+	match = &models.Match{}
+	query = fmt.Sprintf("INSERT INTO %s (id, user_id1, user_id2)"+
+		" values ($1, LEAST($2::uuid, $3::uuid), GREATEST($2::uuid, $3::uuid))"+
+		" ON CONFLICT (user_id1, user_id2) DO NOTHING RETURNING *", matchesTable)
+	err = tx.GetContext(ctx, match, query, uuid.New(), id, likedId)
+	created = err == nil
 	if err == sql.ErrNoRows {
-		return false, nil
+		query = fmt.Sprintf(`SELECT * FROM %s WHERE user_id1 = LEAST($1::uuid, $2::uuid) AND user_id2 = GREATEST($1::uuid, $2::uuid)`, matchesTable)
+		err = tx.GetContext(ctx, match, query, id, likedId)
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to create match", "id", id, "liked_id", likedId, "error", err)
+		return nil, false, err
 	}
 
-	return true, nil
+	return match, created, tx.Commit()
 }
 
-func (r repository) GetRecommendationsForUser(userId uuid.UUID, page int, size int) ([]models.User, error) {
-	var users []models.User
-	query := fmt.Sprintf("SELECT * FROM %s  WHERE id != $1 LIMIT $2 OFFSET $3 ", userTable)
+// Dislike hides the disliked user from the user, until expiresAt when it is
+// set. Swiping the user again replaces the earlier dislike or pass, a user
+// that does not exist is not found.
+func (r repository) Dislike(ctx context.Context, id uuid.UUID, dislikedId uuid.UUID, expiresAt *time.Time) (models.Swipe, error) {
+	swipe := models.Swipe{Kind: models.DislikeSwipe}
+	if expiresAt != nil {
+		swipe.Kind = models.PassSwipe
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Swipe{}, err
+	}
+	defer tx.Rollback()
 
-	err := r.db.Select(&users, query, userId, page*size, page-1)
+	if err := checkUser(ctx, tx, dislikedId); err != nil {
+		return models.Swipe{}, err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (id, who, from_who, expires_at)"+
+		" values ($1, $2, $3, $4) ON CONFLICT (who, from_who) DO UPDATE SET expires_at = EXCLUDED.expires_at, created_at = now()"+
+		" RETURNING id, who, from_who, created_at, expires_at", dislikesTable)
+	err = tx.GetContext(ctx, &swipe, query, uuid.New(), dislikedId, id, expiresAt)
 	if err != nil {
-		r.logger.Printf("error in db while trying to get all users, error: %s", err.Error())
+		r.logger.ErrorContext(ctx, "error in db while trying to create user dislike", "user_id", id, "error", err)
+		return models.Swipe{}, err
+	}
+
+	return swipe, tx.Commit()
+}
+
+// checkUser fails with NotFoundError when the user does not exist.
+func checkUser(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error {
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1)`, userTable)
+	if err := tx.GetContext(ctx, &exists, query, id); err != nil {
+		return err
+	}
+	if !exists {
+		return schemas.NotFoundError{Message: fmt.Sprintf("Not found any user with id %v", id)}
+	}
+
+	return nil
+}
+
+func (r repository) GetSwipes(ctx context.Context, id uuid.UUID, page, size int) ([]models.Swipe, error) {
+	var swipes []models.Swipe
+	query := swipesQuery + " LIMIT $2 OFFSET $3"
+
+	err := r.db.SelectContext(ctx, &swipes, query, id, size, (page-1)*size)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get swipes of user", "user_id", id, "error", err)
 		return nil, err
 	}
 
-	return users, nil
+	return swipes, nil
 }
 
-func (r repository) GetUserImage(id uuid.UUID) (models.Image, error) {
+// UndoLastSwipe deletes the latest swipe of the user and returns it. A like
+// that was answered with a like can not be undone, the users matched.
+func (r repository) UndoLastSwipe(ctx context.Context, id uuid.UUID) (models.Swipe, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Swipe{}, err
+	}
+	defer tx.Rollback()
+
+	var swipe models.Swipe
+	err = tx.GetContext(ctx, &swipe, swipesQuery+" LIMIT 1", id)
+	if err == sql.ErrNoRows {
+		return models.Swipe{}, schemas.NotFoundError{Message: "there is no swipe to undo"}
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get last swipe of user", "user_id", id, "error", err)
+		return models.Swipe{}, err
+	}
+
+	table := dislikesTable
+	if swipe.Kind == models.LikeSwipe {
+		table = likesTable
+
+		var matched bool
+		query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s
+			WHERE user_id1 = LEAST($1::uuid, $2::uuid) AND user_id2 = GREATEST($1::uuid, $2::uuid))`, matchesTable)
+		if err := tx.GetContext(ctx, &matched, query, id, swipe.Who); err != nil {
+			return models.Swipe{}, err
+		}
+		if matched {
+			return models.Swipe{}, schemas.ConflictError{Message: "the like made a match, unmatch instead"}
+		}
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, table)
+	res, err := tx.ExecContext(ctx, query, swipe.Id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to undo swipe", "swipe_id", swipe.Id, "error", err)
+		return models.Swipe{}, err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return models.Swipe{}, schemas.ConflictError{Message: "the swipe was undone already"}
+	}
+
+	return swipe, tx.Commit()
+}
+
+func (r repository) GetUserImage(ctx context.Context, id uuid.UUID) (models.Image, error) {
 	var image models.Image
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1`, imageTable)
-	fmt.Sprintf(`SELECT * FROM %s WHERE userId = $1`, imageTable)
-	err := r.db.Get(&image, query, id)
+	err := r.db.GetContext(ctx, &image, query, id)
 	if err == sql.ErrNoRows {
 		return image, schemas.NotFoundError{Message: fmt.Sprintf("Not found any image of user with id %v", id)}
 	}
@@ -92,27 +248,26 @@ func (r repository) GetUserImage(id uuid.UUID) (models.Image, error) {
 	return image, err
 }
 
-func (r repository) Create(user models.User) (uuid.UUID, error) {
+func (r repository) Create(ctx context.Context, user models.User) (uuid.UUID, error) {
 	query := fmt.Sprintf("INSERT INTO %s (id, name, surname, email, phone_number, has_access)"+
 		" values ($1, $2, $3, $4, $5, $6) RETURNING id", userTable)
 
 	var id uuid.UUID
 
-	row := r.db.QueryRow(query, user.Id, user.Name, user.Surname, user.Email, user.PhoneNumber, user.HasAccess)
+	row := r.db.QueryRowContext(ctx, query, user.Id, user.Name, user.Surname, user.Email, user.PhoneNumber, user.HasAccess)
 
 	if err := row.Scan(&id); err != nil {
-		r.logger.Printf("error in db while trying to create user %v, error: %s",
-			user.Id, err.Error())
+		r.logger.ErrorContext(ctx, "error in db while trying to create user", "user_id", user.Id, "error", err)
 		return uuid.Nil, err
 	}
 
 	return id, nil
 }
 
-func (r repository) GetById(id uuid.UUID) (models.User, error) {
+func (r repository) GetById(ctx context.Context, id uuid.UUID) (models.User, error) {
 	var user models.User
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, userTable)
-	err := r.db.Get(&user, query, id)
+	err := r.db.GetContext(ctx, &user, query, id)
 	if err == sql.ErrNoRows {
 		return user, schemas.NotFoundError{Message: fmt.Sprintf("Not found any user with id %v", id)}
 	}
@@ -120,7 +275,7 @@ func (r repository) GetById(id uuid.UUID) (models.User, error) {
 	return user, err
 }
 
-func (r repository) Update(id uuid.UUID, user models.UpdateUserInfo) error {
+func (r repository) Update(ctx context.Context, id uuid.UUID, user models.UpdateUserInfo) error {
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 	argId := 1
@@ -155,69 +310,115 @@ func (r repository) Update(id uuid.UUID, user models.UpdateUserInfo) error {
 		argId++
 	}
 
+	if user.Description != nil {
+		setValues = append(setValues, fmt.Sprintf("description=$%d", argId))
+		args = append(args, *user.Description)
+		argId++
+	}
+
+	if user.BirthDate != nil {
+		setValues = append(setValues, fmt.Sprintf("birth_date=$%d", argId))
+		args = append(args, *user.BirthDate)
+		argId++
+	}
+
+	if user.Gender != nil {
+		setValues = append(setValues, fmt.Sprintf("gender=$%d", argId))
+		args = append(args, *user.Gender)
+		argId++
+	}
+
+	if user.Interests != nil {
+		setValues = append(setValues, fmt.Sprintf("interests=$%d", argId))
+		args = append(args, pq.Array(user.Interests))
+		argId++
+	}
+
+	if user.City != nil {
+		setValues = append(setValues, fmt.Sprintf("city=$%d", argId))
+		args = append(args, *user.City)
+		argId++
+	}
+
+	if len(setValues) == 0 {
+		return nil
+	}
+
 	setQuery := strings.Join(setValues, ", ")
 	query := fmt.Sprintf("UPDATE %s u SET %s WHERE u.id = $%v", userTable, setQuery, argId)
 
 	args = append(args, id)
 
-	_, err := r.db.Exec(query, args...)
+	_, err := r.db.ExecContext(ctx, query, args...)
 
 	return err
 }
 
-func (r repository) UpdateUserImage(userId uuid.UUID, image string) error {
+func (r repository) UpdateUserImage(ctx context.Context, userId uuid.UUID, image string) error {
 
 	return nil
 
 }
 
-func (r repository) DeleteById(id uuid.UUID) error {
+func (r repository) DeleteById(ctx context.Context, id uuid.UUID) error {
 	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, userTable)
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 
 	return err
 }
 
-func (r repository) CreateMusicToUser(userToMusic models.UserToMusic) error {
+func (r repository) CreateMusicToUser(ctx context.Context, userToMusic models.UserToMusic) error {
 	query := fmt.Sprintf("INSERT INTO %s (id, user_id, music_id, favourite_level)"+
 		" values ($1, $2, $3, $4) RETURNING id", userToMusicTable)
 
 	var id uuid.UUID
 
-	row := r.db.QueryRow(query, userToMusic.Id, userToMusic.UserId, userToMusic.MusicId, userToMusic.FavouriteLevel)
+	row := r.db.QueryRowContext(ctx, query, userToMusic.Id, userToMusic.UserId, userToMusic.MusicId, userToMusic.FavouriteLevel)
 
 	if err := row.Scan(&id); err != nil {
-		r.logger.Printf("error in db while trying to create user to music %v, error: %s",
-			userToMusic.Id, err.Error())
+		r.logger.ErrorContext(ctx, "error in db while trying to create user to music", "user_to_music_id", userToMusic.Id, "error", err)
 		return err
 	}
 
 	return nil
 }
 
-func (r repository) CreateUserImage(image models.Image) error {
+func (r repository) CreateUserImage(ctx context.Context, image models.Image) error {
 	query := fmt.Sprintf("INSERT INTO %s (id, user_id, image)"+
 		" values ($1, $2, $3) RETURNING id", imageTable)
 
 	var id uuid.UUID
 
-	row := r.db.QueryRow(query, image.Id, image.UserId, image.Image)
+	row := r.db.QueryRowContext(ctx, query, image.Id, image.UserId, image.Image)
 
 	if err := row.Scan(&id); err != nil {
-		r.logger.Printf("error in db while trying to create user image %v, error: %s",
-			image.Id, err.Error())
+		r.logger.ErrorContext(ctx, "error in db while trying to create user image", "image_id", image.Id, "error", err)
 		return err
 	}
 	return nil
 }
 
-func (r repository) GetAll(page, size int) ([]models.User, error) {
+// GetMusic returns the music of all the users, the favourite first.
+func (r repository) GetMusic(ctx context.Context, userIds []uuid.UUID) ([]models.UserToMusic, error) {
+	var music []models.UserToMusic
+	query := fmt.Sprintf("SELECT * FROM %s WHERE user_id = ANY($1) ORDER BY favourite_level DESC, id", userToMusicTable)
+
+	err := r.db.SelectContext(ctx, &music, query, pq.Array(userIds))
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error in db while trying to get music of users", "error", err)
+		return nil, err
+	}
+
+	return music, nil
+}
+
+func (r repository) GetAll(ctx context.Context, page, size int) ([]models.User, error) {
 	var users []models.User
-	query := fmt.Sprintf("SELECT * FROM %s LIMIT $1 OFFSET $2", userTable)
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY surname, name, id LIMIT $1 OFFSET $2", userTable)
 
-	err := r.db.Select(&users, query, page*size, page-1)
+	err := r.db.SelectContext(ctx, &users, query, size, (page-1)*size)
 	if err != nil {
-		r.logger.Printf("error in db while trying to get all users, error: %s", err.Error())
+		r.logger.ErrorContext(ctx, "error in db while trying to get all users", "error", err)
 		return nil, err
 	}
 
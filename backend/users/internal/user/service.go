@@ -1,148 +1,275 @@
 package user
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"time"
 
+	"github.com/Feokrat/music-dating-app/users/internal/config"
 	"github.com/Feokrat/music-dating-app/users/internal/models"
+	"github.com/Feokrat/music-dating-app/users/internal/music"
+	"github.com/Feokrat/music-dating-app/users/internal/payments"
 	"github.com/Feokrat/music-dating-app/users/internal/schemas"
 	"github.com/google/uuid"
 )
 
 type service struct {
-	userRepository Repository
-	logger         *log.Logger
+	userRepository  Repository
+	musicService    music.Service
+	paymentsService payments.Service
+	swipes          config.SwipesConfig
+	logger          *slog.Logger
 }
 
 type Service interface {
-	AddUser(user models.User) (uuid.UUID, error)
-	GetUserById(id uuid.UUID) (schemas.UserResponse, error)
-	DeleteUserById(id uuid.UUID) error
-	AddMusicToUser(userToMusic models.UserToMusic) error
-	AddImageToUser(image models.Image) error
-	UpdateUserInfo(id uuid.UUID, user models.UpdateUserInfo) error
-	GetAllUsers(page, size int) (schemas.UsersResponse, error)
-	GetUserRecommendations(id uuid.UUID, page, size int) (schemas.UsersResponse, error)
-	GetUserImageById(id uuid.UUID) (models.Image, error)
-	LikeUser(id uuid.UUID, likedId uuid.UUID) (bool, error)
+	AddUser(ctx context.Context, user models.User) (uuid.UUID, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (schemas.UserResponse, error)
+	DeleteUserById(ctx context.Context, id uuid.UUID) error
+	AddMusicToUser(ctx context.Context, userToMusic models.UserToMusic) error
+	AddImageToUser(ctx context.Context, image models.Image) error
+	UpdateUserInfo(ctx context.Context, id uuid.UUID, user models.UpdateUserInfo) error
+	GetAllUsers(ctx context.Context, page, size int) (schemas.UsersResponse, error)
+	GetUserRecommendations(ctx context.Context, id uuid.UUID, page, size int) (schemas.RecommendationsResponse, error)
+	GetUserImageById(ctx context.Context, id uuid.UUID) (models.Image, error)
+	LikeUser(ctx context.Context, id uuid.UUID, likedId uuid.UUID) (schemas.LikeResponse, error)
+	DislikeUser(ctx context.Context, id uuid.UUID, dislikedId uuid.UUID) (schemas.SwipeResponse, error)
+	PassUser(ctx context.Context, id uuid.UUID, passedId uuid.UUID) (schemas.SwipeResponse, error)
+	GetSwipes(ctx context.Context, id uuid.UUID, page, size int) (schemas.SwipesResponse, error)
+	UndoLastSwipe(ctx context.Context, id uuid.UUID) (schemas.SwipeResponse, error)
 }
 
-func NewService(repo Repository, logger *log.Logger) Service {
-	return service{repo, logger}
+func NewService(repo Repository, musicService music.Service, paymentsService payments.Service, swipes config.SwipesConfig, logger *slog.Logger) Service {
+	return service{repo, musicService, paymentsService, swipes, logger}
 }
 
-func (s service) LikeUser(id uuid.UUID, likedId uuid.UUID) (bool, error) {
-	isMatch, err := s.userRepository.Like(id, likedId)
-	return isMatch, err
+func (s service) LikeUser(ctx context.Context, id uuid.UUID, likedId uuid.UUID) (schemas.LikeResponse, error) {
+	match, created, err := s.userRepository.Like(ctx, id, likedId)
+	if err != nil {
+		return schemas.LikeResponse{}, err
+	}
+
+	likesTotal.Inc()
+	if match == nil {
+		return schemas.LikeResponse{}, nil
+	}
+	if created {
+		matchesTotal.Inc()
+	}
+	return schemas.LikeResponse{IsMatch: true, MatchId: &match.Id, IsNewMatch: created}, nil
+}
+
+func (s service) DislikeUser(ctx context.Context, id uuid.UUID, dislikedId uuid.UUID) (schemas.SwipeResponse, error) {
+	swipe, err := s.userRepository.Dislike(ctx, id, dislikedId, nil)
+	if err != nil {
+		return schemas.SwipeResponse{}, err
+	}
+
+	dislikesTotal.Inc()
+	return swipeResponse(swipe), nil
+}
+
+// PassUser hides the passed user from the recommendations of the user for
+// the configured cool-down.
+func (s service) PassUser(ctx context.Context, id uuid.UUID, passedId uuid.UUID) (schemas.SwipeResponse, error) {
+	expiresAt := time.Now().Add(s.swipes.PassCooldown)
+	swipe, err := s.userRepository.Dislike(ctx, id, passedId, &expiresAt)
+	if err != nil {
+		return schemas.SwipeResponse{}, err
+	}
+
+	passesTotal.Inc()
+	return swipeResponse(swipe), nil
+}
+
+func (s service) GetSwipes(ctx context.Context, id uuid.UUID, page, size int) (schemas.SwipesResponse, error) {
+	swipes, err := s.userRepository.GetSwipes(ctx, id, page, size)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error occurred during getting swipes of user", "id", id)
+		return schemas.SwipesResponse{}, err
+	}
+
+	response := schemas.SwipesResponse{Swipes: make([]schemas.SwipeResponse, 0, len(swipes))}
+	for _, swipe := range swipes {
+		response.Swipes = append(response.Swipes, swipeResponse(swipe))
+	}
+
+	return response, nil
+}
+
+// UndoLastSwipe takes back the latest swipe of the user, which takes an
+// active Prime subscription.
+func (s service) UndoLastSwipe(ctx context.Context, id uuid.UUID) (schemas.SwipeResponse, error) {
+	prime, err := s.paymentsService.HasPrime(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get subscription of user", "id", id, "error", err)
+		return schemas.SwipeResponse{}, err
+	}
+	if !prime {
+		return schemas.SwipeResponse{}, schemas.ForbiddenError{Message: "undoing swipes is only available with Prime"}
+	}
+
+	swipe, err := s.userRepository.UndoLastSwipe(ctx, id)
+	if err != nil {
+		return schemas.SwipeResponse{}, err
+	}
+
+	undoneSwipesTotal.Inc()
+	return swipeResponse(swipe), nil
+}
+
+func swipeResponse(swipe models.Swipe) schemas.SwipeResponse {
+	return schemas.SwipeResponse{
+		Id:        swipe.Id,
+		UserId:    swipe.Who,
+		Kind:      swipe.Kind,
+		CreatedAt: swipe.CreatedAt,
+		ExpiresAt: swipe.ExpiresAt,
+	}
 }
 
-func (s service) AddUser(user models.User) (uuid.UUID, error) {
-	id, err := s.userRepository.Create(user)
+func (s service) AddUser(ctx context.Context, user models.User) (uuid.UUID, error) {
+	id, err := s.userRepository.Create(ctx, user)
 	return id, err
 }
 
-func (s service) GetUserById(id uuid.UUID) (schemas.UserResponse, error) {
-	user, err := s.userRepository.GetById(id)
+func (s service) GetUserById(ctx context.Context, id uuid.UUID) (schemas.UserResponse, error) {
+	user, err := s.userRepository.GetById(ctx, id)
 	if err != nil {
-		s.logger.Printf("Error occured during getting user")
+		s.logger.ErrorContext(ctx, "error occurred during getting user")
 		return schemas.UserResponse{}, err
 	}
-	image, err := s.userRepository.GetUserImage(id)
+	profiles, err := s.userResponses(ctx, []models.User{user})
 	if err != nil {
-		s.logger.Printf("Error occured during getting user image")
-		return schemas.UserResponse{Id: user.Id,
+		return schemas.UserResponse{}, err
+	}
+	image, err := s.userRepository.GetUserImage(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error occurred during getting user image")
+		return profiles[0], nil
+	}
+	profiles[0].Image = image.Image
+
+	return profiles[0], nil
+}
+
+// userResponses builds the profiles of the users. Their music and
+// subscriptions are fetched for all of them at once, the subscription is
+// the free one while the payment service is unavailable.
+func (s service) userResponses(ctx context.Context, users []models.User) ([]schemas.UserResponse, error) {
+	userIds := make([]uuid.UUID, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.Id)
+	}
+
+	music, err := s.userRepository.GetMusic(ctx, userIds)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error occurred during getting music of users")
+		return nil, err
+	}
+	musicIds := make(map[uuid.UUID][]string, len(users))
+	for _, userToMusic := range music {
+		musicIds[userToMusic.UserId] = append(musicIds[userToMusic.UserId], userToMusic.MusicId.String())
+	}
+
+	subscriptionTypes, err := s.paymentsService.GetSubscriptionTypes(ctx, userIds)
+	if err != nil {
+		s.logger.WarnContext(ctx, "could not get subscriptions of users, showing the free one", "error", err)
+	}
+
+	responses := make([]schemas.UserResponse, 0, len(users))
+	for _, user := range users {
+		response := schemas.UserResponse{Id: user.Id,
 			Name:             user.Name,
 			Surname:          user.Surname,
-			Description:      "Хочу квас",
-			SubscriptionType: 0,
-			MusicIds:         []string{"365b8b96-3244-486e-934d-9b020fe6ea72"},
-		}, nil
-	}
-	return schemas.UserResponse{Id: user.Id,
-		Name:             user.Name,
-		Surname:          user.Surname,
-		Description:      "Хочу квас",
-		SubscriptionType: 0,
-		Image:            image.Image,
-		MusicIds:         []string{"365b8b96-3244-486e-934d-9b020fe6ea72"},
-	}, err
-}
-
-func (s service) GetUserImageById(id uuid.UUID) (models.Image, error) {
-	image, err := s.userRepository.GetUserImage(id)
+			Description:      user.Description,
+			Gender:           user.Gender,
+			Interests:        user.Interests,
+			City:             user.City,
+			SubscriptionType: subscriptionTypes[user.Id],
+			MusicIds:         musicIds[user.Id],
+		}
+		if response.Interests == nil {
+			response.Interests = []string{}
+		}
+		if response.MusicIds == nil {
+			response.MusicIds = []string{}
+		}
+		if user.BirthDate != nil {
+			response.BirthDate = user.BirthDate.Format(schemas.DateLayout)
+		}
+		responses = append(responses, response)
+	}
+
+	return responses, nil
+}
+
+func (s service) GetUserImageById(ctx context.Context, id uuid.UUID) (models.Image, error) {
+	image, err := s.userRepository.GetUserImage(ctx, id)
 	return image, err
 }
 
-func (s service) DeleteUserById(id uuid.UUID) error {
-	err := s.userRepository.DeleteById(id)
+func (s service) DeleteUserById(ctx context.Context, id uuid.UUID) error {
+	err := s.userRepository.DeleteById(ctx, id)
 	return err
 }
 
-func (s service) AddMusicToUser(userToMusic models.UserToMusic) error {
-	err := s.userRepository.CreateMusicToUser(userToMusic)
+func (s service) AddMusicToUser(ctx context.Context, userToMusic models.UserToMusic) error {
+	err := s.userRepository.CreateMusicToUser(ctx, userToMusic)
 	return err
 }
 
-func (s service) AddImageToUser(image models.Image) error {
-	err := s.userRepository.CreateUserImage(image)
+func (s service) AddImageToUser(ctx context.Context, image models.Image) error {
+	err := s.userRepository.CreateUserImage(ctx, image)
 	return err
 }
 
-func (s service) UpdateUserInfo(id uuid.UUID, user models.UpdateUserInfo) error {
-	err := s.userRepository.Update(id, user)
+func (s service) UpdateUserInfo(ctx context.Context, id uuid.UUID, user models.UpdateUserInfo) error {
+	err := s.userRepository.Update(ctx, id, user)
 	return err
 }
 
-func (s service) GetAllUsers(page, size int) (schemas.UsersResponse, error) {
-	users, err := s.userRepository.GetAll(page, size)
+func (s service) GetAllUsers(ctx context.Context, page, size int) (schemas.UsersResponse, error) {
+	users, err := s.userRepository.GetAll(ctx, page, size)
 	if err != nil {
-		s.logger.Printf("Error occured in getting all users")
+		s.logger.ErrorContext(ctx, "error occurred in getting all users")
 		return schemas.UsersResponse{}, err
 	}
-	var usersResponse schemas.UsersResponse
-	for i := 0; i < len(users); i++ {
-		usersResponse.Users = append(usersResponse.Users, schemas.UserResponse{Id: users[i].Id,
-			Name:             users[i].Name,
-			Surname:          users[i].Surname,
-			Description:      "Хочу квас",
-			SubscriptionType: 1,
-			MusicIds:         []string{"365b8b96-3244-486e-934d-9b020fe6ea72"},
-		})
+	profiles, err := s.userResponses(ctx, users)
+	if err != nil {
+		return schemas.UsersResponse{}, err
 	}
 
-	return usersResponse, nil
+	return schemas.UsersResponse{Users: profiles}, nil
 }
 
-func (s service) GetUserRecommendations(id uuid.UUID, page int, size int) (schemas.UsersResponse, error) {
-	users, err := s.userRepository.GetRecommendationsForUser(id, page, size)
+func (s service) GetUserRecommendations(ctx context.Context, id uuid.UUID, page int, size int) (schemas.RecommendationsResponse, error) {
+	recommendations, err := s.musicService.GetUserRecommendations(ctx, id, page, size)
 	if err != nil {
-		s.logger.Printf("Error occured during getting recommendations for user %v", id)
-		return schemas.UsersResponse{}, err
+		s.logger.ErrorContext(ctx, "error occurred during getting recommendations for user", "id", id)
+		return schemas.RecommendationsResponse{}, err
 	}
-	var usersResponse schemas.UsersResponse
+	users := make([]models.User, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		users = append(users, recommendation.User)
+	}
+	profiles, err := s.userResponses(ctx, users)
+	if err != nil {
+		return schemas.RecommendationsResponse{}, err
+	}
+	var response schemas.RecommendationsResponse
 
-	for i := 0; i < len(users); i++ {
-		image, err := s.userRepository.GetUserImage(users[i].Id)
+	for i, recommendation := range recommendations {
+		image, err := s.userRepository.GetUserImage(ctx, profiles[i].Id)
 		if err != nil {
-			s.logger.Printf("Error occured during getting image for user %v", users[i].Id)
-			usersResponse.Users = append(usersResponse.Users, schemas.UserResponse{Id: users[i].Id,
-				Name:             users[i].Name,
-				Surname:          users[i].Surname,
-				Description:      "Хочу квас",
-				SubscriptionType: 0,
-				Image:            "",
-				MusicIds:         []string{"365b8b96-3244-486e-934d-9b020fe6ea72"},
-			})
-		} else {
-			usersResponse.Users = append(usersResponse.Users, schemas.UserResponse{Id: users[i].Id,
-				Name:             users[i].Name,
-				Surname:          users[i].Surname,
-				Description:      "Хочу квас",
-				SubscriptionType: 0,
-				Image:            image.Image,
-				MusicIds:         []string{"365b8b96-3244-486e-934d-9b020fe6ea72"},
-			})
+			s.logger.ErrorContext(ctx, "error occurred during getting image for user", "user_id", profiles[i].Id)
 		}
+		profiles[i].Image = image.Image
+		response.Users = append(response.Users, schemas.RecommendationResponse{
+			UserResponse: profiles[i],
+			Score:        recommendation.Score,
+			Explanation:  recommendation.Explanation,
+		})
 	}
 
-	return usersResponse, nil
+	return response, nil
 }
@@ -0,0 +1,5 @@
+DROP TABLE user_likes;
+DROP TABLE users_to_music;
+DROP TABLE images;
+DROP TABLE musics;
+DROP TABLE users;
@@ -1,6 +1,6 @@
-CREATE DATABASE users;
-
-CREATE TABLE users
+-- the tables may already exist in databases created with the former
+-- database.sql, which are adopted as they are
+CREATE TABLE IF NOT EXISTS users
 (
     id uuid NOT NULL,
     name text NOT NULL,
@@ -11,7 +11,7 @@ CREATE TABLE users
     PRIMARY KEY (id)
 );
 
-CREATE TABLE musics
+CREATE TABLE IF NOT EXISTS musics
 (
     id uuid NOT NULL,
     name text NOT NULL,
@@ -20,7 +20,7 @@ CREATE TABLE musics
     PRIMARY KEY (id)
 );
 
-CREATE TABLE images
+CREATE TABLE IF NOT EXISTS images
 (
     id uuid NOT NULL,
     user_id uuid NOT NULL,
@@ -30,10 +30,12 @@ CREATE TABLE images
         REFERENCES users (id) MATCH SIMPLE
         ON UPDATE NO ACTION
         ON DELETE NO ACTION
-        NOT VALID
 );
 
-CREATE TABLE users_to_musics
+-- database.sql named the table users_to_musics
+ALTER TABLE IF EXISTS users_to_musics RENAME TO users_to_music;
+
+CREATE TABLE IF NOT EXISTS users_to_music
 (
     id uuid NOT NULL,
     user_id uuid NOT NULL,
@@ -43,13 +45,11 @@ CREATE TABLE users_to_musics
     CONSTRAINT "USER_ID_FK" FOREIGN KEY (user_id)
         REFERENCES users (id) MATCH SIMPLE
         ON UPDATE NO ACTION
-        ON DELETE NO ACTION
-        NOT VALID,
+        ON DELETE NO ACTION,
     CONSTRAINT "MUSIC_ID_FK" FOREIGN KEY (music_id)
         REFERENCES musics (id) MATCH SIMPLE
         ON UPDATE NO ACTION
         ON DELETE NO ACTION
-        NOT VALID
 );
 
 CREATE TABLE IF NOT EXISTS user_likes
@@ -58,4 +58,4 @@ CREATE TABLE IF NOT EXISTS user_likes
     who uuid NOT NULL,
     from_who uuid NOT NULL,
     CONSTRAINT user_likes_pkey PRIMARY KEY (id)
-);
\ No newline at end of file
+);
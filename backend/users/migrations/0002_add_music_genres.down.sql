@@ -0,0 +1,5 @@
+DROP INDEX user_likes_from_who_idx;
+DROP INDEX users_to_music_music_id_idx;
+DROP INDEX users_to_music_user_id_idx;
+
+ALTER TABLE musics DROP COLUMN genre;
@@ -0,0 +1,5 @@
+ALTER TABLE musics ADD COLUMN genre text NOT NULL DEFAULT '';
+
+CREATE INDEX users_to_music_user_id_idx ON users_to_music (user_id);
+CREATE INDEX users_to_music_music_id_idx ON users_to_music (music_id);
+CREATE INDEX user_likes_from_who_idx ON user_likes (from_who, who);
@@ -0,0 +1,3 @@
+DROP TABLE user_dislikes;
+
+ALTER TABLE user_likes DROP COLUMN created_at;
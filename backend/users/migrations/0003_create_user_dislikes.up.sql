@@ -0,0 +1,14 @@
+ALTER TABLE user_likes ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+
+CREATE TABLE user_dislikes
+(
+    id uuid NOT NULL,
+    who uuid NOT NULL,
+    from_who uuid NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    -- set for a pass, the user is recommended again after it
+    expires_at timestamptz,
+    CONSTRAINT user_dislikes_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX user_dislikes_from_who_idx ON user_dislikes (from_who, who);
@@ -0,0 +1,3 @@
+DROP TABLE matches;
+
+ALTER TABLE user_likes DROP CONSTRAINT user_likes_who_from_who_key;
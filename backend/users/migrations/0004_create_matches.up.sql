@@ -0,0 +1,24 @@
+-- a user likes another one once, the first of repeated likes is kept
+DELETE FROM user_likes l USING user_likes d
+WHERE l.who = d.who AND l.from_who = d.from_who AND (l.created_at, l.id) > (d.created_at, d.id);
+
+ALTER TABLE user_likes ADD CONSTRAINT user_likes_who_from_who_key UNIQUE (who, from_who);
+
+CREATE TABLE matches
+(
+    id uuid NOT NULL,
+    -- the users are ordered, so that a pair matches once
+    user_id1 uuid NOT NULL,
+    user_id2 uuid NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT matches_pkey PRIMARY KEY (id),
+    CONSTRAINT matches_users_key UNIQUE (user_id1, user_id2),
+    CONSTRAINT matches_users_check CHECK (user_id1 < user_id2)
+);
+
+CREATE INDEX matches_user_id2_idx ON matches (user_id2);
+
+INSERT INTO matches (id, user_id1, user_id2, created_at)
+SELECT gen_random_uuid(), l.from_who, l.who, GREATEST(l.created_at, r.created_at)
+FROM user_likes l JOIN user_likes r ON r.who = l.from_who AND r.from_who = l.who
+WHERE l.from_who < l.who;
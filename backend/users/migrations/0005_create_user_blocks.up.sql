@@ -0,0 +1,14 @@
+CREATE TABLE user_blocks
+(
+    id uuid NOT NULL,
+    who uuid NOT NULL,
+    from_who uuid NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    -- set when the block also reports the user to the moderators
+    reported boolean NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT user_blocks_pkey PRIMARY KEY (id),
+    CONSTRAINT user_blocks_who_from_who_key UNIQUE (who, from_who)
+);
+
+CREATE INDEX user_blocks_from_who_idx ON user_blocks (from_who, who);
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    DROP COLUMN city,
+    DROP COLUMN interests,
+    DROP COLUMN gender,
+    DROP COLUMN birth_date,
+    DROP COLUMN description;
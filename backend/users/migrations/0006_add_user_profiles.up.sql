@@ -0,0 +1,8 @@
+ALTER TABLE users
+    ADD COLUMN description text NOT NULL DEFAULT '',
+    -- unknown for the users who registered before profiles
+    ADD COLUMN birth_date date,
+    ADD COLUMN gender text NOT NULL DEFAULT ''
+        CONSTRAINT users_gender_check CHECK (gender IN ('', 'female', 'male', 'other')),
+    ADD COLUMN interests text[] NOT NULL DEFAULT '{}',
+    ADD COLUMN city text NOT NULL DEFAULT '';
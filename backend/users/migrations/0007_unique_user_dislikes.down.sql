@@ -0,0 +1 @@
+ALTER TABLE user_dislikes DROP CONSTRAINT user_dislikes_who_from_who_key;
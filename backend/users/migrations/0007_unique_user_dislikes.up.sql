@@ -0,0 +1,6 @@
+-- a later dislike or pass of a user replaces the earlier one, the latest of
+-- repeated swipes is kept
+DELETE FROM user_dislikes d USING user_dislikes l
+WHERE d.who = l.who AND d.from_who = l.from_who AND (d.created_at, d.id) < (l.created_at, l.id);
+
+ALTER TABLE user_dislikes ADD CONSTRAINT user_dislikes_who_from_who_key UNIQUE (who, from_who);
@@ -0,0 +1,4 @@
+ALTER TABLE user_blocks DROP CONSTRAINT user_blocks_who_fkey, DROP CONSTRAINT user_blocks_from_who_fkey;
+ALTER TABLE matches DROP CONSTRAINT matches_user_id1_fkey, DROP CONSTRAINT matches_user_id2_fkey;
+ALTER TABLE user_dislikes DROP CONSTRAINT user_dislikes_who_fkey, DROP CONSTRAINT user_dislikes_from_who_fkey;
+ALTER TABLE user_likes DROP CONSTRAINT user_likes_who_fkey, DROP CONSTRAINT user_likes_from_who_fkey;
@@ -0,0 +1,22 @@
+-- swipes, matches and blocks of users that do not exist are dropped, they go
+-- along with the user from now on
+DELETE FROM user_likes WHERE who NOT IN (SELECT id FROM users) OR from_who NOT IN (SELECT id FROM users);
+DELETE FROM user_dislikes WHERE who NOT IN (SELECT id FROM users) OR from_who NOT IN (SELECT id FROM users);
+DELETE FROM matches WHERE user_id1 NOT IN (SELECT id FROM users) OR user_id2 NOT IN (SELECT id FROM users);
+DELETE FROM user_blocks WHERE who NOT IN (SELECT id FROM users) OR from_who NOT IN (SELECT id FROM users);
+
+ALTER TABLE user_likes
+    ADD CONSTRAINT user_likes_who_fkey FOREIGN KEY (who) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT user_likes_from_who_fkey FOREIGN KEY (from_who) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE user_dislikes
+    ADD CONSTRAINT user_dislikes_who_fkey FOREIGN KEY (who) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT user_dislikes_from_who_fkey FOREIGN KEY (from_who) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE matches
+    ADD CONSTRAINT matches_user_id1_fkey FOREIGN KEY (user_id1) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT matches_user_id2_fkey FOREIGN KEY (user_id2) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE user_blocks
+    ADD CONSTRAINT user_blocks_who_fkey FOREIGN KEY (who) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT user_blocks_from_who_fkey FOREIGN KEY (from_who) REFERENCES users (id) ON DELETE CASCADE;
@@ -0,0 +1,63 @@
+package HTTPclient
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker opens after threshold consecutive failures and rejects calls until
+// openTimeout has passed. Then a single probe call is let through: success
+// closes the breaker, failure opens it again.
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	return &breaker{threshold: threshold, openTimeout: openTimeout}
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.openTimeout {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+}
+
+// failure records a failed call and tells whether the breaker is open now.
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		return true
+	}
+	return false
+}
@@ -2,25 +2,31 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Feokrat/music-dating-app/users/internal/config"
+	"github.com/XSAM/otelsql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-func NewPostgresDB(cfg config.PGConfig, logger *log.Logger) (*sqlx.DB, error) {
-	db, err := sqlx.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s "+
+func NewPostgresDB(cfg config.PGConfig, logger *slog.Logger) (*sqlx.DB, error) {
+	// the driver is wrapped to trace every query as a child of the request span
+	sqlDB, err := otelsql.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s "+
 		"sslmode=%s",
-		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode))
+		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode),
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}))
 	if err != nil {
-		logger.Printf("failed to open connection to database: %s", err)
+		logger.Error("failed to open connection to database", "error", err)
 		return nil, err
 	}
+	db := sqlx.NewDb(sqlDB, "postgres")
 
 	err = db.Ping()
 	if err != nil {
-		logger.Printf("failed to connect database: %s", err)
+		logger.Error("failed to connect database", "error", err)
 		return nil, err
 	}
 
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusUp           = "up"
+	StatusDown         = "down"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check tells whether a dependency of the service can be used.
+type Check func(ctx context.Context) error
+
+type dependency struct {
+	name  string
+	check Check
+}
+
+// DependencyReport is the outcome of one check.
+type DependencyReport struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyReport `json:"dependencies,omitempty"`
+}
+
+// Checker runs the readiness checks of a service. Once Shutdown is called the
+// service reports itself as not ready, so that it stops getting new traffic
+// while in-flight requests finish.
+type Checker struct {
+	timeout      time.Duration
+	dependencies []dependency
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a dependency, checks are not safe to add once serving.
+func (c *Checker) Add(name string, check Check) {
+	c.dependencies = append(c.dependencies, dependency{name: name, check: check})
+}
+
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Ready runs all checks concurrently, each bounded by the checker timeout.
+func (c *Checker) Ready(ctx context.Context) (Report, bool) {
+	report := Report{Status: StatusUp, Dependencies: make(map[string]DependencyReport, len(c.dependencies))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, dep := range c.dependencies {
+		wg.Add(1)
+		go func(dep dependency) {
+			defer wg.Done()
+			result := c.run(ctx, dep.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[dep.name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(dep)
+	}
+	wg.Wait()
+
+	if c.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report, report.Status == StatusUp
+}
+
+func (c *Checker) run(ctx context.Context, check Check) DependencyReport {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check(ctx)
+	result := DependencyReport{Status: StatusUp, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// RegisterHandlers adds /healthz, which only tells that the process serves
+// requests, and /readyz, which fails while a dependency is down.
+func RegisterHandlers(router gin.IRoutes, checker *Checker) {
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, Report{Status: StatusUp})
+	})
+
+	router.GET("/readyz", func(c *gin.Context) {
+		report, ready := checker.Ready(c.Request.Context())
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+}
@@ -0,0 +1,237 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// versionTable records the applied migrations.
+const versionTable = "schema_migrations"
+
+// lockKey is the advisory lock taken while migrating, so that replicas
+// starting at the same time do not apply a migration twice.
+const lockKey = 72_410_351
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change, Down reverts Up.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads migrations named like 0001_create_users.up.sql and
+// 0001_create_users.down.sql from the root of fsys.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s is not named like 0001_name.up.sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+func NewMigrator(db *sqlx.DB, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations, logger: logger}, nil
+}
+
+// Up applies every migration newer than the current version, each in its own
+// transaction together with its record in the version table.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				query := fmt.Sprintf(`INSERT INTO %s (version, name) VALUES ($1, $2)`, versionTable)
+				_, err := tx.ExecContext(ctx, query, migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.InfoContext(ctx, "applied migration", "version", migration.Version, "name", migration.Name)
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		for ; steps > 0; steps-- {
+			current, err := version(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				return nil
+			}
+
+			migration, ok := m.find(current)
+			if !ok {
+				return fmt.Errorf("applied migration %d is unknown to this build", current)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+
+			err = inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+				query := fmt.Sprintf(`DELETE FROM %s WHERE version = $1`, versionTable)
+				_, err := tx.ExecContext(ctx, query, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.InfoContext(ctx, "reverted migration", "version", migration.Version, "name", migration.Name)
+		}
+
+		return nil
+	})
+}
+
+// Version returns the last applied migration, 0 when there is none.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var current int
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		var err error
+		current, err = version(ctx, conn)
+		return err
+	})
+
+	return current, err
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+// locked runs fn on a single connection holding the migration lock, the
+// advisory lock belongs to the database session and not to a transaction.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	)`, versionTable)
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func version(ctx context.Context, conn *sql.Conn) (int, error) {
+	var current int
+	query := fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s`, versionTable)
+	err := conn.QueryRowContext(ctx, query).Scan(&current)
+
+	return current, err
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
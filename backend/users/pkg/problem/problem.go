@@ -0,0 +1,228 @@
+// Package problem implements RFC 7807 problem details, the error format
+// shared by every service.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Feokrat/music-dating-app/users/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ContentType = "application/problem+json"
+
+// typeBase prefixes Code to build the problem type URI.
+const typeBase = "/problems/"
+
+// Stable problem codes, clients are expected to switch on them.
+const (
+	CodeValidation          = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeTooManyRequests     = "too_many_requests"
+	CodeInternal            = "internal_error"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+)
+
+// Problem is a problem details document. It is an error, so services can
+// return it as is and handlers write it with Respond.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	TraceID   string       `json:"traceId,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError tells what is wrong with one field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   typeBase + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func Validation(detail string, fields ...FieldError) *Problem {
+	p := New(http.StatusBadRequest, CodeValidation, detail)
+	p.Errors = fields
+	return p
+}
+
+func Unauthorized(detail string) *Problem {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Problem {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Conflict(detail string) *Problem {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// Internal hides the cause from the client, it is only logged.
+func Internal() *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, "")
+}
+
+// InvalidParam reports a malformed path or query parameter.
+func InvalidParam(name string, err error) *Problem {
+	return Validation(fmt.Sprintf("invalid %s", name), FieldError{
+		Field:   name,
+		Code:    "invalid",
+		Message: err.Error(),
+	})
+}
+
+// Binding turns an error of gin binding into a validation problem with the
+// offending fields named as in the JSON body.
+func Binding(err error) *Problem {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fieldError.Namespace()),
+				Code:    fieldError.Tag(),
+				Message: fieldMessage(fieldError),
+			})
+		}
+		return Validation("request body is invalid", fields...)
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return Validation("request body is invalid", FieldError{
+			Field:   typeError.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("must be of type %s", typeError.Type),
+		})
+	}
+
+	return Validation("request body is not valid JSON")
+}
+
+// Describer is implemented by domain errors that correspond to a problem.
+type Describer interface {
+	Problem() *Problem
+}
+
+// Respond writes err as a problem and aborts the request. Errors that neither
+// are a *Problem nor a Describer become an internal error.
+func Respond(c *gin.Context, err error) {
+	var p *Problem
+	var describer Describer
+	switch {
+	case errors.As(err, &p):
+	case errors.As(err, &describer):
+		p = describer.Problem()
+	default:
+		p = Internal()
+	}
+
+	// the document is copied, a problem may be a shared value
+	document := *p
+	if document.Instance == "" {
+		document.Instance = c.Request.URL.Path
+	}
+	if document.TraceID == "" {
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+			document.TraceID = spanContext.TraceID().String()
+		}
+	}
+	if document.RequestID == "" {
+		document.RequestID = logging.RequestID(c.Request.Context())
+	}
+
+	if err != nil {
+		c.Error(err)
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(document.Status, document)
+}
+
+// Recovery is gin.Recovery answering with an internal error problem.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		Respond(c, fmt.Errorf("panic: %v", recovered))
+	})
+}
+
+// NoRoute answers requests to unknown routes with a not found problem.
+func NoRoute(c *gin.Context) {
+	Respond(c, NotFound("no route for "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+func fieldPath(namespace string) string {
+	// the namespace starts with the name of the bound struct
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be an email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fieldError.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldError.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", fieldError.Param())
+	}
+	return fmt.Sprintf("failed the %s check", fieldError.Tag())
+}
+
+// Field errors are reported with their JSON names instead of the Go ones.
+func init() {
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}